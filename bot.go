@@ -5,6 +5,7 @@ import (
 	"path"
 	"sync"
 
+	"github.com/gasoid/merge-bot/v3/cache"
 	"github.com/gasoid/merge-bot/v3/config"
 	"github.com/gasoid/merge-bot/v3/handlers"
 	"github.com/gasoid/merge-bot/v3/logger"
@@ -95,7 +96,16 @@ func Handler(c echo.Context) error {
 				return
 			}
 
-			if err := command.LoadInfoAndConfig(hook.GetProjectID(), hook.GetID()); err != nil {
+			// project events like branch pushes don't have MR
+			isProjectEvent := hook.GetID() == 0
+
+			if isProjectEvent {
+				err = command.LoadProject(hook.GetProjectID())
+			} else {
+				err = command.LoadInfoAndConfig(hook.GetProjectID(), hook.GetID())
+			}
+
+			if err != nil {
 				logger.Error("can't load repo config", "provider", providerName, "command", command, "err", err)
 				return
 			}
@@ -105,7 +115,17 @@ func Handler(c echo.Context) error {
 				return
 			}
 
-			go backgroundRoutine(command)
+			isNew, err := cache.RegisterProject(providerName, hook.GetProjectID())
+			if err != nil {
+				logger.Info("project can't be registered for scheduled tasks", "projectId", hook.GetProjectID(), "err", err)
+			}
+
+			// scheduled tasks reach the project within the hour, labels are needed right away
+			if isNew {
+				if err := command.CreateLabels(); err != nil {
+					logger.Error("can't create labels", "projectId", hook.GetProjectID(), "err", err)
+				}
+			}
 
 			if hook.NoteID > 0 {
 				if err := command.AwardEmoji(hook.NoteID, emojiRobot); err != nil {
@@ -142,17 +162,3 @@ func handle(onEvent string, funcHandler func(*handlers.Request, string) error) {
 		)
 	}
 }
-
-func backgroundRoutine(command *handlers.Request) {
-	if err := command.CreateLabels(); err != nil {
-		logger.Error("command.CreateLabels", "err", err)
-	}
-
-	if err := command.DeleteStaleBranches(); err != nil {
-		logger.Error("command.DeleteStaleBranches", "err", err)
-	}
-
-	if err := command.UpdateReviewRouletteCounts(); err != nil {
-		logger.Error("command.UpdateReviewRouletteCounts", "err", err)
-	}
-}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	assignmentsPrefix = "mergebot:assignments"
+	mrReviewersPrefix = "mergebot:mr-reviewers"
+	// assignmentsTTL is extended on every assignment, the log of inactive projects expires
+	assignmentsTTL = time.Hour * 24 * 365
+	// maxAssignments is the number of the latest assignments kept per project
+	maxAssignments = 5000
+)
+
+// Assignment is a reviewer assigned to MR by the roulette or manually
+type Assignment struct {
+	Reviewer string    `json:"reviewer"`
+	MergeID  int64     `json:"mr"`
+	Source   string    `json:"source"`
+	At       time.Time `json:"at"`
+}
+
+func assignmentsKey(id int64) string {
+	return fmt.Sprintf("%s:%d", assignmentsPrefix, id)
+}
+
+func mrReviewersKey(id, mergeID int64) string {
+	return fmt.Sprintf("%s:%d:%d", mrReviewersPrefix, id, mergeID)
+}
+
+// GetMRReviewers returns reviewers of MR which are in the assignment log, false if MR isn't indexed yet
+func GetMRReviewers(id, mergeID int64) ([]string, bool, error) {
+	reviewers := []string{}
+	ok, err := getJson("mr-reviewers", mrReviewersKey(id, mergeID), &reviewers)
+	return reviewers, ok, err
+}
+
+// SetMRReviewers saves the index of the assignment log for MR, it expires with the log
+func SetMRReviewers(id, mergeID int64, reviewers []string) error {
+	if err := setJson(mrReviewersKey(id, mergeID), reviewers, assignmentsTTL); err != nil {
+		return fmt.Errorf("can't save reviewers of MR err: %w", err)
+	}
+
+	return nil
+}
+
+func AddAssignments(id int64, assignments ...Assignment) error {
+	for _, a := range assignments {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+
+		if err := contributors.ListPush(assignmentsKey(id), string(data), maxAssignments); err != nil {
+			return fmt.Errorf("can't save assignment err: %w", err)
+		}
+	}
+
+	return contributors.ExtendTTL(assignmentsKey(id), assignmentsTTL)
+}
+
+// GetAssignments returns assignments made since the time, oldest first
+func GetAssignments(id int64, since time.Time) ([]Assignment, error) {
+	values, err := contributors.ListRange(assignmentsKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]Assignment, 0, len(values))
+	for _, v := range values {
+		a := Assignment{}
+		if err := json.Unmarshal([]byte(v), &a); err != nil {
+			logger.Debug("assignment can't be decoded", "project", id, "value", v, "err", err)
+			continue
+		}
+
+		if a.At.Before(since) {
+			continue
+		}
+
+		assignments = append(assignments, a)
+	}
+
+	return assignments, nil
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+//nolint:errcheck
+func TestAssignments(t *testing.T) {
+	redisUrl = ""
+	Init()
+
+	id := int64(654)
+	now := time.Now()
+
+	if res, err := GetAssignments(id, time.Time{}); err != nil || len(res) != 0 {
+		t.Fatalf("expected empty log, got %v, err: %v", res, err)
+	}
+
+	AddAssignments(id,
+		Assignment{Reviewer: "user1", MergeID: 1, Source: "roulette", At: now.AddDate(0, 0, -40)},
+		Assignment{Reviewer: "user2", MergeID: 2, Source: "manual", At: now.AddDate(0, 0, -1)},
+	)
+	AddAssignments(id, Assignment{Reviewer: "user1", MergeID: 3, Source: "roulette", At: now})
+
+	res, err := GetAssignments(id, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("GetAssignments failed: %v", err)
+	}
+
+	if len(res) != 2 || res[0].Reviewer != "user2" || res[1].MergeID != 3 {
+		t.Errorf("expected assignments of MR 2 and 3, got %v", res)
+	}
+
+	// the log keeps only the latest assignments
+	for i := range maxAssignments {
+		AddAssignments(id, Assignment{Reviewer: "user3", MergeID: int64(i + 10), At: now})
+	}
+
+	res, _ = GetAssignments(id, time.Time{})
+	if len(res) != maxAssignments || res[0].Reviewer != "user3" {
+		t.Errorf("expected %d assignments of user3, got %d", maxAssignments, len(res))
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	branchNoticesPrefix = "mergebot:branch-notices"
+	branchNoticesTTL    = time.Hour * 24 * 180
+)
+
+func branchNoticeKey(id int64, branch string) string {
+	return fmt.Sprintf("%s:%d:%s", branchNoticesPrefix, id, branch)
+}
+
+// GetBranchNotice decodes the notice sent to the author of the stale branch into v
+func GetBranchNotice(id int64, branch string, v any) (bool, error) {
+	return getJson("branch-notices", branchNoticeKey(id, branch), v)
+}
+
+func SetBranchNotice(id int64, branch string, v any) error {
+	if err := setJson(branchNoticeKey(id, branch), v, branchNoticesTTL); err != nil {
+		return fmt.Errorf("can't save branch notice err: %w", err)
+	}
+
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/gasoid/merge-bot/v3/logger"
 )
 
 type CacheJson interface {
@@ -15,6 +17,18 @@ type CacheJson interface {
 	JsonIncr(key string, item string, v int) (bool, error)
 }
 
+type CacheString interface {
+	StringSet(key, value string, ttl time.Duration) error
+	StringGet(key string) (string, bool, error)
+	Delete(key string) error
+}
+
+// CacheList keeps the latest maxLen values of append-only logs
+type CacheList interface {
+	ListPush(key, value string, maxLen int) error
+	ListRange(key string) ([]string, error)
+}
+
 type CacheLease interface {
 	AcquireLease(key string) bool
 	ReleaseLease(key string)
@@ -28,6 +42,8 @@ type CacheBase interface {
 
 type Cache interface {
 	CacheJson
+	CacheString
+	CacheList
 	CacheLease
 	CacheBase
 }
@@ -48,6 +64,8 @@ func (e *CacheError) Error() string {
 
 func Init() error {
 	if redisUrl == "" {
+		// fairness of the review roulette counts assignments of the log for fairness_days
+		logger.Info("cache is kept in memory, the review assignment log is lost on restart and roulette fairness counts assignments since the start only, set -redis-url to keep it")
 		contributors = &MemCache{}
 	} else {
 		contributors = &RedisCache{}
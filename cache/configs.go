@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+const (
+	configsPrefix      = "mergebot:configs"
+	validConfigsPrefix = "mergebot:valid-configs"
+	configsTTL         = time.Minute * 10
+	// validConfigsTTL is long, the last valid config replaces invalid ones until they are fixed
+	validConfigsTTL = time.Hour * 24 * 30
+)
+
+func configKey(name string) string {
+	return fmt.Sprintf("%s:%s", configsPrefix, name)
+}
+
+func GetConfig(name string) (string, bool, error) {
+	content, ok, err := contributors.StringGet(configKey(name))
+	if ok {
+		metrics.CacheHitInc("configs")
+	} else {
+		metrics.CacheMissInc("configs")
+	}
+
+	return content, ok, err
+}
+
+func SetConfig(name, content string) error {
+	if err := contributors.StringSet(configKey(name), content, configsTTL); err != nil {
+		return fmt.Errorf("can't save config err: %w", err)
+	}
+
+	return nil
+}
+
+func validConfigKey(projectID int64) string {
+	return fmt.Sprintf("%s:%d", validConfigsPrefix, projectID)
+}
+
+// GetValidConfig returns the last config of the project which has no problems
+func GetValidConfig(projectID int64) (string, bool, error) {
+	return contributors.StringGet(validConfigKey(projectID))
+}
+
+func SetValidConfig(projectID int64, content string) error {
+	if err := contributors.StringSet(validConfigKey(projectID), content, validConfigsTTL); err != nil {
+		return fmt.Errorf("can't save valid config err: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	conflictsPrefix = "mergebot:conflicts"
+	conflictsTTL    = time.Hour * 24 * 30
+)
+
+func conflictKey(id, mergeID int64) string {
+	return fmt.Sprintf("%s:%d:%d", conflictsPrefix, id, mergeID)
+}
+
+// MarkConflict saves that the author of MR has been warned about conflicts caused by another MR
+func MarkConflict(id, mergeID, causedBy int64) error {
+	return contributors.StringSet(conflictKey(id, mergeID), strconv.FormatInt(causedBy, 10), conflictsTTL)
+}
+
+// IsConflictMarked returns true if the author of MR has been warned about its conflicts
+func IsConflictMarked(id, mergeID int64) (bool, error) {
+	_, ok, err := contributors.StringGet(conflictKey(id, mergeID))
+	return ok, err
+}
+
+func UnmarkConflict(id, mergeID int64) error {
+	return contributors.Delete(conflictKey(id, mergeID))
+}
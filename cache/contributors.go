@@ -12,87 +12,27 @@ var (
 )
 
 const (
-	countsPrefix       = "mergebot:counts"
 	contributorsPrefix = "mergebot:contributors"
 	updateLocksPrefix  = "mergebot:update:locks"
 	locksPrefix        = "mergebot:locks"
-	countsTTL          = time.Hour * 12
 	contributorsTTL    = time.Hour * 12
 )
 
-func contributorsKey(id int64) string {
-	return fmt.Sprintf("%s:%d", contributorsPrefix, id)
-}
-
-func countsKey(id int64) string {
-	return fmt.Sprintf("%s:%d", countsPrefix, id)
+func contributorsKey(id int64, days int) string {
+	return fmt.Sprintf("%s:%d:%d", contributorsPrefix, id, days)
 }
 
 func locksKey(id int64) string {
 	return fmt.Sprintf("%s:%d", locksPrefix, id)
 }
 
-func updateLockKey(id int64) string {
-	return fmt.Sprintf("%s:%d", updateLocksPrefix, id)
-}
-
-func SetCounts(id int64, counts map[string]int) error {
-	logger.Debug("SetCounts", "size", len(counts))
-	if err := contributors.JsonSet(countsKey(id), counts); err != nil {
-		return fmt.Errorf("can't save counts err: %w", err)
-	}
-
-	return contributors.ExtendTTL(countsKey(id), countsTTL)
-}
-
-func GetCounts(id int64) (map[string]int, error) {
-	logger.Debug("GetCounts")
-	val, err := contributors.JsonGetMap(countsKey(id))
-	if err != nil {
-		return nil, err
-	}
-
-	if val == nil {
-		return nil, nil
-	}
-
-	logger.Debug("GetCounts", "val", val)
-	return val, nil
-}
-
-func IncrCount(id int64, item string) (bool, error) {
-	logger.Debug("IncrCount", "item", item)
-	ok, err := contributors.JsonExists(countsKey(id), item)
-	if err != nil {
-		return false, err
-	}
-
-	if err := contributors.ExtendTTL(countsKey(id), countsTTL); err != nil {
-		return false, err
-	}
-
-	if ok {
-		return contributors.JsonIncr(countsKey(id), item, 1)
-	} else {
-		return true, contributors.JsonAdd(countsKey(id), item, 1)
-	}
-}
-
-func DecrCount(id int64, item string) (bool, error) {
-	ok, err := contributors.JsonExists(countsKey(id), item)
-	if err != nil {
-		return false, err
-	}
-
-	if ok {
-		return contributors.JsonIncr(countsKey(id), item, -1)
-	}
-
-	return false, nil
+func updateLockKey(id int64, branch string) string {
+	return fmt.Sprintf("%s:%d:%s", updateLocksPrefix, id, branch)
 }
 
-func GetContributors(id int64) ([]int64, error) {
-	val, err := contributors.JsonGet(contributorsKey(id))
+// GetContributors returns IDs of MR authors of the project for last days
+func GetContributors(id int64, days int) ([]int64, error) {
+	val, err := contributors.JsonGet(contributorsKey(id, days))
 	if err != nil {
 		return nil, err
 	}
@@ -100,13 +40,13 @@ func GetContributors(id int64) ([]int64, error) {
 	return val, nil
 }
 
-func SetContributors(id int64, candidates []int64) error {
+func SetContributors(id int64, days int, candidates []int64) error {
 	logger.Debug("save contributors", "size", len(candidates))
-	if err := contributors.JsonSet(contributorsKey(id), candidates); err != nil {
+	if err := contributors.JsonSet(contributorsKey(id, days), candidates); err != nil {
 		return err
 	}
 
-	return contributors.ExtendTTL(contributorsKey(id), contributorsTTL)
+	return contributors.ExtendTTL(contributorsKey(id, days), contributorsTTL)
 }
 
 func AcquireBranchDeletionLease(id int64) bool {
@@ -117,12 +57,13 @@ func ReleaseBranchDeletionLease(id int64) {
 	contributors.ReleaseLease(locksKey(id))
 }
 
-func AcquireUpdateLease(id int64) bool {
-	return contributors.AcquireLease(updateLockKey(id))
+// AcquireUpdateLease locks auto-update of MRs into the branch
+func AcquireUpdateLease(id int64, branch string) bool {
+	return contributors.AcquireLease(updateLockKey(id, branch))
 }
 
-func ReleaseUpdateLease(id int64) {
-	contributors.ReleaseLease(updateLockKey(id))
+func ReleaseUpdateLease(id int64, branch string) {
+	contributors.ReleaseLease(updateLockKey(id, branch))
 }
 
 func IsHealthy() bool {
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+const (
+	projectsPrefix = "mergebot:projects"
+	filesPrefix    = "mergebot:files"
+	projectsTTL    = time.Hour
+	filesTTL       = time.Hour * 24
+)
+
+// ProjectHead is the default branch of a project and its last commit, files are cached by the commit
+type ProjectHead struct {
+	DefaultBranch string `json:"default_branch"`
+	SHA           string `json:"sha"`
+}
+
+// File is a cached repository file, missing files are cached as well
+type File struct {
+	Content string `json:"content"`
+	Exists  bool   `json:"exists"`
+}
+
+func projectKey(id int64) string {
+	return fmt.Sprintf("%s:%d", projectsPrefix, id)
+}
+
+func fileKey(id int64, sha, path string) string {
+	return fmt.Sprintf("%s:%d:%s:%s", filesPrefix, id, sha, path)
+}
+
+func getJson(name, key string, v any) (bool, error) {
+	data, ok, err := contributors.StringGet(key)
+	if err != nil || !ok {
+		metrics.CacheMissInc(name)
+		return false, err
+	}
+
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		metrics.CacheMissInc(name)
+		return false, fmt.Errorf("%w: %s can't be decoded: %w", ErrWrongType, key, err)
+	}
+
+	metrics.CacheHitInc(name)
+	return true, nil
+}
+
+func setJson(key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return contributors.StringSet(key, string(data), ttl)
+}
+
+func GetProjectHead(id int64) (*ProjectHead, bool, error) {
+	head := &ProjectHead{}
+	ok, err := getJson("projects", projectKey(id), head)
+	if !ok {
+		return nil, false, err
+	}
+
+	return head, true, nil
+}
+
+func SetProjectHead(id int64, head ProjectHead) error {
+	if err := setJson(projectKey(id), head, projectsTTL); err != nil {
+		return fmt.Errorf("can't save project head err: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateProjectHead moves the cached head when the default branch gets a new commit, files of the old commit expire by TTL
+func UpdateProjectHead(id int64, branch, sha string) error {
+	head, ok, err := GetProjectHead(id)
+	if err != nil || !ok || head.DefaultBranch != branch {
+		return err
+	}
+
+	if sha == "" {
+		return contributors.Delete(projectKey(id))
+	}
+
+	head.SHA = sha
+	return SetProjectHead(id, *head)
+}
+
+func GetFile(id int64, sha, path string) (*File, bool, error) {
+	file := &File{}
+	ok, err := getJson("files", fileKey(id, sha, path), file)
+	if !ok {
+		return nil, false, err
+	}
+
+	return file, true, nil
+}
+
+func SetFile(id int64, sha, path string, file File) error {
+	if err := setJson(fileKey(id, sha, path), file, filesTTL); err != nil {
+		return fmt.Errorf("can't save file err: %w", err)
+	}
+
+	return nil
+}
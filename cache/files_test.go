@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+)
+
+//nolint:errcheck
+func TestFiles_ProjectHead(t *testing.T) {
+	redisUrl = ""
+	Init()
+
+	id := int64(321)
+
+	if _, ok, err := GetProjectHead(id); ok || err != nil {
+		t.Fatalf("expected empty cache, got ok: %v, err: %v", ok, err)
+	}
+
+	SetProjectHead(id, ProjectHead{DefaultBranch: "main", SHA: "aaa"})
+
+	// pushes to other branches don't change the head
+	UpdateProjectHead(id, "feature", "bbb")
+	head, ok, err := GetProjectHead(id)
+	if err != nil || !ok || head.SHA != "aaa" {
+		t.Fatalf("expected head aaa, got %v, ok: %v, err: %v", head, ok, err)
+	}
+
+	UpdateProjectHead(id, "main", "ccc")
+	head, _, _ = GetProjectHead(id)
+	if head.SHA != "ccc" {
+		t.Errorf("expected head ccc, got %s", head.SHA)
+	}
+
+	// empty sha means that the branch has been deleted, head is fetched again
+	UpdateProjectHead(id, "main", "")
+	if _, ok, _ := GetProjectHead(id); ok {
+		t.Errorf("expected head to be deleted")
+	}
+}
+
+//nolint:errcheck
+func TestFiles_File(t *testing.T) {
+	redisUrl = ""
+	Init()
+
+	id := int64(321)
+
+	SetFile(id, "aaa", ".mrbot.yaml", File{Content: "rules: {}", Exists: true})
+	SetFile(id, "aaa", "CODEOWNERS", File{})
+
+	file, ok, err := GetFile(id, "aaa", ".mrbot.yaml")
+	if err != nil || !ok || !file.Exists || file.Content != "rules: {}" {
+		t.Errorf("unexpected file %v, ok: %v, err: %v", file, ok, err)
+	}
+
+	file, ok, _ = GetFile(id, "aaa", "CODEOWNERS")
+	if !ok || file.Exists {
+		t.Errorf("expected cached missing file, got %v, ok: %v", file, ok)
+	}
+
+	if _, ok, _ := GetFile(id, "bbb", ".mrbot.yaml"); ok {
+		t.Errorf("files of another commit must not be found")
+	}
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	historyPrefix = "mergebot:history"
+	historyTTL    = time.Hour * 24
+)
+
+// historyKey depends on days because the history is loaded for the period
+func historyKey(id int64, path string, days int) string {
+	return fmt.Sprintf("%s:%d:%d:%s", historyPrefix, id, days, path)
+}
+
+// GetPathHistory decodes cached contributions to the path into v
+func GetPathHistory(id int64, path string, days int, v any) (bool, error) {
+	return getJson("history", historyKey(id, path, days), v)
+}
+
+func SetPathHistory(id int64, path string, days int, v any) error {
+	if err := setJson(historyKey(id, path, days), v, historyTTL); err != nil {
+		return fmt.Errorf("can't save history err: %w", err)
+	}
+
+	return nil
+}
@@ -3,14 +3,20 @@ package cache
 import (
 	"fmt"
 	"maps"
+	"slices"
 	"sync"
 	"time"
 )
 
+// pruneInterval is how often writes delete expired keys, lookups only skip them
+const pruneInterval = time.Minute
+
 type MemCache struct {
 	mu           sync.Mutex
 	locks        map[string]bool
 	keys         map[string]any
+	expires      map[string]time.Time
+	prunedAt     time.Time
 	memcacheLock sync.RWMutex
 }
 
@@ -22,7 +28,7 @@ func (m *MemCache) JsonAdd(key string, item string, v int) error {
 	m.memcacheLock.Lock()
 	defer m.memcacheLock.Unlock()
 
-	data, ok := m.keys[key]
+	data, ok := m.lookup(key)
 	if !ok {
 		return fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
@@ -44,7 +50,7 @@ func (m *MemCache) JsonGet(key string) ([]int64, error) {
 	m.memcacheLock.RLock()
 	defer m.memcacheLock.RUnlock()
 
-	val, ok := m.keys[key]
+	val, ok := m.lookup(key)
 	if !ok || val == nil {
 		return nil, nil
 	}
@@ -63,7 +69,7 @@ func (m *MemCache) JsonGetMap(key string) (map[string]int, error) {
 	m.memcacheLock.RLock()
 	defer m.memcacheLock.RUnlock()
 
-	val, ok := m.keys[key]
+	val, ok := m.lookup(key)
 	if !ok || val == nil {
 		return nil, nil
 	}
@@ -82,7 +88,7 @@ func (m *MemCache) JsonIncr(key, item string, v int) (bool, error) {
 	m.memcacheLock.Lock()
 	defer m.memcacheLock.Unlock()
 
-	data, ok := m.keys[key]
+	data, ok := m.lookup(key)
 	if !ok {
 		return false, fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
@@ -108,7 +114,7 @@ func (m *MemCache) JsonExists(key, item string) (bool, error) {
 	m.memcacheLock.RLock()
 	defer m.memcacheLock.RUnlock()
 
-	data, ok := m.keys[key]
+	data, ok := m.lookup(key)
 	if !ok {
 		return false, nil
 	}
@@ -122,10 +128,127 @@ func (m *MemCache) JsonExists(key, item string) (bool, error) {
 	return exists, nil
 }
 
+func (m *MemCache) StringSet(key, value string, ttl time.Duration) error {
+	m.memcacheLock.Lock()
+	defer m.memcacheLock.Unlock()
+
+	if m.keys == nil {
+		m.keys = make(map[string]any)
+	}
+
+	if m.expires == nil {
+		m.expires = make(map[string]time.Time)
+	}
+
+	m.prune()
+	m.keys[key] = value
+	m.expires[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *MemCache) StringGet(key string) (string, bool, error) {
+	m.memcacheLock.RLock()
+	defer m.memcacheLock.RUnlock()
+
+	val, ok := m.lookup(key)
+	if !ok || val == nil {
+		return "", false, nil
+	}
+
+	data, ok := val.(string)
+	if !ok {
+		return "", false, fmt.Errorf("%w: expected string for key %s", ErrWrongType, key)
+	}
+
+	return data, true, nil
+}
+
+func (m *MemCache) Delete(key string) error {
+	m.memcacheLock.Lock()
+	defer m.memcacheLock.Unlock()
+
+	delete(m.keys, key)
+	delete(m.expires, key)
+	return nil
+}
+
 func (m *MemCache) ExtendTTL(key string, ttl time.Duration) error {
+	m.memcacheLock.Lock()
+	defer m.memcacheLock.Unlock()
+
+	if _, ok := m.lookup(key); !ok {
+		return nil
+	}
+
+	if m.expires == nil {
+		m.expires = make(map[string]time.Time)
+	}
+
+	m.expires[key] = time.Now().Add(ttl)
 	return nil
 }
 
+func (m *MemCache) ListPush(key, value string, maxLen int) error {
+	m.memcacheLock.Lock()
+	defer m.memcacheLock.Unlock()
+
+	if m.keys == nil {
+		m.keys = make(map[string]any)
+	}
+
+	m.prune()
+
+	list := []string{}
+	if val, ok := m.lookup(key); ok {
+		data, ok := val.([]string)
+		if !ok {
+			return fmt.Errorf("%w: expected []string for key %s", ErrWrongType, key)
+		}
+		list = data
+	} else {
+		delete(m.expires, key)
+	}
+
+	list = append(list, value)
+	if len(list) > maxLen {
+		list = slices.Clone(list[len(list)-maxLen:])
+	}
+
+	m.keys[key] = list
+	return nil
+}
+
+func (m *MemCache) ListRange(key string) ([]string, error) {
+	m.memcacheLock.RLock()
+	defer m.memcacheLock.RUnlock()
+
+	val, ok := m.lookup(key)
+	if !ok || val == nil {
+		return nil, nil
+	}
+
+	data, ok := val.([]string)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected []string for key %s", ErrWrongType, key)
+	}
+
+	return slices.Clone(data), nil
+}
+
+// lookup returns the value of the key unless it is expired, the caller must hold memcacheLock
+func (m *MemCache) lookup(key string) (any, bool) {
+	val, ok := m.keys[key]
+	if !ok {
+		return nil, false
+	}
+
+	if expiresAt, ok := m.expires[key]; ok && time.Now().After(expiresAt) {
+		return nil, false
+	}
+
+	return val, true
+}
+
 func (m *MemCache) set(key string, val any) error {
 	m.memcacheLock.Lock()
 	defer m.memcacheLock.Unlock()
@@ -134,10 +257,29 @@ func (m *MemCache) set(key string, val any) error {
 		m.keys = make(map[string]any)
 	}
 
+	m.prune()
 	m.keys[key] = val
+	delete(m.expires, key)
 	return nil
 }
 
+// prune deletes expired keys once in pruneInterval, the caller must hold memcacheLock for writing
+func (m *MemCache) prune() {
+	now := time.Now()
+	if now.Sub(m.prunedAt) < pruneInterval {
+		return
+	}
+
+	m.prunedAt = now
+
+	for key, expiresAt := range m.expires {
+		if now.After(expiresAt) {
+			delete(m.keys, key)
+			delete(m.expires, key)
+		}
+	}
+}
+
 func (m *MemCache) Connect() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
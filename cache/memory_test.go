@@ -3,6 +3,7 @@ package cache
 import (
 	"sync"
 	"testing"
+	"time"
 )
 
 //nolint:errcheck
@@ -152,3 +153,102 @@ func TestMemCache_PanicCheck(t *testing.T) {
 		t.Errorf("JsonGetMap should return error for wrong type, not nil. got res: %v", res)
 	}
 }
+
+//nolint:errcheck
+func TestMemCache_String(t *testing.T) {
+	m := &MemCache{}
+	m.Connect()
+
+	m.StringSet("config", "rules: {}", time.Minute)
+	val, ok, err := m.StringGet("config")
+	if err != nil || !ok || val != "rules: {}" {
+		t.Errorf("StringGet returns %q, %v, %v", val, ok, err)
+	}
+
+	m.StringSet("expired", "value", -time.Second)
+	if _, ok, _ := m.StringGet("expired"); ok {
+		t.Error("expired value should not be returned")
+	}
+
+	m.Delete("config")
+	if _, ok, _ := m.StringGet("config"); ok {
+		t.Error("deleted value should not be returned")
+	}
+
+	m.JsonSet("wrong_type", []int64{1})
+	if _, _, err := m.StringGet("wrong_type"); err == nil {
+		t.Error("StringGet should return error for wrong type")
+	}
+}
+
+//nolint:errcheck
+func TestMemCache_Prune(t *testing.T) {
+	m := &MemCache{}
+	m.Connect()
+
+	m.StringSet("expired", "value", -time.Second)
+	m.StringSet("fresh", "value", time.Minute)
+	if _, ok := m.keys["expired"]; !ok {
+		t.Error("expired key should be kept until the next prune")
+	}
+
+	m.prunedAt = time.Time{}
+	m.JsonSet("counts", map[string]int{"item": 1})
+
+	if _, ok := m.keys["expired"]; ok {
+		t.Error("expired key should be deleted")
+	}
+
+	if _, ok := m.expires["expired"]; ok {
+		t.Error("expiration of deleted key should be deleted")
+	}
+
+	if _, ok, _ := m.StringGet("fresh"); !ok {
+		t.Error("fresh key should be kept")
+	}
+}
+
+//nolint:errcheck
+func TestMemCache_ExtendTTL(t *testing.T) {
+	m := &MemCache{}
+	m.Connect()
+
+	m.JsonSet("counts", map[string]int{"item": 1})
+	m.ExtendTTL("counts", -time.Second)
+	if res, _ := m.JsonGetMap("counts"); res != nil {
+		t.Errorf("expired value should not be returned, got %v", res)
+	}
+
+	// a missing key gets no TTL, like in redis
+	m.ExtendTTL("missing", -time.Second)
+	m.JsonSet("missing", []int64{1})
+	if res, _ := m.JsonGet("missing"); len(res) != 1 {
+		t.Errorf("expected value without TTL, got %v", res)
+	}
+}
+
+//nolint:errcheck
+func TestMemCache_List(t *testing.T) {
+	m := &MemCache{}
+	m.Connect()
+
+	for _, v := range []string{"a", "b", "c"} {
+		m.ListPush("log", v, 2)
+	}
+
+	res, err := m.ListRange("log")
+	if err != nil || len(res) != 2 || res[0] != "b" || res[1] != "c" {
+		t.Errorf("ListRange returns %v, %v", res, err)
+	}
+
+	m.ExtendTTL("log", -time.Second)
+	if res, _ := m.ListRange("log"); res != nil {
+		t.Errorf("expired list should not be returned, got %v", res)
+	}
+
+	// a new list starts after the old one is expired
+	m.ListPush("log", "d", 2)
+	if res, _ := m.ListRange("log"); len(res) != 1 || res[0] != "d" {
+		t.Errorf("expected new list, got %v", res)
+	}
+}
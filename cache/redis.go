@@ -176,6 +176,58 @@ func (r *RedisCache) JsonIncr(key, item string, v int) (bool, error) {
 	return true, nil
 }
 
+func (r *RedisCache) StringSet(key, value string, ttl time.Duration) error {
+	if _, err := r.client.Set(context.TODO(), key, value, ttl).Result(); err != nil {
+		return &CacheError{Operation: "StringSet", Err: err}
+	}
+
+	return nil
+}
+
+func (r *RedisCache) StringGet(key string) (string, bool, error) {
+	val, err := r.client.Get(context.TODO(), key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, &CacheError{Operation: "StringGet", Err: err}
+	}
+
+	return val, true, nil
+}
+
+func (r *RedisCache) Delete(key string) error {
+	if _, err := r.client.Del(context.TODO(), key).Result(); err != nil {
+		return &CacheError{Operation: "Delete", Err: err}
+	}
+
+	return nil
+}
+
+func (r *RedisCache) ListPush(key, value string, maxLen int) error {
+	pipe := r.client.TxPipeline()
+	pipe.RPush(context.TODO(), key, value)
+	pipe.LTrim(context.TODO(), key, int64(-maxLen), -1)
+
+	if _, err := pipe.Exec(context.TODO()); err != nil {
+		return &CacheError{Operation: "ListPush", Err: err}
+	}
+
+	return nil
+}
+
+func (r *RedisCache) ListRange(key string) ([]string, error) {
+	val, err := r.client.LRange(context.TODO(), key, 0, -1).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, &CacheError{Operation: "ListRange", Err: err}
+	}
+
+	return val, nil
+}
+
 func (r *RedisCache) AcquireLease(key string) bool {
 	_, err := r.client.SetArgs(context.TODO(), key, true, redis.SetArgs{Mode: "NX", TTL: lockTTL}).Result()
 	if err == nil {
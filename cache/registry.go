@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	registryKey        = "mergebot:registry:projects"
+	registrySeenPrefix = "mergebot:registry:seen"
+	// registryKnownPrefix marks projects which have been registered, they aren't new when they come back
+	registryKnownPrefix = "mergebot:registry:known"
+	// registrySeenTTL is how often a project is written to the registry while it sends webhooks
+	registrySeenTTL = time.Hour * 24
+	registryTTL     = time.Hour * 24 * 365
+	maxRegistrySize = 10000
+
+	runsPrefix     = "mergebot:scheduler:runs"
+	runsLockPrefix = "mergebot:scheduler:locks"
+	runsTTL        = time.Hour * 24 * 30
+)
+
+// RegisteredProject is a project the bot has received webhooks from
+type RegisteredProject struct {
+	Provider string    `json:"provider"`
+	ID       int64     `json:"id"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+func registrySeenKey(provider string, id int64) string {
+	return fmt.Sprintf("%s:%s:%d", registrySeenPrefix, provider, id)
+}
+
+func registryKnownKey(provider string, id int64) string {
+	return fmt.Sprintf("%s:%s:%d", registryKnownPrefix, provider, id)
+}
+
+// RegisterProject adds the project to the registry, it is written at most once a day.
+// It returns true if the project has been registered for the first time
+func RegisterProject(provider string, id int64) (bool, error) {
+	_, ok, err := contributors.StringGet(registrySeenKey(provider, id))
+	if err != nil || ok {
+		return false, err
+	}
+
+	_, known, err := contributors.StringGet(registryKnownKey(provider, id))
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+
+	data, err := json.Marshal(RegisteredProject{Provider: provider, ID: id, SeenAt: now})
+	if err != nil {
+		return false, err
+	}
+
+	if err := contributors.ListPush(registryKey, string(data), maxRegistrySize); err != nil {
+		return false, fmt.Errorf("can't register project err: %w", err)
+	}
+
+	if err := contributors.ExtendTTL(registryKey, registryTTL); err != nil {
+		return false, err
+	}
+
+	if err := contributors.StringSet(registryKnownKey(provider, id), now.Format(time.RFC3339), registryTTL); err != nil {
+		return false, err
+	}
+
+	return !known, contributors.StringSet(registrySeenKey(provider, id), now.Format(time.RFC3339), registrySeenTTL)
+}
+
+// ListProjects returns projects seen since the time, every project once
+func ListProjects(since time.Time) ([]RegisteredProject, error) {
+	values, err := contributors.ListRange(registryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	type projectKey struct {
+		provider string
+		id       int64
+	}
+
+	index := map[projectKey]int{}
+	projects := []RegisteredProject{}
+
+	for _, v := range values {
+		p := RegisteredProject{}
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			logger.Debug("registered project can't be decoded", "value", v, "err", err)
+			continue
+		}
+
+		key := projectKey{p.Provider, p.ID}
+		if i, ok := index[key]; ok {
+			projects[i].SeenAt = p.SeenAt
+			continue
+		}
+
+		index[key] = len(projects)
+		projects = append(projects, p)
+	}
+
+	result := projects[:0]
+	for _, p := range projects {
+		if !p.SeenAt.Before(since) {
+			result = append(result, p)
+		}
+	}
+
+	return result, nil
+}
+
+// ClaimRun returns true if the run of the task at the time hasn't been claimed by any replica yet
+func ClaimRun(task string, at time.Time) (bool, error) {
+	lock := fmt.Sprintf("%s:%s", runsLockPrefix, task)
+	if !contributors.AcquireLease(lock) {
+		return false, nil
+	}
+
+	defer contributors.ReleaseLease(lock)
+
+	key := fmt.Sprintf("%s:%s", runsPrefix, task)
+
+	last, ok, err := contributors.StringGet(key)
+	if err != nil {
+		return false, err
+	}
+
+	if ok {
+		lastRun, err := time.Parse(time.RFC3339, last)
+		if err == nil && !lastRun.Before(at) {
+			return false, nil
+		}
+	}
+
+	if err := contributors.StringSet(key, at.Format(time.RFC3339), runsTTL); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterProject(t *testing.T) {
+	redisUrl = ""
+	if err := Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	before := time.Now().Add(-time.Second)
+
+	for _, tt := range []struct {
+		id      int64
+		wantNew bool
+	}{{1, true}, {2, true}, {1, false}} {
+		isNew, err := RegisterProject("gitlab", tt.id)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantNew, isNew, "project %d", tt.id)
+	}
+
+	// the daily mark expires, the project is written again, but it isn't new
+	assert.NoError(t, contributors.Delete(registrySeenKey("gitlab", 1)))
+	isNew, err := RegisterProject("gitlab", 1)
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+
+	projects, err := ListProjects(before)
+	assert.NoError(t, err)
+
+	ids := []int64{}
+	for _, p := range projects {
+		assert.Equal(t, "gitlab", p.Provider)
+		ids = append(ids, p.ID)
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	projects, err = ListProjects(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, projects)
+}
+
+func TestClaimRun(t *testing.T) {
+	redisUrl = ""
+	if err := Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task string
+		at   time.Time
+		want bool
+	}{
+		{"first run", "labels", at, true},
+		{"same run", "labels", at, false},
+		{"earlier run", "labels", at.Add(-time.Hour), false},
+		{"next run", "labels", at.Add(time.Hour), true},
+		{"another task", "stale-branches", at, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := ClaimRun(tt.task, tt.at)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	remindersPrefix = "mergebot:reminders"
+	remindersTTL    = time.Hour * 24 * 30
+)
+
+func remindersKey(id, mergeID int64) string {
+	return fmt.Sprintf("%s:%d:%d", remindersPrefix, id, mergeID)
+}
+
+// GetReminders decodes reminders sent to reviewers of MR into v
+func GetReminders(id, mergeID int64, v any) (bool, error) {
+	return getJson("reminders", remindersKey(id, mergeID), v)
+}
+
+func SetReminders(id, mergeID int64, v any) error {
+	if err := setJson(remindersKey(id, mergeID), v, remindersTTL); err != nil {
+		return fmt.Errorf("can't save reminders err: %w", err)
+	}
+
+	return nil
+}
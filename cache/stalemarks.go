@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	staleMarksPrefix = "mergebot:stale-marks"
+	staleMarksTTL    = time.Hour * 24 * 365
+	snoozesPrefix    = "mergebot:snoozes"
+)
+
+func staleMarkKey(id, mergeID int64) string {
+	return fmt.Sprintf("%s:%d:%d", staleMarksPrefix, id, mergeID)
+}
+
+func snoozeKey(id, mergeID int64) string {
+	return fmt.Sprintf("%s:%d:%d", snoozesPrefix, id, mergeID)
+}
+
+func getTime(key string) (time.Time, bool, error) {
+	value, ok, err := contributors.StringGet(key)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a time: %w", ErrWrongType, key, err)
+	}
+
+	return t, true, nil
+}
+
+// MarkStale saves the time when MR got the stale label, comments are compared with it, so fractions of a second are kept
+func MarkStale(id, mergeID int64, at time.Time) error {
+	return contributors.StringSet(staleMarkKey(id, mergeID), at.Format(time.RFC3339Nano), staleMarksTTL)
+}
+
+// StaleMarkedAt returns the time when MR got the stale label from the bot
+func StaleMarkedAt(id, mergeID int64) (time.Time, bool, error) {
+	return getTime(staleMarkKey(id, mergeID))
+}
+
+func UnmarkStale(id, mergeID int64) error {
+	return contributors.Delete(staleMarkKey(id, mergeID))
+}
+
+// Snooze exempts MR from the stale cleanup until the time
+func Snooze(id, mergeID int64, until time.Time) error {
+	return contributors.StringSet(snoozeKey(id, mergeID), until.Format(time.RFC3339), time.Until(until))
+}
+
+// SnoozedUntil returns the end of the snooze of MR, false if MR isn't snoozed
+func SnoozedUntil(id, mergeID int64) (time.Time, bool, error) {
+	return getTime(snoozeKey(id, mergeID))
+}
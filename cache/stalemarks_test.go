@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+//nolint:errcheck
+func TestStaleMarks(t *testing.T) {
+	redisUrl = ""
+	Init()
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	if err := MarkStale(1, 2, at); err != nil {
+		t.Fatalf("MarkStale failed: %v", err)
+	}
+
+	got, ok, err := StaleMarkedAt(1, 2)
+	if err != nil || !ok {
+		t.Fatalf("StaleMarkedAt failed: %v, %v", ok, err)
+	}
+
+	// comments of the same second are ordered by fractions
+	if !got.Equal(at) {
+		t.Errorf("expected %v, got %v", at, got)
+	}
+
+	UnmarkStale(1, 2)
+
+	if _, ok, _ := StaleMarkedAt(1, 2); ok {
+		t.Error("mark is expected to be removed")
+	}
+}
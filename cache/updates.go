@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	pendingUpdatesPrefix = "mergebot:update:pending"
+	updatedSHAsPrefix    = "mergebot:update:sha"
+	pendingUpdateTTL     = time.Hour
+	updatedSHATTL        = time.Hour * 24
+)
+
+func pendingUpdateKey(id int64, branch string) string {
+	return fmt.Sprintf("%s:%d:%s", pendingUpdatesPrefix, id, branch)
+}
+
+func updatedSHAKey(id int64, branch string) string {
+	return fmt.Sprintf("%s:%d:%s", updatedSHAsPrefix, id, branch)
+}
+
+// SetPendingUpdate asks the holder of the update lease to update MRs again from the commit, sha may be empty
+func SetPendingUpdate(id int64, branch, sha string) error {
+	return contributors.StringSet(pendingUpdateKey(id, branch), sha, pendingUpdateTTL)
+}
+
+// TakePendingUpdate returns and removes the pending update of MRs into the branch
+func TakePendingUpdate(id int64, branch string) (string, bool, error) {
+	sha, ok, err := contributors.StringGet(pendingUpdateKey(id, branch))
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return sha, true, contributors.Delete(pendingUpdateKey(id, branch))
+}
+
+// UpdatedSHA returns the last commit of the branch which MRs have been updated from
+func UpdatedSHA(id int64, branch string) (string, bool, error) {
+	return contributors.StringGet(updatedSHAKey(id, branch))
+}
+
+func SetUpdatedSHA(id int64, branch, sha string) error {
+	return contributors.StringSet(updatedSHAKey(id, branch), sha, updatedSHATTL)
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	usersPrefix       = "mergebot:users"
+	groupsPrefix      = "mergebot:groups"
+	openReviewsPrefix = "mergebot:open-reviews"
+	emailsPrefix      = "mergebot:emails"
+	userIDsPrefix     = "mergebot:user-ids"
+	usersTTL          = time.Hour * 24
+	groupsTTL         = time.Hour
+	// openReviewsTTL is short, workload changes with every review
+	openReviewsTTL = time.Minute * 5
+)
+
+// User is the profile of the user which matters for the roulette
+type User struct {
+	// Timezone is empty if the user has no timezone
+	Timezone string `json:"timezone"`
+	Bot      bool   `json:"bot"`
+}
+
+func userKey(userID int64) string {
+	return fmt.Sprintf("%s:%d", usersPrefix, userID)
+}
+
+func GetUser(userID int64) (*User, bool, error) {
+	user := &User{}
+	ok, err := getJson("users", userKey(userID), user)
+	if !ok {
+		return nil, false, err
+	}
+
+	return user, true, nil
+}
+
+func SetUser(userID int64, user User) error {
+	if err := setJson(userKey(userID), user, usersTTL); err != nil {
+		return fmt.Errorf("can't save user err: %w", err)
+	}
+
+	return nil
+}
+
+func groupKey(group string) string {
+	return fmt.Sprintf("%s:%s", groupsPrefix, group)
+}
+
+func GetGroupMembers(group string) ([]string, bool, error) {
+	members := []string{}
+	ok, err := getJson("groups", groupKey(group), &members)
+	if !ok {
+		return nil, false, err
+	}
+
+	return members, true, nil
+}
+
+func SetGroupMembers(group string, members []string) error {
+	if err := setJson(groupKey(group), members, groupsTTL); err != nil {
+		return fmt.Errorf("can't save group members err: %w", err)
+	}
+
+	return nil
+}
+
+func openReviewsKey(username string) string {
+	return fmt.Sprintf("%s:%s", openReviewsPrefix, username)
+}
+
+// GetOpenReviews returns the cached number of open MRs awaiting review of the user
+func GetOpenReviews(username string) (int, bool, error) {
+	count := 0
+	ok, err := getJson("open_reviews", openReviewsKey(username), &count)
+	return count, ok, err
+}
+
+func SetOpenReviews(username string, count int) error {
+	if err := setJson(openReviewsKey(username), count, openReviewsTTL); err != nil {
+		return fmt.Errorf("can't save open reviews err: %w", err)
+	}
+
+	return nil
+}
+
+func emailKey(email string) string {
+	return fmt.Sprintf("%s:%s", emailsPrefix, email)
+}
+
+// GetUsernameByEmail returns the cached username of the commit email, it is empty if no user has the email
+func GetUsernameByEmail(email string) (string, bool, error) {
+	username := ""
+	ok, err := getJson("emails", emailKey(email), &username)
+	return username, ok, err
+}
+
+func SetUsernameByEmail(email, username string) error {
+	if err := setJson(emailKey(email), username, usersTTL); err != nil {
+		return fmt.Errorf("can't save username err: %w", err)
+	}
+
+	return nil
+}
+
+func userIDKey(username string) string {
+	return fmt.Sprintf("%s:%s", userIDsPrefix, username)
+}
+
+// GetUserID returns the cached ID of the username, it is 0 if there is no such user
+func GetUserID(username string) (int64, bool, error) {
+	var id int64
+	ok, err := getJson("user-ids", userIDKey(username), &id)
+	return id, ok, err
+}
+
+func SetUserID(username string, id int64) error {
+	if err := setJson(userIDKey(username), id, usersTTL); err != nil {
+		return fmt.Errorf("can't save user id err: %w", err)
+	}
+
+	return nil
+}
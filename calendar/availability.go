@@ -0,0 +1,247 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/logger"
+
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// userCalendarTTL is how long a fetched personal calendar is used before it is fetched again
+	userCalendarTTL = time.Hour
+	// failedCalendarTTL is how long a failure of the fetch is remembered, spins don't wait for the same timeout again
+	failedCalendarTTL = time.Minute * 5
+	fetchTimeout      = time.Second * 10
+	maxCalendarSize   = 5 << 20
+)
+
+var (
+	oooCalendarsDir string
+	oooCalendars    string
+
+	sources   []Source
+	sourcesMu sync.RWMutex
+
+	oooKeywords = []string{"ooo", "out of office", "vacation", "holiday", "leave", "pto", "sick", "travel"}
+
+	ErrFetch = errors.New("calendar can't be fetched")
+)
+
+func init() {
+	config.StringVar(&oooCalendarsDir, "ooo-calendars-dir", "", "directory with personal ICS calendars named <username>.ics, people on vacation are not picked for review (also via OOO_CALENDARS_DIR)")
+	config.StringVar(&oooCalendars, "ooo-calendars", "", "YAML file which maps usernames to URLs or paths of personal ICS calendars (also via OOO_CALENDARS)")
+}
+
+// Source returns the personal calendar of the user, nil if the user has no calendar
+type Source interface {
+	Calendar(username string) (*Calendar, error)
+}
+
+// DirSource reads calendars named <username>.ics from the directory
+type DirSource struct {
+	Dir string
+}
+
+func (s DirSource) Calendar(username string) (*Calendar, error) {
+	if username == "" || filepath.Base(username) != username || username == "." || username == ".." {
+		return nil, fmt.Errorf("%w: %q", ErrPath, username)
+	}
+
+	c, err := loadFile(filepath.Join(s.Dir, username+".ics"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return c, err
+}
+
+type fetchedCalendar struct {
+	fetchedAt time.Time
+	calendar  *Calendar
+	// err is the failure of the last fetch, the previous calendar is kept if there is any
+	err error
+}
+
+func (f fetchedCalendar) expired() bool {
+	ttl := userCalendarTTL
+	if f.err != nil {
+		ttl = failedCalendarTTL
+	}
+
+	return time.Since(f.fetchedAt) >= ttl
+}
+
+// UserSource reads calendars listed per user, calendars are URLs or file paths
+type UserSource struct {
+	calendars map[string]string
+	client    *http.Client
+
+	// fetches of the same URL are shared, fetches of different URLs don't wait for each other
+	fetches singleflight.Group
+	mu      sync.Mutex
+	fetched map[string]fetchedCalendar
+}
+
+func NewUserSource(calendars map[string]string) *UserSource {
+	return &UserSource{
+		calendars: calendars,
+		client:    &http.Client{Timeout: fetchTimeout},
+		fetched:   map[string]fetchedCalendar{},
+	}
+}
+
+func (s *UserSource) Calendar(username string) (*Calendar, error) {
+	location, ok := s.calendars[username]
+	if !ok {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return loadFile(location)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.fetched[location]
+	s.mu.Unlock()
+
+	if !ok || cached.expired() {
+		v, _, _ := s.fetches.Do(location, func() (any, error) {
+			return s.refresh(username, location), nil
+		})
+		cached = v.(fetchedCalendar)
+	}
+
+	if cached.calendar != nil {
+		return cached.calendar, nil
+	}
+
+	return nil, cached.err
+}
+
+// refresh fetches the calendar and remembers the result, the previous calendar is used while the URL fails
+func (s *UserSource) refresh(username, location string) fetchedCalendar {
+	c, err := s.fetch(location)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := fetchedCalendar{fetchedAt: time.Now(), calendar: c, err: err}
+	if err != nil {
+		result.calendar = s.fetched[location].calendar
+		if result.calendar != nil {
+			logger.Info("calendar can't be fetched, the previous version is used", "username", username, "err", err)
+		}
+	}
+
+	s.fetched[location] = result
+	return result
+}
+
+func (s *UserSource) fetch(url string) (*Calendar, error) {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returns %s", ErrFetch, url, resp.Status)
+	}
+
+	return Parse(io.LimitReader(resp.Body, maxCalendarSize))
+}
+
+// Init sets up sources of personal calendars given by flags
+func Init() error {
+	list := []Source{}
+
+	if oooCalendarsDir != "" {
+		list = append(list, DirSource{Dir: oooCalendarsDir})
+	}
+
+	if oooCalendars != "" {
+		b, err := os.ReadFile(oooCalendars)
+		if err != nil {
+			return err
+		}
+
+		calendars := map[string]string{}
+		if err := yaml.Unmarshal(b, &calendars); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrFormat, oooCalendars, err)
+		}
+
+		list = append(list, NewUserSource(calendars))
+	}
+
+	SetSources(list...)
+	return nil
+}
+
+func SetSources(list ...Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	sources = list
+}
+
+// OutOfOffice looks up the vacation of the user in personal calendars, t should be in the user's timezone
+func OutOfOffice(username string, t time.Time) (Event, bool) {
+	sourcesMu.RLock()
+	list := sources
+	sourcesMu.RUnlock()
+
+	for _, s := range list {
+		c, err := s.Calendar(username)
+		if err != nil {
+			logger.Info("personal calendar can't be loaded", "username", username, "err", err)
+			continue
+		}
+
+		if c == nil {
+			continue
+		}
+
+		if e, ok := c.OutOfOffice(t); ok {
+			return e, true
+		}
+	}
+
+	return Event{}, false
+}
+
+func isVacation(summary string) bool {
+	summary = strings.ToLower(summary)
+	return slices.ContainsFunc(oooKeywords, func(k string) bool {
+		return strings.Contains(summary, k)
+	})
+}
+
+// OutOfOffice returns the vacation event at the time: all-day events with vacation-like summary or any events marked as OOF
+func (c *Calendar) OutOfOffice(t time.Time) (Event, bool) {
+	for _, e := range c.Events {
+		covers := e.AllDay && e.dayIn(t) || !e.AllDay && !t.Before(e.Start) && t.Before(e.End)
+		if !covers {
+			continue
+		}
+
+		if e.BusyStatus == "OOF" || e.AllDay && isVacation(e.Summary) {
+			return e, true
+		}
+	}
+
+	return Event{}, false
+}
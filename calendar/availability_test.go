@@ -0,0 +1,162 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const vacations = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261019\r\n" +
+	"DTEND;VALUE=DATE:20261024\r\n" +
+	"SUMMARY:Vacation\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261026\r\n" +
+	"SUMMARY:Team offsite\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20261027T120000Z\r\n" +
+	"DTEND:20261027T180000Z\r\n" +
+	"SUMMARY:Doctor\r\n" +
+	"X-MICROSOFT-CDO-BUSYSTATUS:OOF\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestCalendar_OutOfOffice(t *testing.T) {
+	c, err := Parse(strings.NewReader(vacations))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"vacation", time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC), "Vacation"},
+		{"vacation ends", time.Date(2026, 10, 24, 10, 0, 0, 0, time.UTC), ""},
+		{"all-day event is not a vacation", time.Date(2026, 10, 26, 10, 0, 0, 0, time.UTC), ""},
+		{"out of office event", time.Date(2026, 10, 27, 13, 0, 0, 0, time.UTC), "Doctor"},
+		{"out of office event ends", time.Date(2026, 10, 27, 18, 0, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := c.OutOfOffice(tt.t)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, e.Summary)
+		})
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "alice.ics"), []byte(vacations), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	s := DirSource{Dir: dir}
+
+	c, err := s.Calendar("alice")
+	assert.NoError(t, err)
+	assert.Len(t, c.Events, 3)
+
+	c, err = s.Calendar("bob")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = s.Calendar("../alice")
+	assert.ErrorIs(t, err, ErrPath)
+}
+
+func TestUserSource(t *testing.T) {
+	var (
+		requests  atomic.Int32
+		available atomic.Bool
+	)
+	available.Store(true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !available.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(vacations))
+	}))
+	defer server.Close()
+
+	s := NewUserSource(map[string]string{"alice": server.URL + "/alice.ics", "bob": server.URL + "/bob.ics"})
+
+	// concurrent spins share the fetch
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			c, err := s.Calendar("alice")
+			assert.NoError(t, err)
+			assert.Len(t, c.Events, 3)
+		})
+	}
+	wg.Wait()
+
+	_, err := s.Calendar("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "calendar should be cached")
+
+	// expired calendar is used while the server is unavailable
+	available.Store(false)
+	s.fetched[server.URL+"/alice.ics"] = fetchedCalendar{fetchedAt: time.Now().Add(-2 * userCalendarTTL), calendar: &Calendar{}}
+	c, err := s.Calendar("alice")
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+
+	// the failure is remembered
+	requests.Store(0)
+	for range 2 {
+		_, err = s.Calendar("bob")
+		assert.ErrorIs(t, err, ErrFetch)
+	}
+	assert.Equal(t, int32(1), requests.Load(), "failure should be cached")
+
+	c, err = s.Calendar("carol")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func TestOutOfOffice(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "alice.ics"), []byte(vacations), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	oooCalendars = filepath.Join(dir, "calendars.yaml")
+	defer func() { oooCalendars = "" }()
+
+	if err := os.WriteFile(oooCalendars, []byte("bob: "+filepath.Join(dir, "alice.ics")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	assert.NoError(t, Init())
+	defer SetSources()
+
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	e, ok := OutOfOffice("bob", monday)
+	assert.True(t, ok)
+	assert.Equal(t, "Vacation", e.Summary)
+
+	_, ok = OutOfOffice("alice", monday)
+	assert.False(t, ok, "ooo-calendars-dir is not set")
+
+	if err := os.WriteFile(oooCalendars, []byte("bob: [broken"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	assert.ErrorIs(t, Init(), ErrFormat)
+}
@@ -0,0 +1,242 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/config"
+
+	// alpine image has no zoneinfo
+	_ "time/tzdata"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+var (
+	calendarsDir string
+
+	cache   = map[string]cachedCalendar{}
+	cacheMu sync.Mutex
+
+	ErrPath   = errors.New("calendar name must be a file name inside calendars dir")
+	ErrFormat = errors.New("calendar can't be parsed")
+)
+
+func init() {
+	config.StringVar(&calendarsDir, "calendars-dir", "", "directory with ICS calendars which can be referenced from .mrbot.yaml (also via CALENDARS_DIR)")
+}
+
+type Event struct {
+	Summary string
+	Start   time.Time
+	// End is exclusive like DTEND in ICS
+	End    time.Time
+	AllDay bool
+	Yearly bool
+	// BusyStatus is OOF for out of office events of Outlook calendars
+	BusyStatus string
+}
+
+type Calendar struct {
+	Events []Event
+}
+
+type cachedCalendar struct {
+	modTime  time.Time
+	calendar *Calendar
+}
+
+// unfold joins continuation lines, ICS lines longer than 75 octets are folded with a leading space
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseTime parses DTSTART/DTEND values, dates are all-day events in the zone of the reader
+func parseTime(params, value string) (time.Time, bool, error) {
+	if strings.Contains(params, "VALUE=DATE") || len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeLayout, strings.TrimSuffix(value, "Z"))
+		return t, false, err
+	}
+
+	loc := time.UTC
+	for p := range strings.SplitSeq(params, ";") {
+		if name, ok := strings.CutPrefix(p, "TZID="); ok {
+			if l, err := time.LoadLocation(name); err == nil {
+				loc = l
+			}
+		}
+	}
+
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	return t, false, err
+}
+
+func Parse(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calendar{}
+	var event *Event
+
+	for i, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		name, params, _ := strings.Cut(name, ";")
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if value == "VEVENT" {
+				event = &Event{}
+			}
+
+		case "END":
+			if value != "VEVENT" || event == nil {
+				continue
+			}
+
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("%w: line %d: event has no DTSTART", ErrFormat, i+1)
+			}
+
+			if event.End.IsZero() {
+				event.End = event.Start
+				if event.AllDay {
+					event.End = event.Start.AddDate(0, 0, 1)
+				}
+			}
+
+			c.Events = append(c.Events, *event)
+			event = nil
+
+		case "SUMMARY":
+			if event != nil {
+				event.Summary = value
+			}
+
+		case "DTSTART", "DTEND":
+			if event == nil {
+				continue
+			}
+
+			t, allDay, err := parseTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrFormat, i+1, err)
+			}
+
+			if name == "DTSTART" {
+				event.Start, event.AllDay = t, allDay
+			} else {
+				event.End = t
+			}
+
+		case "X-MICROSOFT-CDO-BUSYSTATUS":
+			if event != nil {
+				event.BusyStatus = strings.ToUpper(value)
+			}
+
+		case "RRULE":
+			if event != nil && strings.Contains(value, "FREQ=YEARLY") {
+				event.Yearly = true
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// Load reads the calendar by its file name inside calendars dir, parsed calendars are kept until the file changes
+func Load(name string) (*Calendar, error) {
+	if calendarsDir == "" || name == "" || filepath.Base(name) != name || name == "." || name == ".." {
+		return nil, fmt.Errorf("%w: %q", ErrPath, name)
+	}
+
+	return loadFile(filepath.Join(calendarsDir, name))
+}
+
+// loadFile parses the calendar file, parsed calendars are kept until the file changes
+func loadFile(path string) (*Calendar, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if cached, ok := cache[path]; ok && cached.modTime.Equal(stat.ModTime()) {
+		return cached.calendar, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	cache[path] = cachedCalendar{modTime: stat.ModTime(), calendar: c}
+	return c, nil
+}
+
+// dayIn checks if the all-day event covers the date, yearly events are moved to the year of the date
+func (e Event) dayIn(date time.Time) bool {
+	start, end := e.Start, e.End
+	if e.Yearly {
+		shift := date.Year() - start.Year()
+		if shift < 0 {
+			return false
+		}
+		start, end = start.AddDate(shift, 0, 0), end.AddDate(shift, 0, 0)
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return !day.Before(start) && day.Before(end)
+}
+
+// Holiday returns the all-day event of the local date of t
+func (c *Calendar) Holiday(t time.Time) (Event, bool) {
+	for _, e := range c.Events {
+		if e.AllDay && e.dayIn(t) {
+			return e, true
+		}
+	}
+
+	return Event{}, false
+}
@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const holidays = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20241225\r\n" +
+	"DTEND;VALUE=DATE:20241227\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"  holidays\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261003\r\n" +
+	"SUMMARY:German Unity Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20261005T090000Z\r\n" +
+	"DTEND:20261005T100000Z\r\n" +
+	"SUMMARY:Meeting\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(holidays))
+	assert.NoError(t, err)
+	assert.Len(t, c.Events, 3)
+	assert.Equal(t, "Christmas holidays", c.Events[0].Summary)
+	assert.True(t, c.Events[0].Yearly)
+	assert.Equal(t, time.Date(2026, 10, 4, 0, 0, 0, 0, time.UTC), c.Events[1].End)
+	assert.False(t, c.Events[2].AllDay)
+
+	_, err = Parse(strings.NewReader("BEGIN:VEVENT\nSUMMARY:broken\nEND:VEVENT\n"))
+	assert.ErrorIs(t, err, ErrFormat)
+}
+
+func TestCalendar_Holiday(t *testing.T) {
+	c, err := Parse(strings.NewReader(holidays))
+	assert.NoError(t, err)
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"yearly event", time.Date(2026, 12, 26, 10, 0, 0, 0, time.UTC), "Christmas holidays"},
+		{"yearly event ends", time.Date(2026, 12, 27, 10, 0, 0, 0, time.UTC), ""},
+		{"single day", time.Date(2026, 10, 3, 23, 30, 0, 0, berlin), "German Unity Day"},
+		{"single day ends", time.Date(2026, 10, 4, 0, 30, 0, 0, berlin), ""},
+		{"timed events are not holidays", time.Date(2026, 10, 5, 9, 30, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := c.Holiday(tt.t)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, e.Summary)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	calendarsDir = t.TempDir()
+	defer func() { calendarsDir = "" }()
+
+	if err := os.WriteFile(filepath.Join(calendarsDir, "de.ics"), []byte(holidays), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	c, err := Load("de.ics")
+	assert.NoError(t, err)
+	assert.Len(t, c.Events, 3)
+
+	for _, name := range []string{"../de.ics", "/etc/passwd", "", ".."} {
+		_, err := Load(name)
+		assert.ErrorIs(t, err, ErrPath, name)
+	}
+
+	_, err = Load("missing.ics")
+	assert.Error(t, err)
+}
@@ -19,46 +19,27 @@ func init() {
 	handle("!update", UpdateBranchCmd)
 	handle("!rerun", RerunPipelineCmd)
 	handle("!spin", ReviewRouletteCmd)
+	handle("!roulette", RouletteCmd)
+	handle("!stale", StaleCmd)
+	handle("!restore-branch", RestoreBranchCmd)
+	handle("!snooze", SnoozeCmd)
+	handle("!config", ConfigCmd)
 	handle(webhook.OnNewMR, NewMREvent)
 	handle(webhook.OnMerge, MergeEvent)
 	handle(webhook.OnUpdate, UpdateEvent)
 	handle(webhook.OnCommit, PushEvent)
+	handle(webhook.OnPipeline, PipelineEvent)
+	handle(webhook.OnBranchPush, BranchPushEvent)
 }
 
 const success = "You can merge, LGTM :D"
 
 func UpdateBranchCmd(command *handlers.Request, args string) error {
-	const (
-		mergeText = `
-🛠️ I failed to merge %s into your branch, you have to resolve conflicts manually
-
-<details>
-<summary>
-How to merge branch manually:
-</summary>
-<pre><code>git checkout %s
-git pull origin
-git checkout %s
-git merge %s
-# resolve conflicts
-git push origin</code></pre>
-
-</details>
-`
-	)
 	if err := command.UpdateFromMaster(); err != nil {
 		logger.Info("command.UpdateFromMaster failed", "error", err)
 		mergeError := &handlers.MergeError{}
 		if errors.As(err, &mergeError) {
-			text := fmt.Sprintf(
-				mergeText,
-				mergeError.DestinationBranch,
-				mergeError.DestinationBranch,
-				mergeError.SourceBranch,
-				mergeError.DestinationBranch,
-			)
-
-			return command.LeaveComment(text)
+			return command.LeaveComment(mergeError.Comment())
 		}
 
 		return command.LeaveComment("❌ i couldn't update the branch from the destination")
@@ -92,33 +73,129 @@ func CheckCmd(command *handlers.Request, args string) error {
 	return command.LeaveComment(text)
 }
 
+const (
+	spinReroll = "reroll"
+	spinAdd    = "add"
+	spinUsage  = "> [!important]\n> Arguments are invalid, usage: `!spin [number]`, `!spin reroll @user` or `!spin add [number]`, each of them accepts `--exclude @user1,@user2`"
+)
+
+var errSpinArgs = errors.New("spin arguments are invalid")
+
+type spinArgs struct {
+	action  string
+	num     int
+	user    string
+	exclude []string
+}
+
+func parseUsernames(s string) []string {
+	usernames := []string{}
+	for u := range strings.SplitSeq(s, ",") {
+		if u = strings.TrimPrefix(strings.TrimSpace(u), "@"); u != "" {
+			usernames = append(usernames, u)
+		}
+	}
+
+	return usernames
+}
+
+// parseSpinArgs parses `[number]`, `reroll @user` or `add [number]` with optional `--exclude @user1,@user2`
+func parseSpinArgs(args string) (spinArgs, error) {
+	result := spinArgs{}
+	positional := []string{}
+
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--exclude" && i+1 < len(fields):
+			i++
+			value := fields[i]
+			// tolerate spaces after commas: --exclude @a, @b
+			for strings.HasSuffix(value, ",") && i+1 < len(fields) {
+				i++
+				value += fields[i]
+			}
+			result.exclude = append(result.exclude, parseUsernames(value)...)
+		case strings.HasPrefix(fields[i], "--exclude="):
+			result.exclude = append(result.exclude, parseUsernames(strings.TrimPrefix(fields[i], "--exclude="))...)
+		case strings.HasPrefix(fields[i], "--"):
+			return result, fmt.Errorf("%w: unknown option %s", errSpinArgs, fields[i])
+		default:
+			positional = append(positional, fields[i])
+		}
+	}
+
+	if len(positional) > 0 && (positional[0] == spinReroll || positional[0] == spinAdd) {
+		result.action = positional[0]
+		positional = positional[1:]
+	}
+
+	switch {
+	case result.action == spinReroll:
+		if len(positional) != 1 {
+			return result, fmt.Errorf("%w: reroll needs one user", errSpinArgs)
+		}
+		result.user = strings.TrimPrefix(positional[0], "@")
+	case len(positional) > 1:
+		return result, fmt.Errorf("%w: too many arguments", errSpinArgs)
+	case len(positional) == 1:
+		num, err := strconv.Atoi(positional[0])
+		if err != nil || num < 0 {
+			return result, fmt.Errorf("%w: number of players is invalid", errSpinArgs)
+		}
+		result.num = num
+	}
+
+	return result, nil
+}
+
+func leaveRouletteResult(command *handlers.Request, result *handlers.RouletteResult) error {
+	if len(result.Winners) == 0 {
+		return command.LeaveComment("🎲 No available players")
+	}
+
+	return command.LeaveComment(result.String())
+}
+
 func ReviewRouletteCmd(command *handlers.Request, args string) error {
-	arg := strings.TrimSpace(args)
+	spin, err := parseSpinArgs(args)
+	if err != nil {
+		logger.Debug("spin", "args", args, "err", err)
+		return command.LeaveComment(spinUsage)
+	}
 
-	num := 0
-	if arg != "" {
-		var err error
-		num, err = strconv.Atoi(arg)
+	switch spin.action {
+	case spinReroll:
+		result, err := command.RerollReviewer(spin.user, spin.exclude...)
+		if err != nil {
+			if errors.Is(err, handlers.NotReviewerError) {
+				return command.LeaveComment(fmt.Sprintf("🎲 @%s is not a reviewer of this Merge Request", spin.user))
+			}
+
+			return fmt.Errorf("command.RerollReviewer returns err: %w", err)
+		}
+
+		return leaveRouletteResult(command, result)
+
+	case spinAdd:
+		result, err := command.AddReviewers(spin.num, spin.exclude...)
 		if err != nil {
-			logger.Debug("spin", "args", args, "arg", arg)
-			return command.LeaveComment("> [!important]\n> Number of players is invalid or wrong")
+			return fmt.Errorf("command.AddReviewers returns err: %w", err)
 		}
+
+		return leaveRouletteResult(command, result)
 	}
 
-	result, err := command.ReviewRoulette(num)
+	result, err := command.ReviewRoulette(spin.num, spin.exclude...)
 	if err != nil {
 		if errors.Is(err, handlers.ReviewersAssignedError) {
-			return command.LeaveComment("🎲 Merge Request has assigned reviewers already")
+			return command.LeaveComment("🎲 Merge Request has assigned reviewers already, use `!spin reroll @user` or `!spin add`")
 		}
 
 		return fmt.Errorf("command.ReviewRoulette returns err: %w", err)
 	}
 
-	if len(result.Winners) == 0 {
-		return command.LeaveComment("🎲 No available players")
-	}
-
-	if err := command.LeaveComment(result.String()); err != nil {
+	if err := leaveRouletteResult(command, result); err != nil || len(result.Winners) == 0 {
 		return err
 	}
 
@@ -129,11 +206,105 @@ func ReviewRouletteCmd(command *handlers.Request, args string) error {
 	return nil
 }
 
+func RouletteCmd(command *handlers.Request, args string) error {
+	const usage = "> [!important]\n> Usage: `!roulette stats [days]`, days is from 1 to %d"
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 || fields[0] != "stats" {
+		return command.LeaveComment(fmt.Sprintf(usage, handlers.MaxStatsDays))
+	}
+
+	days := 0
+	if len(fields) == 2 {
+		var err error
+		days, err = strconv.Atoi(fields[1])
+		if err != nil || days < 1 || days > handlers.MaxStatsDays {
+			return command.LeaveComment(fmt.Sprintf(usage, handlers.MaxStatsDays))
+		}
+	}
+
+	text, err := command.RouletteStats(days)
+	if err != nil {
+		return fmt.Errorf("command.RouletteStats returns err: %w", err)
+	}
+
+	return command.LeaveComment(text)
+}
+
+func StaleCmd(command *handlers.Request, args string) error {
+	const usage = "> [!important]\n> Usage: `!stale preview`"
+
+	if strings.TrimSpace(args) != "preview" {
+		return command.LeaveComment(usage)
+	}
+
+	return command.LeaveComment(command.StalePreview())
+}
+
+func SnoozeCmd(command *handlers.Request, args string) error {
+	const usage = "> [!important]\n> Usage: `!snooze <period>`, e.g. `!snooze 30d`, `!snooze 2w`"
+
+	fields := strings.Fields(args)
+	if len(fields) != 1 {
+		return command.LeaveComment(usage)
+	}
+
+	_, err := command.Snooze(fields[0])
+	switch {
+	case errors.Is(err, handlers.PeriodError):
+		return command.LeaveComment(usage)
+	case err != nil:
+		return fmt.Errorf("command.Snooze returns err: %w", err)
+	}
+
+	return nil
+}
+
+func RestoreBranchCmd(command *handlers.Request, args string) error {
+	const usage = "> [!important]\n> Usage: `!restore-branch <name>`"
+
+	fields := strings.Fields(args)
+	if len(fields) != 1 {
+		return command.LeaveComment(usage)
+	}
+
+	name := fields[0]
+
+	tag, err := command.RestoreBranch(name)
+	switch {
+	case errors.Is(err, handlers.NotFoundError):
+		return command.LeaveComment(fmt.Sprintf("❌ Branch `%s` has no archive tags", name))
+	case errors.Is(err, handlers.AlreadyExistsError):
+		return command.LeaveComment(fmt.Sprintf("❌ Branch `%s` already exists", name))
+	case err != nil:
+		return fmt.Errorf("command.RestoreBranch returns err: %w", err)
+	}
+
+	return command.LeaveComment(fmt.Sprintf("♻️ Branch `%s` is restored from tag `%s`", name, tag))
+}
+
+func ConfigCmd(command *handlers.Request, args string) error {
+	text, err := command.ConfigReport()
+	if err != nil {
+		return fmt.Errorf("command.ConfigReport returns err: %w", err)
+	}
+
+	return command.LeaveComment(text)
+}
+
 func NewMREvent(command *handlers.Request, args string) error {
 	if err := command.Greetings(); err != nil {
 		return fmt.Errorf("command.Greetings returns err: %w", err)
 	}
 
+	if err := command.ValidateConfigChange(); err != nil {
+		logger.Info("config change can't be validated", "err", err)
+	}
+
+	if err := command.RecordManualAssignments(); err != nil {
+		logger.Info("manual assignments can't be recorded", "err", err)
+	}
+
 	if err := command.AutoAssignReviewers(); err != nil {
 		if errors.Is(err, handlers.ReviewersAssignedError) {
 			return nil
@@ -147,13 +318,21 @@ func NewMREvent(command *handlers.Request, args string) error {
 
 func MergeEvent(command *handlers.Request, args string) error {
 	if err := command.UpdateBranches(); err != nil {
-		return fmt.Errorf("command.UpdateBranchesWithLabel returns err: %w", err)
+		logger.Info("branches can't be updated", "err", err)
+	}
+
+	if err := command.WarnConflicts(); err != nil {
+		return fmt.Errorf("command.WarnConflicts returns err: %w", err)
 	}
 
 	return nil
 }
 
 func UpdateEvent(command *handlers.Request, args string) error {
+	if err := command.RecordManualAssignments(); err != nil {
+		logger.Info("manual assignments can't be recorded", "err", err)
+	}
+
 	ok, _, err := command.IsValid()
 	if err != nil {
 		return fmt.Errorf("command.IsValid returns err: %w", err)
@@ -173,6 +352,37 @@ func UpdateEvent(command *handlers.Request, args string) error {
 }
 
 func PushEvent(command *handlers.Request, args string) error {
+	if err := command.ValidateConfigChange(); err != nil {
+		return fmt.Errorf("command.ValidateConfigChange returns err: %w", err)
+	}
+
+	return nil
+}
+
+func PipelineEvent(command *handlers.Request, args string) error {
+	if err := command.SummarizePipelineFailure(); err != nil {
+		return fmt.Errorf("command.SummarizePipelineFailure returns err: %w", err)
+	}
+
+	return nil
+}
+
+func BranchPushEvent(command *handlers.Request, args string) error {
+	branch, sha, _ := strings.Cut(args, " ")
+
+	if err := command.BranchPushed(branch, sha); err != nil {
+		return fmt.Errorf("command.BranchPushed returns err: %w", err)
+	}
+
+	// the branch is deleted
+	if sha == "" {
+		return nil
+	}
+
+	if err := command.UpdateBranchesFrom(branch, sha); err != nil {
+		return fmt.Errorf("command.UpdateBranchesFrom returns err: %w", err)
+	}
+
 	return nil
 }
 
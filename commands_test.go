@@ -55,3 +55,49 @@ func TestInitCommands(t *testing.T) {
 		assert.True(t, exists, "Handler %s should be registered", handler)
 	}
 }
+
+func TestParseSpinArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    spinArgs
+		wantErr bool
+	}{
+		{name: "default number", args: "", want: spinArgs{}},
+		{name: "number", args: " 3 ", want: spinArgs{num: 3}},
+		{name: "reroll", args: "reroll @alice", want: spinArgs{action: spinReroll, user: "alice"}},
+		{name: "add", args: "add 1", want: spinArgs{action: spinAdd, num: 1}},
+		{name: "add default number", args: "add", want: spinArgs{action: spinAdd}},
+		{
+			name: "exclude",
+			args: "2 --exclude @alice,@bob",
+			want: spinArgs{num: 2, exclude: []string{"alice", "bob"}},
+		},
+		{
+			name: "exclude with spaces",
+			args: "--exclude @alice, @bob reroll carol",
+			want: spinArgs{action: spinReroll, user: "carol", exclude: []string{"alice", "bob"}},
+		},
+		{
+			name: "exclude with equal sign",
+			args: "add 1 --exclude=alice",
+			want: spinArgs{action: spinAdd, num: 1, exclude: []string{"alice"}},
+		},
+		{name: "invalid number", args: "two", wantErr: true},
+		{name: "reroll without user", args: "reroll", wantErr: true},
+		{name: "too many arguments", args: "add 1 2", wantErr: true},
+		{name: "unknown option", args: "--include @alice", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSpinArgs(tt.args)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errSpinArgs)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
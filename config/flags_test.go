@@ -42,6 +42,23 @@ func TestBoolVar(t *testing.T) {
 	assert.Equal(t, "true", flag.DefValue)
 }
 
+func TestIntVar(t *testing.T) {
+	// Reset flag set for testing
+	originalFS := fs
+	defer func() { fs = originalFS }()
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+
+	var testInt int
+	IntVar(&testInt, "test-int", 90, "test int variable")
+
+	// Test that the flag was added
+	flag := fs.Lookup("test-int")
+	assert.NotNil(t, flag)
+	assert.Equal(t, "test int variable", flag.Usage)
+	assert.Equal(t, "90", flag.DefValue)
+}
+
 //nolint:errcheck
 func TestParse(t *testing.T) {
 	// Save original args and restore after test
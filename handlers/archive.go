@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ArchiveTag keeps deleted branches as tags archive/<branch>/<date>
+	ArchiveTag       = "tag"
+	archiveTagPrefix = "archive/"
+)
+
+// maxArchiveTags limits archives of the branch made on the same day
+const maxArchiveTags = 100
+
+// archiveTagName returns the name of the n-th archive of the branch made on the day, n starts with 1
+func archiveTagName(branch string, now time.Time, n int) string {
+	name := archiveTagPrefix + branch + "/" + now.Format(time.DateOnly)
+	if n > 1 {
+		name += "-" + strconv.Itoa(n)
+	}
+
+	return name
+}
+
+// deleteBranch archives the commit of the branch if archive is on and deletes the branch,
+// the branch itself is archived if the commit is unknown
+func (r Request) deleteBranch(branch, commit string, now time.Time) error {
+	if r.config.StaleBranchesDeletion.Archive == ArchiveTag {
+		if err := r.archiveBranch(branch, cmp.Or(commit, branch), now); err != nil {
+			return err
+		}
+	}
+
+	if err := r.provider.DeleteBranch(r.info.ProjectID, branch); err != nil {
+		return fmt.Errorf("DeleteBranch returns error: %w", err)
+	}
+
+	return nil
+}
+
+// archiveBranch tags the ref, the name gets a suffix if the branch has been archived on the same day
+func (r Request) archiveBranch(branch, ref string, now time.Time) error {
+	for n := 1; n <= maxArchiveTags; n++ {
+		err := r.provider.CreateTag(r.info.ProjectID, archiveTagName(branch, now, n), ref)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, AlreadyExistsError) {
+			return fmt.Errorf("CreateTag returns error: %w", err)
+		}
+	}
+
+	return fmt.Errorf("%w: branch %s has been archived %d times today", AlreadyExistsError, branch, maxArchiveTags)
+}
+
+// archiveOrder returns the date of the archive tag and its number on the day
+func archiveOrder(tag string) (string, int) {
+	name := tag[strings.LastIndex(tag, "/")+1:]
+	date, suffix := name[:min(len(name), len(time.DateOnly))], name[min(len(name), len(time.DateOnly)):]
+
+	n, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+	if err != nil {
+		n = 1
+	}
+
+	return date, n
+}
+
+// compareArchives orders archive tags of the branch by date and then by their number on the day
+func compareArchives(a, b string) int {
+	dateA, nA := archiveOrder(a)
+	dateB, nB := archiveOrder(b)
+
+	return cmp.Or(cmp.Compare(dateA, dateB), cmp.Compare(nA, nB))
+}
+
+// RestoreBranch recreates the branch from its latest archive tag and returns the tag
+func (r Request) RestoreBranch(name string) (string, error) {
+	prefix := archiveTagPrefix + name + "/"
+
+	tags, err := r.provider.ListTags(r.info.ProjectID, prefix)
+	if err != nil {
+		return "", fmt.Errorf("ListTags returns error: %w", err)
+	}
+
+	// archives of feature/x are not archives of feature
+	tags = slices.DeleteFunc(tags, func(t string) bool {
+		date, ok := strings.CutPrefix(t, prefix)
+		return !ok || strings.Contains(date, "/")
+	})
+
+	if len(tags) == 0 {
+		return "", fmt.Errorf("%w: no archive of %q", NotFoundError, name)
+	}
+
+	// the latest archive is restored
+	latest := slices.MaxFunc(tags, compareArchives)
+
+	if err := r.provider.CreateBranch(r.info.ProjectID, name, latest); err != nil {
+		return "", fmt.Errorf("CreateBranch returns error: %w", err)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_cleanStaleBranches_Archive(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	today := now.Format(time.DateOnly)
+
+	provider := &testProvider{
+		branches: []StaleBranch{{Name: "feature/old", Commit: "c1", LastUpdated: now.AddDate(0, 0, -120)}},
+		mergeRequests: []MR{
+			{ID: 1, Branch: "old-mr", SHA: "c2", Labels: []string{staleLabel}, LastUpdated: now.AddDate(0, 0, -5)},
+		},
+		// the branch was archived earlier today
+		tags: map[string]string{"archive/old-mr/" + today: "old-mr"},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 4300}, config: defaultConfig()}
+	assert.NoError(t, cache.SetBranchNotice(4300, "feature/old", branchNotice{Commit: "c1", NotifiedAt: now.AddDate(0, 0, -2)}))
+	r.config.StaleBranchesDeletion.Enabled = true
+	r.config.StaleBranchesDeletion.Archive = ArchiveTag
+
+	assert.NoError(t, r.DeleteStaleBranches())
+	assert.Equal(t, []string{"old-mr", "feature/old"}, provider.deleted)
+	assert.Equal(t, map[string]string{
+		"archive/old-mr/" + today:        "old-mr",
+		"archive/old-mr/" + today + "-2": "c2",
+		"archive/feature/old/" + today:   "c1",
+	}, provider.tags)
+}
+
+func TestRequest_RestoreBranch(t *testing.T) {
+	provider := &testProvider{
+		tags: map[string]string{
+			"archive/feature/2026-01-10":    "feature",
+			"archive/feature/2026-03-02":    "feature",
+			"archive/feature/2026-03-02-2":  "feature",
+			"archive/feature/2026-03-02-9":  "feature",
+			"archive/feature/2026-03-02-10": "feature",
+			"archive/feature/x/2026-05-01":  "feature/x",
+		},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 1}, config: defaultConfig()}
+
+	tag, err := r.RestoreBranch("feature")
+	assert.NoError(t, err)
+	assert.Equal(t, "archive/feature/2026-03-02-10", tag)
+	assert.Equal(t, []string{"feature"}, provider.created)
+
+	_, err = r.RestoreBranch("feature")
+	assert.ErrorIs(t, err, AlreadyExistsError)
+
+	_, err = r.RestoreBranch("feat")
+	assert.ErrorIs(t, err, NotFoundError)
+
+	// API errors are not missing archives
+	provider.err = errors.New("500 Internal Server Error")
+	_, err = r.RestoreBranch("feature/x")
+	assert.ErrorIs(t, err, provider.err)
+	assert.NotErrorIs(t, err, NotFoundError)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	AssignedByRoulette = "roulette"
+	AssignedManually   = "manual"
+	// AssignmentCancelled cancels the previous assignment of the reviewer to MR, e.g. after !spin reroll
+	AssignmentCancelled = "cancelled"
+
+	// MaxStatsDays is the longest period of !roulette stats
+	MaxStatsDays = 365
+)
+
+type reviewerStats struct {
+	username string
+	roulette int
+	manual   int
+	last     time.Time
+}
+
+func (s reviewerStats) total() int {
+	return s.roulette + s.manual
+}
+
+// recordAssignments logs assignments and keeps the index of logged reviewers of MR
+func (r Request) recordAssignments(usernames []string, source string, now time.Time) {
+	assignments := make([]cache.Assignment, 0, len(usernames))
+	for _, u := range usernames {
+		assignments = append(assignments, cache.Assignment{Reviewer: u, MergeID: r.info.ID, Source: source, At: now})
+	}
+
+	if err := cache.AddAssignments(r.info.ProjectID, assignments...); err != nil {
+		logger.Error("assignments can't be saved", "err", err)
+		return
+	}
+
+	reviewers, err := r.loggedReviewers()
+	if err != nil {
+		logger.Error("logged reviewers can't be loaded", "err", err)
+		return
+	}
+
+	for _, u := range usernames {
+		switch {
+		case source == AssignmentCancelled:
+			reviewers = slices.DeleteFunc(reviewers, func(reviewer string) bool { return reviewer == u })
+		case !slices.Contains(reviewers, u):
+			reviewers = append(reviewers, u)
+		}
+	}
+
+	if err := cache.SetMRReviewers(r.info.ProjectID, r.info.ID, reviewers); err != nil {
+		logger.Error("logged reviewers can't be saved", "err", err)
+	}
+}
+
+// loggedReviewers returns reviewers of MR whose assignments are logged, the index of MR is built from the log
+// if it doesn't exist yet, so the whole log isn't decoded on every update of MR
+func (r Request) loggedReviewers() ([]string, error) {
+	reviewers, ok, err := cache.GetMRReviewers(r.info.ProjectID, r.info.ID)
+	if err != nil || ok {
+		return reviewers, err
+	}
+
+	assignments, err := r.assignments(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	reviewers = []string{}
+	for _, a := range assignments {
+		if a.MergeID == r.info.ID && !slices.Contains(reviewers, a.Reviewer) {
+			reviewers = append(reviewers, a.Reviewer)
+		}
+	}
+
+	if err := cache.SetMRReviewers(r.info.ProjectID, r.info.ID, reviewers); err != nil {
+		logger.Error("logged reviewers can't be saved", "err", err)
+	}
+
+	return reviewers, nil
+}
+
+// effectiveAssignments drops cancelled assignments
+func effectiveAssignments(assignments []cache.Assignment) []cache.Assignment {
+	result := make([]cache.Assignment, 0, len(assignments))
+
+	for _, a := range assignments {
+		if a.Source != AssignmentCancelled {
+			result = append(result, a)
+			continue
+		}
+
+		for i := len(result) - 1; i >= 0; i-- {
+			if result[i].MergeID == a.MergeID && result[i].Reviewer == a.Reviewer {
+				result = slices.Delete(result, i, i+1)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// assignments returns the assignment log of the project since the time without cancelled assignments
+func (r Request) assignments(since time.Time) ([]cache.Assignment, error) {
+	assignments, err := cache.GetAssignments(r.info.ProjectID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return effectiveAssignments(assignments), nil
+}
+
+// RecordManualAssignments logs reviewers of MR who were not assigned by the roulette
+func (r Request) RecordManualAssignments() error {
+	if len(r.info.Reviewers) == 0 {
+		return nil
+	}
+
+	logged, err := r.loggedReviewers()
+	if err != nil {
+		return err
+	}
+
+	manual := slices.DeleteFunc(slices.Clone(r.info.Reviewers), func(u string) bool {
+		return slices.Contains(logged, u)
+	})
+
+	if len(manual) == 0 {
+		return nil
+	}
+
+	r.recordAssignments(manual, AssignedManually, time.Now())
+	return nil
+}
+
+// assignmentCounts returns the number of assignments of every reviewer in the fairness window
+func (r Request) assignmentCounts(now time.Time) (map[string]int, error) {
+	assignments, err := r.assignments(now.AddDate(0, 0, -r.config.AssignReviewers.FairnessDays))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, a := range assignments {
+		counts[a.Reviewer]++
+	}
+
+	return counts, nil
+}
+
+// RouletteStats returns the table of assignments per reviewer for the last days, contributors without assignments are listed too
+func (r Request) RouletteStats(days int) (string, error) {
+	if days == 0 {
+		days = r.config.AssignReviewers.FairnessDays
+	}
+
+	assignments, err := r.assignments(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return "", err
+	}
+
+	stats := map[string]*reviewerStats{}
+	for _, a := range assignments {
+		s, ok := stats[a.Reviewer]
+		if !ok {
+			s = &reviewerStats{username: a.Reviewer}
+			stats[a.Reviewer] = s
+		}
+
+		if a.Source == AssignedManually {
+			s.manual++
+		} else {
+			s.roulette++
+		}
+
+		s.last = a.At
+	}
+
+	settings := r.config.AssignReviewers.Candidates
+
+	gamblers, err := r.provider.GetContributors(r.info.ProjectID, r.info.ID, settings)
+	if err != nil {
+		logger.Info("GetContributors returns error, stats show assigned reviewers only", "err", err)
+	}
+
+	for _, g := range gamblers {
+		if _, ok := stats[g.Username]; ok || settings.isBot(g) || slices.Contains(r.config.AssignReviewers.ExcludeUsernames, g.Username) {
+			continue
+		}
+
+		stats[g.Username] = &reviewerStats{username: g.Username}
+	}
+
+	if len(stats) == 0 {
+		return fmt.Sprintf("📊 No review assignments for last %d days", days), nil
+	}
+
+	rows := make([]*reviewerStats, 0, len(stats))
+	for _, s := range stats {
+		rows = append(rows, s)
+	}
+
+	slices.SortFunc(rows, func(a, b *reviewerStats) int {
+		if c := cmp.Compare(b.total(), a.total()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.username, b.username)
+	})
+
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "📊 **Review assignments for last %d days**\n\n", days)
+	builder.WriteString("| Reviewer | Roulette | Manual | Total | Last assigned |\n")
+	builder.WriteString("|---|---|---|---|---|\n")
+
+	for _, s := range rows {
+		last := "-"
+		if !s.last.IsZero() {
+			last = s.last.Format(time.DateOnly)
+		}
+
+		fmt.Fprintf(builder, "| @%s | %d | %d | %d | %s |\n", s.username, s.roulette, s.manual, s.total(), last)
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_RecordManualAssignments(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	r := Request{
+		provider: &testProvider{},
+		info:     &MrInfo{ProjectID: 36, ID: 1, Reviewers: []string{"alice", "bob"}},
+		config:   defaultConfig(),
+	}
+
+	r.recordAssignments([]string{"alice"}, AssignedByRoulette, time.Now())
+
+	// the update event is received after every change of MR, reviewers are logged once
+	for range 2 {
+		assert.NoError(t, r.RecordManualAssignments())
+	}
+
+	assignments, err := cache.GetAssignments(36, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 2)
+	assert.Equal(t, "alice", assignments[0].Reviewer)
+	assert.Equal(t, AssignedByRoulette, assignments[0].Source)
+	assert.Equal(t, "bob", assignments[1].Reviewer)
+	assert.Equal(t, AssignedManually, assignments[1].Source)
+
+	reviewers, ok, err := cache.GetMRReviewers(36, 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"alice", "bob"}, reviewers)
+
+	// a rerolled reviewer assigned again is logged as a manual assignment
+	r.recordAssignments([]string{"alice"}, AssignmentCancelled, time.Now())
+	assert.NoError(t, r.RecordManualAssignments())
+
+	assignments, err = cache.GetAssignments(36, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 4)
+	assert.Equal(t, "alice", assignments[3].Reviewer)
+	assert.Equal(t, AssignedManually, assignments[3].Source)
+}
+
+func TestRequest_RecordManualAssignments_Index(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	// the log of MR is indexed on the first update
+	assert.NoError(t, cache.AddAssignments(38, cache.Assignment{Reviewer: "alice", MergeID: 1, Source: AssignedByRoulette, At: time.Now()}))
+
+	r := Request{
+		provider: &testProvider{},
+		info:     &MrInfo{ProjectID: 38, ID: 1, Reviewers: []string{"alice"}},
+		config:   defaultConfig(),
+	}
+
+	assert.NoError(t, r.RecordManualAssignments())
+
+	reviewers, ok, err := cache.GetMRReviewers(38, 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"alice"}, reviewers)
+
+	assignments, err := cache.GetAssignments(38, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 1)
+}
+
+func TestRequest_AssignReviewers_Failed(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	provider := &testProvider{
+		candidates:  []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}},
+		openReviews: map[string]int{},
+		assignErr:   errors.New("forbidden"),
+	}
+
+	r := Request{
+		provider: provider,
+		info:     &MrInfo{ProjectID: 39, ID: 1, Author: "author", Reviewers: []string{"alice"}, IsValid: true},
+		config:   defaultConfig(),
+	}
+	r.config.AssignReviewers.Enabled = true
+
+	_, err := r.AddReviewers(1)
+	assert.Error(t, err)
+
+	_, err = r.RerollReviewer("alice")
+	assert.Error(t, err)
+
+	r.info.Reviewers = nil
+	assert.Error(t, r.AutoAssignReviewers())
+
+	// reviewers which aren't assigned don't count
+	assignments, err := cache.GetAssignments(39, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, assignments)
+}
+
+func TestRequest_spinRouletteFairness(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	assert.NoError(t, cache.AddAssignments(37,
+		cache.Assignment{Reviewer: "bob", MergeID: 1, Source: AssignedByRoulette, At: now.AddDate(0, 0, -40)},
+		cache.Assignment{Reviewer: "bob", MergeID: 2, Source: AssignedByRoulette, At: now.AddDate(0, 0, -35)},
+		cache.Assignment{Reviewer: "alice", MergeID: 3, Source: AssignedByRoulette, At: now.AddDate(0, 0, -2)},
+	))
+
+	r := Request{
+		provider: &testProvider{
+			candidates:  []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}},
+			openReviews: map[string]int{},
+		},
+		info:   &MrInfo{ProjectID: 37, ID: 4, Author: "author"},
+		config: defaultConfig(),
+	}
+
+	// assignments of bob are older than fairness_days
+	result, err := r.spinRoulette(2)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bob", "carol"}, result.Winners)
+
+	r.config.AssignReviewers.FairnessDays = 60
+	result, err = r.spinRoulette(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"carol", "alice"}, result.Winners)
+}
+
+func TestRequest_RouletteStats(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	assert.NoError(t, cache.AddAssignments(38,
+		cache.Assignment{Reviewer: "alice", MergeID: 1, Source: AssignedByRoulette, At: now.AddDate(0, 0, -3)},
+		cache.Assignment{Reviewer: "bob", MergeID: 1, Source: AssignedManually, At: now.AddDate(0, 0, -3)},
+		cache.Assignment{Reviewer: "alice", MergeID: 2, Source: AssignedManually, At: now.AddDate(0, 0, -1)},
+		cache.Assignment{Reviewer: "dave", MergeID: 3, Source: AssignedByRoulette, At: now.AddDate(0, 0, -20)},
+	))
+
+	r := Request{
+		provider: &testProvider{candidates: []Candidate{{Username: "alice"}, {Username: "carol"}, {Username: "merge-bot"}}},
+		info:     &MrInfo{ProjectID: 38, ID: 4},
+		config:   defaultConfig(),
+	}
+
+	text, err := r.RouletteStats(7)
+	assert.NoError(t, err)
+	assert.Contains(t, text, "for last 7 days")
+	assert.Contains(t, text, "| @alice | 1 | 1 | 2 | "+now.AddDate(0, 0, -1).Format(time.DateOnly)+" |\n| @bob | 0 | 1 | 1 |")
+	assert.Contains(t, text, "| @carol | 0 | 0 | 0 | - |")
+	assert.NotContains(t, text, "dave")
+	assert.NotContains(t, text, "merge-bot")
+
+	text, err = r.RouletteStats(0)
+	assert.NoError(t, err)
+	assert.Contains(t, text, "for last 30 days")
+	assert.Contains(t, text, "| @dave | 1 | 0 | 1 |")
+
+	r.info.ProjectID = 39
+	r.provider = &testProvider{}
+	text, err = r.RouletteStats(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "📊 No review assignments for last 7 days", text)
+}
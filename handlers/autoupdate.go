@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+const autoUpdateMarker = "<!-- merge-bot:auto-update -->"
+
+var (
+	autoUpdateConcurrency int
+
+	// runningPipelineStatuses are statuses of pipelines which would be restarted by the update
+	runningPipelineStatuses = []string{"created", "waiting_for_resource", "preparing", "pending", "running"}
+)
+
+func init() {
+	config.IntVar(&autoUpdateConcurrency, "auto-update-concurrency", 4, "max number of MRs labelled merge-bot:auto-update which are updated at once (also via AUTO_UPDATE_CONCURRENCY)")
+}
+
+// UpdateBranches updates MRs labelled merge-bot:auto-update from the target branch of the merged MR
+func (r Request) UpdateBranches() error {
+	return r.UpdateBranchesFrom(r.info.TargetBranch, r.info.MergedSHA)
+}
+
+// UpdateBranchesFrom merges the target branch into MRs labelled merge-bot:auto-update, MRs with running pipelines are skipped,
+// sha is the head of the target branch, MRs aren't updated twice from the same sha; it may be empty if the head is unknown
+func (r Request) UpdateBranchesFrom(targetBranch, sha string) error {
+	for {
+		if !cache.AcquireUpdateLease(r.info.ProjectID, targetBranch) {
+			// the holder of the lease updates MRs again when it's done
+			if err := cache.SetPendingUpdate(r.info.ProjectID, targetBranch, sha); err != nil {
+				return fmt.Errorf("pending update can't be saved: %w", err)
+			}
+
+			// the lease may have been released before the pending update was saved
+			if !cache.AcquireUpdateLease(r.info.ProjectID, targetBranch) {
+				return nil
+			}
+		}
+
+		r.updateBranchesFrom(targetBranch, sha)
+		cache.ReleaseUpdateLease(r.info.ProjectID, targetBranch)
+
+		pending, ok, err := cache.TakePendingUpdate(r.info.ProjectID, targetBranch)
+		if err != nil {
+			return fmt.Errorf("pending update can't be loaded: %w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		sha = pending
+	}
+}
+
+func (r Request) updateBranchesFrom(targetBranch, sha string) {
+	if sha != "" {
+		// the merge of MR and the push of its commit come together, both of them bring the same sha
+		updated, ok, err := cache.UpdatedSHA(r.info.ProjectID, targetBranch)
+		if err != nil {
+			logger.Info("updated sha can't be loaded", "branch", targetBranch, "err", err)
+		}
+
+		if ok && updated == sha {
+			logger.Debug("MRs are already updated", "branch", targetBranch, "sha", sha)
+			return
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, max(autoUpdateConcurrency, 1))
+		skipped = 0
+	)
+
+	for mr := range r.provider.FindMergeRequests(r.info.ProjectID, targetBranch, autoUpdateLabel) {
+		if slices.Contains(runningPipelineStatuses, mr.PipelineStatus) {
+			logger.Debug("auto-update is skipped, pipeline is running", "mergeID", mr.ID, "status", mr.PipelineStatus)
+			skipped++
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			r.autoUpdate(mr, targetBranch)
+		})
+	}
+
+	wg.Wait()
+
+	// skipped MRs are updated by the next merge or push even if it brings the same sha
+	if sha == "" || skipped > 0 {
+		return
+	}
+
+	if err := cache.SetUpdatedSHA(r.info.ProjectID, targetBranch, sha); err != nil {
+		logger.Info("updated sha can't be saved", "branch", targetBranch, "err", err)
+	}
+}
+
+func autoUpdatedText(targetBranch string) string {
+	return fmt.Sprintf("%s\n✅ `%s` is merged into this MR, the conflicts are resolved", autoUpdateMarker, targetBranch)
+}
+
+// autoUpdate updates MR from its target branch and explains how to resolve conflicts if there are any
+func (r Request) autoUpdate(mr MR, targetBranch string) {
+	metrics.BackgroundRunInc("update_branch")
+
+	err := r.provider.UpdateFromMaster(r.info.ProjectID, mr.ID)
+	if err == nil {
+		r.resolveAutoUpdateComment(mr, targetBranch)
+		return
+	}
+
+	logger.Info("UpdateFromDestination", "mergeID", mr.ID, "err", err)
+
+	mergeError := &MergeError{}
+	if !errors.As(err, &mergeError) {
+		return
+	}
+
+	if err := r.provider.LeaveOrUpdateComment(r.info.ProjectID, mr.ID, autoUpdateMarker, autoUpdateMarker+mergeError.Comment()); err != nil {
+		logger.Error("conflict comment can't be left", "mergeID", mr.ID, "err", err)
+	}
+}
+
+// resolveAutoUpdateComment replaces instructions of the failed update, if MR has them, once MR is updated
+func (r Request) resolveAutoUpdateComment(mr MR, targetBranch string) {
+	comment, err := r.provider.LastComment(r.info.ProjectID, mr.ID, autoUpdateMarker)
+	if err != nil {
+		logger.Error("conflict comment can't be loaded", "mergeID", mr.ID, "err", err)
+		return
+	}
+
+	message := autoUpdatedText(targetBranch)
+	if comment == nil || comment.Body == message {
+		return
+	}
+
+	if err := r.provider.LeaveOrUpdateComment(r.info.ProjectID, mr.ID, autoUpdateMarker, message); err != nil {
+		logger.Error("conflict comment can't be updated", "mergeID", mr.ID, "err", err)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_UpdateBranchesFrom(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	labels := []string{autoUpdateLabel}
+
+	provider := &testProvider{
+		mergeRequests: []MR{
+			{ID: 1, Branch: "feature-1", Labels: labels, PipelineStatus: "success"},
+			{ID: 2, Branch: "feature-2", Labels: labels, PipelineStatus: "running"},
+			{ID: 3, Branch: "feature-3", Labels: labels},
+			{ID: 4, Branch: "feature-4"},
+			{ID: 5, Branch: "feature-5", Labels: labels, PipelineStatus: "failed"},
+		},
+		updateErrors: map[int64]error{
+			3: &MergeError{SourceBranch: "feature-3", DestinationBranch: "main"},
+			5: errors.New("network is down"),
+		},
+		notes: []Comment{{Body: autoUpdateMarker + (&MergeError{SourceBranch: "feature-1", DestinationBranch: "main"}).Comment()}},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 4700}, config: defaultConfig()}
+
+	assert.NoError(t, r.UpdateBranchesFrom("main", ""))
+
+	slices.Sort(provider.updated)
+	assert.Equal(t, []int64{1, 3, 5}, provider.updated)
+
+	assert.Equal(t, autoUpdateMarker, provider.lastMarker)
+	assert.Contains(t, provider.lastComment, "🛠️ I failed to merge main into your branch")
+	assert.Contains(t, provider.lastComment, "git checkout feature-3")
+
+	// instructions of the failed update are replaced once MR is updated
+	assert.Equal(t, autoUpdatedText("main"), provider.comments[1])
+
+	// the lease is released
+	provider.updated = nil
+	assert.NoError(t, r.UpdateBranchesFrom("main", ""))
+	assert.Len(t, provider.updated, 3)
+
+	// MRs skipped because of running pipelines are updated by the same commit again
+	provider.updated = nil
+	assert.NoError(t, r.UpdateBranchesFrom("main", "abc"))
+	assert.NoError(t, r.UpdateBranchesFrom("main", "abc"))
+	assert.Len(t, provider.updated, 6)
+
+	// the merge and the push of the same commit update MRs once
+	provider.mergeRequests[1].PipelineStatus = "success"
+	provider.updated = nil
+	assert.NoError(t, r.UpdateBranchesFrom("main", "abc"))
+	assert.NoError(t, r.UpdateBranchesFrom("main", "abc"))
+	assert.Len(t, provider.updated, 4)
+
+	assert.NoError(t, r.UpdateBranchesFrom("main", "def"))
+	assert.Len(t, provider.updated, 8)
+}
+
+func TestRequest_UpdateBranchesFrom_Lease(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	provider := &testProvider{
+		mergeRequests: []MR{{ID: 1, Branch: "feature-1", Labels: []string{autoUpdateLabel}}},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 4701, TargetBranch: "main", MergedSHA: "abc"}, config: defaultConfig()}
+
+	assert.True(t, cache.AcquireUpdateLease(4701, "main"))
+	defer cache.ReleaseUpdateLease(4701, "main")
+
+	// the push is left to the holder of the lease
+	assert.NoError(t, r.UpdateBranches())
+	assert.Empty(t, provider.updated)
+
+	pending, ok, err := cache.TakePendingUpdate(4701, "main")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", pending)
+
+	// the holder updates MRs again from the pending push
+	assert.NoError(t, cache.SetPendingUpdate(4701, "release", "def"))
+	assert.NoError(t, r.UpdateBranchesFrom("release", "abc"))
+	assert.Equal(t, []int64{1, 1}, provider.updated)
+
+	_, ok, err = cache.TakePendingUpdate(4701, "release")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type BranchRule struct {
+	Name           string    `yaml:"name,omitempty"`
+	TargetBranches []string  `yaml:"target_branches"`
+	Paths          []string  `yaml:"paths,omitempty"`
+	Rules          yaml.Node `yaml:"rules"`
+}
+
+func (b BranchRule) String() string {
+	if b.Name != "" {
+		return b.Name
+	}
+
+	name := strings.Join(b.TargetBranches, ", ")
+	if len(b.Paths) > 0 {
+		name += " (" + strings.Join(b.Paths, ", ") + ")"
+	}
+
+	return name
+}
+
+func (b BranchRule) matchPaths(changedFiles []string) bool {
+	for _, f := range changedFiles {
+		if matchAny(b.Paths, f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyBranchRules overrides Rules with the first branch_rules entry matching the MR
+func (r *Request) applyBranchRules() error {
+	for i, rule := range r.config.BranchRules {
+		if !matchAny(rule.TargetBranches, r.info.TargetBranch) {
+			continue
+		}
+
+		if len(rule.Paths) > 0 {
+			changedFiles, err := r.getChangedFiles()
+			if err != nil {
+				return err
+			}
+
+			if !rule.matchPaths(changedFiles) {
+				continue
+			}
+		}
+
+		if !rule.Rules.IsZero() {
+			if err := rule.Rules.Decode(&r.config.Rules); err != nil {
+				return fmt.Errorf("branch_rules[%d] can't be applied: %w", i, err)
+			}
+		}
+
+		r.config.AppliedBranchRule = rule.String()
+		return nil
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"main", "main", true},
+		{"release/*", "release/1.2", true},
+		{"release/*", "release/1.2/hotfix", false},
+		{"docs/**", "docs/api/index.md", true},
+		{"**/*.go", "cmd/bot/main.go", true},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPattern(tt.pattern, tt.s))
+		})
+	}
+}
+
+func TestRequest_applyBranchRules(t *testing.T) {
+	const config = `
+rules:
+  min_approvals: 1
+  approvers: [alice]
+  title_regex: ".*"
+branch_rules:
+  - name: release
+    target_branches: ["release/*"]
+    rules:
+      min_approvals: 2
+      approvers: [bob, carol]
+  - target_branches: [main]
+    paths: ["db/migrations/**"]
+    rules:
+      min_approvals: 3
+  - target_branches: [main, develop]
+    rules:
+      title_regex: "^JIRA-"
+`
+
+	tests := []struct {
+		name          string
+		targetBranch  string
+		changedFiles  []string
+		wantRule      string
+		wantApprovals int
+		wantApprovers []string
+		wantRegex     string
+	}{
+		{
+			name:          "release branch",
+			targetBranch:  "release/1.0",
+			wantRule:      "release",
+			wantApprovals: 2,
+			wantApprovers: []string{"bob", "carol"},
+			wantRegex:     ".*",
+		},
+		{
+			name:          "main with migrations",
+			targetBranch:  "main",
+			changedFiles:  []string{"README.md", "db/migrations/001_init.sql"},
+			wantRule:      "main (db/migrations/**)",
+			wantApprovals: 3,
+			wantApprovers: []string{"alice"},
+			wantRegex:     ".*",
+		},
+		{
+			name:          "main without migrations",
+			targetBranch:  "main",
+			changedFiles:  []string{"README.md"},
+			wantRule:      "main, develop",
+			wantApprovals: 1,
+			wantApprovers: []string{"alice"},
+			wantRegex:     "^JIRA-",
+		},
+		{
+			name:          "no match",
+			targetBranch:  "feature/x",
+			wantRule:      "",
+			wantApprovals: 1,
+			wantApprovers: []string{"alice"},
+			wantRegex:     ".*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{provider: &testProvider{
+				state:        "opened",
+				config:       config,
+				targetBranch: tt.targetBranch,
+				changedFiles: tt.changedFiles,
+			}}
+
+			if err := r.LoadInfoAndConfig(1, 2); err != nil {
+				t.Fatalf("LoadInfoAndConfig failed: %v", err)
+			}
+
+			assert.Equal(t, tt.wantRule, r.config.AppliedBranchRule)
+			assert.Equal(t, tt.wantApprovals, r.config.Rules.MinApprovals)
+			assert.Equal(t, tt.wantApprovers, r.config.Rules.Approvers)
+			assert.Equal(t, tt.wantRegex, r.config.Rules.TitleRegex)
+
+			_, text, err := r.IsValid()
+			assert.NoError(t, err)
+			if tt.wantRule != "" {
+				assert.Contains(t, text, "Rules are overridden by branch_rules: `"+tt.wantRule+"`")
+			} else {
+				assert.NotContains(t, text, "branch_rules")
+			}
+		})
+	}
+}
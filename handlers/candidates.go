@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gasoid/merge-bot/v3/config"
+)
+
+const (
+	AccessGuest      = "guest"
+	AccessReporter   = "reporter"
+	AccessDeveloper  = "developer"
+	AccessMaintainer = "maintainer"
+	AccessOwner      = "owner"
+)
+
+var (
+	accessLevels = []string{AccessGuest, AccessReporter, AccessDeveloper, AccessMaintainer, AccessOwner}
+
+	botUsernames     string
+	vacationStatuses string
+	emojiStatuses    string
+	minAccessLevel   string
+	lookbackDays     int
+)
+
+func init() {
+	config.StringVar(&botUsernames, "roulette-bot-usernames", "*bot,jira*,gitlab*,github*", "comma list of username patterns of bots in addition to bot accounts, default of review_roulette.candidates.bot_usernames (also via ROULETTE_BOT_USERNAMES)")
+	config.StringVar(&vacationStatuses, "roulette-vacation-statuses", "ooo,vacation,travel,parental leave", "comma list of user status words which mean the user is away, default of review_roulette.candidates.vacation_statuses (also via ROULETTE_VACATION_STATUSES)")
+	config.StringVar(&emojiStatuses, "roulette-emoji-statuses", "beach,beach_umbrella,palm_tree,red_circle,no_entry", "comma list of user status emoji which mean the user is away, default of review_roulette.candidates.emoji_statuses (also via ROULETTE_EMOJI_STATUSES)")
+	config.StringVar(&minAccessLevel, "roulette-min-access-level", AccessMaintainer, "minimal access level of reviewers: guest, reporter, developer, maintainer or owner, default of review_roulette.candidates.min_access_level (also via ROULETTE_MIN_ACCESS_LEVEL)")
+	config.IntVar(&lookbackDays, "roulette-lookback-days", 90, "authors of MRs for this number of days are in the roulette pool, default of review_roulette.candidates.lookback_days (also via ROULETTE_LOOKBACK_DAYS)")
+}
+
+// Candidates tells who can be picked by the roulette
+type Candidates struct {
+	// BotUsernames are patterns of bots in addition to bot accounts of the platform
+	BotUsernames     []string `yaml:"bot_usernames"`
+	VacationStatuses []string `yaml:"vacation_statuses"`
+	EmojiStatuses    []string `yaml:"emoji_statuses"`
+	// MinAccessLevel is required from everybody except code owners
+	MinAccessLevel string `yaml:"min_access_level"`
+	LookbackDays   int    `yaml:"lookback_days"`
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// defaultCandidates returns the instance-wide settings given by flags
+func defaultCandidates() Candidates {
+	return Candidates{
+		BotUsernames:     splitList(botUsernames),
+		VacationStatuses: splitList(vacationStatuses),
+		EmojiStatuses:    splitList(emojiStatuses),
+		MinAccessLevel:   minAccessLevel,
+		LookbackDays:     lookbackDays,
+	}
+}
+
+func (s Candidates) isAvailable(c Candidate) bool {
+	if c.OutOfOffice != "" {
+		return false
+	}
+
+	status := strings.ToLower(c.Status)
+
+	for _, v := range s.VacationStatuses {
+		if strings.Contains(status, strings.ToLower(v)) {
+			return false
+		}
+	}
+
+	return !slices.Contains(s.EmojiStatuses, c.StatusEmoji)
+}
+
+func (s Candidates) isBot(c Candidate) bool {
+	if c.Bot {
+		return true
+	}
+
+	username := strings.ToLower(c.Username)
+	return slices.ContainsFunc(s.BotUsernames, func(p string) bool {
+		return matchPattern(strings.ToLower(p), username)
+	})
+}
+
+func (v *configValidator) candidates(c Candidates) {
+	v.globs(c.BotUsernames, "review_roulette", "candidates", "bot_usernames")
+
+	if !slices.Contains(accessLevels, c.MinAccessLevel) {
+		v.add(fmt.Sprintf("`review_roulette.candidates.min_access_level` must be one of %s, got %q", strings.Join(accessLevels, ", "), c.MinAccessLevel), "review_roulette", "candidates", "min_access_level")
+	}
+
+	v.atLeast(int64(c.LookbackDays), 1, "review_roulette", "candidates", "lookback_days")
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandidates_isBot(t *testing.T) {
+	settings := defaultCandidates()
+
+	tests := []struct {
+		name      string
+		candidate Candidate
+		want      bool
+	}{
+		{"bot account", Candidate{Username: "deploy", Bot: true}, true},
+		{"bot username", Candidate{Username: "merge-bot"}, true},
+		{"bot username in upper case", Candidate{Username: "RenovateBot"}, true},
+		{"service prefix", Candidate{Username: "jira-sync"}, true},
+		{"username containing bot", Candidate{Username: "abbott"}, false},
+		{"human", Candidate{Username: "alice"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, settings.isBot(tt.candidate))
+		})
+	}
+
+	settings.BotUsernames = []string{"ci-*"}
+	assert.False(t, settings.isBot(Candidate{Username: "merge-bot"}))
+	assert.True(t, settings.isBot(Candidate{Username: "ci-runner"}))
+}
+
+func TestCandidates_isAvailable(t *testing.T) {
+	settings := defaultCandidates()
+
+	tests := []struct {
+		name      string
+		candidate Candidate
+		want      bool
+	}{
+		{"no status", Candidate{Username: "alice"}, true},
+		{"vacation status", Candidate{Username: "alice", Status: "On Vacation till Monday"}, false},
+		{"emoji status", Candidate{Username: "alice", StatusEmoji: "palm_tree"}, false},
+		{"other emoji", Candidate{Username: "alice", StatusEmoji: "coffee"}, true},
+		{"out of office", Candidate{Username: "alice", OutOfOffice: "Vacation"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, settings.isAvailable(tt.candidate))
+		})
+	}
+
+	settings.VacationStatuses = []string{"Sabbatical"}
+	settings.EmojiStatuses = []string{"coffee"}
+	assert.True(t, settings.isAvailable(Candidate{Status: "vacation"}))
+	assert.False(t, settings.isAvailable(Candidate{Status: "sabbatical"}))
+	assert.False(t, settings.isAvailable(Candidate{StatusEmoji: "coffee"}))
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"ooo", "parental leave"}, splitList(" ooo, ,parental leave,"))
+	assert.Equal(t, []string{}, splitList(""))
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	extendsKey      = "extends"
+	maxExtendsDepth = 5
+)
+
+var (
+	defaultConfigPath string
+	configProjects    string
+
+	ExtendsError = &Error{"Config can't be extended"}
+)
+
+func init() {
+	config.StringVar(&defaultConfigPath, "default-config", "", "path to instance-wide default .mrbot.yaml, it is used when repository has no config (also via DEFAULT_CONFIG)")
+	config.StringVar(&configProjects, "config-projects", "", "comma list of paths or IDs of projects whose configs any project may extend, a project may always extend its own configs (also via CONFIG_PROJECTS)")
+}
+
+// canExtend reports whether configs of the project may be extended by the repository config,
+// other projects are not allowed, otherwise extends would read private files of any project the bot can see
+func (r *Request) canExtend(project string) bool {
+	if r.info != nil && project == strconv.FormatInt(r.info.ProjectID, 10) {
+		return true
+	}
+
+	return slices.ContainsFunc(splitList(configProjects), func(p string) bool { return strings.EqualFold(p, project) })
+}
+
+// ConfigRef points to a config file in another project, e.g. platform/mrbot-config:base.yaml@main
+type ConfigRef struct {
+	Project string
+	Path    string
+	Ref     string
+}
+
+func (c ConfigRef) String() string {
+	s := c.Project + ":" + c.Path
+	if c.Ref != "" {
+		s += "@" + c.Ref
+	}
+	return s
+}
+
+func ParseConfigRef(s string) (ConfigRef, error) {
+	ref := ConfigRef{}
+	s = strings.TrimSpace(s)
+
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		ref.Ref = s[i+1:]
+		s = s[:i]
+	}
+
+	if i := strings.Index(s, ":"); i >= 0 {
+		ref.Project = s[:i]
+		s = s[i+1:]
+	}
+
+	ref.Path = strings.TrimPrefix(s, "/")
+
+	if ref.Path == "" {
+		return ref, fmt.Errorf("%w: path is empty in %q", ExtendsError, s)
+	}
+
+	return ref, nil
+}
+
+func loadDefaultConfig() string {
+	if defaultConfigPath == "" {
+		return ""
+	}
+
+	content, err := os.ReadFile(defaultConfigPath)
+	if err != nil {
+		logger.Error("default config can't be read", "path", defaultConfigPath, "err", err)
+		return ""
+	}
+
+	return string(content)
+}
+
+// mergeConfigs deep-merges override into base, maps are merged key by key, lists and scalars are replaced
+func mergeConfigs(base, override map[string]any) map[string]any {
+	result := make(map[string]any, len(base)+len(override))
+
+	for k, v := range base {
+		result[k] = v
+	}
+
+	for k, v := range override {
+		baseMap, baseOk := result[k].(map[string]any)
+		overrideMap, overrideOk := v.(map[string]any)
+		if baseOk && overrideOk {
+			result[k] = mergeConfigs(baseMap, overrideMap)
+			continue
+		}
+
+		result[k] = v
+	}
+
+	return result
+}
+
+func (r *Request) fetchConfig(ref ConfigRef) (string, error) {
+	if ref.Project == "" {
+		if r.info == nil {
+			return "", fmt.Errorf("%w: project is not set in %q", ExtendsError, ref)
+		}
+		ref.Project = strconv.FormatInt(r.info.ProjectID, 10)
+	}
+
+	name := ref.String()
+
+	content, ok, err := cache.GetConfig(name)
+	if err != nil {
+		logger.Info("config cache is unavailable", "config", name, "err", err)
+	}
+
+	if ok {
+		return content, nil
+	}
+
+	b, err := r.provider.GetProjectFile(ref.Project, ref.Path, ref.Ref)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q can't be fetched: %w", ExtendsError, name, err)
+	}
+
+	if err := cache.SetConfig(name, string(b)); err != nil {
+		logger.Info("config can't be cached", "config", name, "err", err)
+	}
+
+	return string(b), nil
+}
+
+// resolveExtends loads the chain of extended configs and returns the merged config as a map,
+// project contains the content, refs without a project point to it
+func (r *Request) resolveExtends(content, project string, depth int, seen map[string]struct{}) (map[string]any, error) {
+	local := map[string]any{}
+	if err := yaml.Unmarshal([]byte(content), &local); err != nil {
+		return nil, err
+	}
+
+	extends, ok := local[extendsKey]
+	if !ok {
+		return local, nil
+	}
+
+	delete(local, extendsKey)
+
+	name, ok := extends.(string)
+	if !ok || strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: extends must be a string like group/project:path.yaml@ref", ExtendsError)
+	}
+
+	if depth >= maxExtendsDepth {
+		return nil, fmt.Errorf("%w: more than %d levels of extends", ExtendsError, maxExtendsDepth)
+	}
+
+	ref, err := ParseConfigRef(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Project == "" {
+		ref.Project = project
+	}
+
+	if !r.canExtend(ref.Project) {
+		return nil, fmt.Errorf("%w: project of %q isn't listed in -config-projects", ExtendsError, ref)
+	}
+
+	if _, ok := seen[ref.String()]; ok {
+		return nil, fmt.Errorf("%w: %q is extended recursively", ExtendsError, ref)
+	}
+	seen[ref.String()] = struct{}{}
+
+	baseContent, err := r.fetchConfig(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := r.resolveExtends(baseContent, ref.Project, depth+1, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeConfigs(base, local), nil
+}
+
+// expandConfig returns the repository config with all extended configs merged in,
+// extends of the repository config is kept for the report
+func (r *Request) expandConfig(content string) (string, error) {
+	local := map[string]any{}
+	if err := yaml.Unmarshal([]byte(content), &local); err != nil {
+		return "", err
+	}
+
+	extends, ok := local[extendsKey]
+	if !ok {
+		return content, nil
+	}
+
+	project := ""
+	if r.info != nil {
+		project = strconv.FormatInt(r.info.ProjectID, 10)
+	}
+
+	merged, err := r.resolveExtends(content, project, 0, map[string]struct{}{})
+	if err != nil {
+		return "", err
+	}
+
+	merged[extendsKey] = extends
+
+	b, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// ConfigReport describes the config of the MR and its problems. Only keys of the repository config are shown:
+// extended configs may come from projects which readers of MR can't see.
+func (r Request) ConfigReport() (string, error) {
+	const configText = `
+<details>
+<summary>
+Repository config:
+</summary>
+
+` + "```yaml\n%s```" + `
+
+</details>
+`
+
+	content := r.info.ConfigContent
+	if strings.TrimSpace(content) == "" {
+		content = loadDefaultConfig()
+	}
+
+	own := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(content), own); err != nil {
+		return "", err
+	}
+
+	b := []byte{}
+	if own.Kind != 0 {
+		var err error
+		if b, err = yaml.Marshal(own); err != nil {
+			return "", err
+		}
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("⚙️ **Merge Bot config**\n\n")
+
+	if r.config.Extends != "" {
+		fmt.Fprintf(builder, "ℹ️ Extends `%s`\n\n", r.config.Extends)
+	}
+
+	if r.config.AppliedBranchRule != "" {
+		fmt.Fprintf(builder, "ℹ️ Rules are overridden by branch_rules: `%s`\n\n", r.config.AppliedBranchRule)
+	}
+
+	if r.configFallback != "" {
+		fmt.Fprintf(builder, "⚠️ Config has values which can't be used, %s\n\n", r.configFallback)
+	}
+
+	if len(r.configIssues) == 0 {
+		builder.WriteString("✅ No problems found\n")
+	} else {
+		builder.WriteString("⚠️ Problems:\n")
+		for _, i := range r.configIssues {
+			fmt.Fprintf(builder, "- %s\n", i)
+		}
+	}
+
+	fmt.Fprintf(builder, configText, b)
+
+	return builder.String(), nil
+}
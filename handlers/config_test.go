@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfigRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    ConfigRef
+		wantErr bool
+	}{
+		{
+			name: "full",
+			s:    "platform/mrbot-config:base.yaml@main",
+			want: ConfigRef{Project: "platform/mrbot-config", Path: "base.yaml", Ref: "main"},
+		},
+		{
+			name: "default branch",
+			s:    "platform/mrbot-config:configs/base.yaml",
+			want: ConfigRef{Project: "platform/mrbot-config", Path: "configs/base.yaml"},
+		},
+		{
+			name: "same project",
+			s:    "base.yaml@v1",
+			want: ConfigRef{Path: "base.yaml", Ref: "v1"},
+		},
+		{
+			name:    "empty path",
+			s:       "platform/mrbot-config:@main",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfigRef(tt.s)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ExtendsError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mergeConfigs(t *testing.T) {
+	base := map[string]any{
+		"rules": map[string]any{
+			"min_approvals": 2,
+			"approvers":     []any{"alice", "bob"},
+			"title_regex":   "^JIRA-",
+		},
+		"auto_master_merge": true,
+	}
+	override := map[string]any{
+		"rules": map[string]any{
+			"approvers": []any{"carol"},
+		},
+		"auto_master_merge": false,
+	}
+
+	assert.Equal(t, map[string]any{
+		"rules": map[string]any{
+			"min_approvals": 2,
+			"approvers":     []any{"carol"},
+			"title_regex":   "^JIRA-",
+		},
+		"auto_master_merge": false,
+	}, mergeConfigs(base, override))
+}
+
+//nolint:errcheck
+func TestRequest_ParseConfig_Extends(t *testing.T) {
+	cache.Init()
+
+	defer func(projects string) { configProjects = projects }(configProjects)
+	configProjects = "Platform/mrbot-config, infra/configs"
+
+	files := map[string]string{
+		"platform/mrbot-config:base.yaml@main":   "extends: platform/mrbot-config:root.yaml\nrules: {min_approvals: 2, title_regex: '^JIRA-'}",
+		"platform/mrbot-config:root.yaml":        "rules: {approvers: [alice]}\nauto_master_merge: true",
+		"platform/mrbot-config:loop-a.yaml@main": "extends: platform/mrbot-config:loop-b.yaml@main",
+		"platform/mrbot-config:loop-b.yaml@main": "extends: platform/mrbot-config:loop-a.yaml@main",
+		"42:local-base.yaml":                     "rules: {min_approvals: 3}",
+		"platform/mrbot-config:relative.yaml":    "extends: root.yaml\nrules: {min_approvals: 5}",
+		"42:root.yaml":                           "rules: {approvers: [mallory]}",
+		"platform/mrbot-config:self.yaml":        "extends: self.yaml",
+		"42:self.yaml":                           "rules: {min_approvals: 6}",
+		"team/secrets:token.yaml":                "rules: {approvers: [eve]}",
+		"platform/mrbot-config:private.yaml":     "extends: team/secrets:token.yaml",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "chain of extends with local overrides",
+			content: "extends: platform/mrbot-config:base.yaml@main\nrules: {min_approvals: 1}",
+			check: func(t *testing.T, c *Config) {
+				assert.Equal(t, 1, c.Rules.MinApprovals)
+				assert.Equal(t, "^JIRA-", c.Rules.TitleRegex)
+				assert.Equal(t, []string{"alice"}, c.Rules.Approvers)
+				assert.True(t, c.AutoMasterMerge)
+				assert.True(t, c.Rules.AllowEmptyDescription)
+				assert.Equal(t, "platform/mrbot-config:base.yaml@main", c.Extends)
+			},
+		},
+		{
+			name:    "relative extends of extended config",
+			content: "extends: platform/mrbot-config:relative.yaml",
+			check: func(t *testing.T, c *Config) {
+				assert.Equal(t, 5, c.Rules.MinApprovals)
+				assert.Equal(t, []string{"alice"}, c.Rules.Approvers)
+			},
+		},
+		{
+			name:    "same path in another project",
+			content: "extends: platform/mrbot-config:self.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "same project",
+			content: "extends: local-base.yaml",
+			check: func(t *testing.T, c *Config) {
+				assert.Equal(t, 3, c.Rules.MinApprovals)
+			},
+		},
+		{
+			name:    "project which isn't allowed",
+			content: "extends: team/secrets:token.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "allowed config extends project which isn't allowed",
+			content: "extends: platform/mrbot-config:private.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "recursive extends",
+			content: "extends: platform/mrbot-config:loop-a.yaml@main",
+			wantErr: true,
+		},
+		{
+			name:    "missing remote config",
+			content: "extends: platform/mrbot-config:missing.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "wrong extends type",
+			content: "extends: [a, b]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{
+				provider: &testProvider{files: files},
+				info:     &MrInfo{ProjectID: 42},
+			}
+
+			got, err := r.ParseConfig(tt.content)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ExtendsError)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.check(t, got)
+		})
+	}
+}
+
+//nolint:errcheck
+func TestRequest_ParseConfig_Default(t *testing.T) {
+	cache.Init()
+
+	path := filepath.Join(t.TempDir(), "default.yaml")
+	if err := os.WriteFile(path, []byte("rules: {min_approvals: 4}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultConfigPath = path
+	defer func() { defaultConfigPath = "" }()
+
+	r := &Request{provider: &testProvider{}}
+
+	got, err := r.ParseConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, got.Rules.MinApprovals)
+
+	got, err = r.ParseConfig("rules: {min_approvals: 2}")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.Rules.MinApprovals)
+}
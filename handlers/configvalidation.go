@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/metrics"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configValidationMarker = "<!-- merge-bot:config-validation -->"
+	diffContextLines       = 2
+)
+
+// configChange is the result of validation of the config proposed by MR
+type configChange struct {
+	Deleted bool
+	// Extends of the proposed config, it isn't fetched because the source branch isn't trusted
+	Extends string
+	Issues  []ConfigIssue
+	Diff    []string
+}
+
+func effectiveConfig(c *Config) []string {
+	if c == nil {
+		return nil
+	}
+
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// lineDiff returns unified diff lines of a and b, unchanged lines are kept only around changes
+func lineDiff(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+
+	visible := make([]bool, len(ops))
+	changed := false
+	for k, op := range ops {
+		if op[0] == ' ' {
+			continue
+		}
+
+		changed = true
+		for c := max(0, k-diffContextLines); c <= min(len(ops)-1, k+diffContextLines); c++ {
+			visible[c] = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	diff := []string{}
+	for k, op := range ops {
+		if !visible[k] {
+			continue
+		}
+
+		if k > 0 && !visible[k-1] {
+			diff = append(diff, "@@")
+		}
+
+		diff = append(diff, op)
+	}
+
+	return diff
+}
+
+// loadConfigChange validates the config from the source branch, it returns nil if MR doesn't touch the config
+func (r *Request) loadConfigChange() (*configChange, error) {
+	changedFiles, err := r.getChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(changedFiles, configPath) {
+		return nil, nil
+	}
+
+	change := &configChange{}
+
+	sourceProjectID := r.info.SourceProjectID
+	if sourceProjectID == 0 {
+		sourceProjectID = r.info.ProjectID
+	}
+
+	content := ""
+	b, err := r.provider.GetProjectFile(strconv.FormatInt(sourceProjectID, 10), configPath, r.info.SourceBranch)
+	switch {
+	case errors.Is(err, NotFoundError):
+		change.Deleted = true
+	case err != nil:
+		return nil, fmt.Errorf("GetProjectFile returns error: %w", err)
+	default:
+		content = string(b)
+	}
+
+	// extended configs may be private, so both configs are compared by their own keys only
+	// and the bot token never fetches files named by the source branch
+	before, _ := r.parseLocalConfig(r.info.ConfigContent)
+
+	after, err := r.parseLocalConfig(content)
+	if err != nil {
+		configErr := &ConfigError{}
+		if errors.As(err, &configErr) {
+			change.Issues = configErr.Issues
+		} else {
+			change.Issues = []ConfigIssue{{Message: err.Error()}}
+		}
+	}
+
+	if after != nil {
+		change.Extends = after.Extends
+	}
+
+	change.Diff = lineDiff(effectiveConfig(before), effectiveConfig(after))
+
+	return change, nil
+}
+
+func configChangeText(change *configChange) string {
+	const diffText = `
+<details>
+<summary>
+Config diff:
+</summary>
+
+` + "```diff\n%s\n```" + `
+
+</details>
+`
+
+	builder := &strings.Builder{}
+	builder.WriteString(configValidationMarker)
+	builder.WriteString("\n")
+	fmt.Fprintf(builder, "⚙️ **`%s` is changed in this MR**\n\n", configPath)
+
+	if change.Deleted {
+		builder.WriteString("ℹ️ Config is deleted, the default config will be used after merge\n\n")
+	}
+
+	if change.Extends != "" {
+		fmt.Fprintf(builder, "ℹ️ Config extends `%s`, extended configs are not validated and not shown in the diff\n\n", change.Extends)
+	}
+
+	if len(change.Issues) == 0 {
+		builder.WriteString("✅ Config is valid\n")
+	} else {
+		fmt.Fprintf(builder, "❌ Config has %s:\n", english.Plural(len(change.Issues), "problem", ""))
+		for _, i := range change.Issues {
+			fmt.Fprintf(builder, "- %s\n", i)
+		}
+	}
+
+	if len(change.Diff) == 0 {
+		builder.WriteString("\nℹ️ Config is not changed\n")
+	} else {
+		fmt.Fprintf(builder, diffText, strings.Join(change.Diff, "\n"))
+	}
+
+	return builder.String()
+}
+
+// ValidateConfigChange comments on MR which changes the config with its problems and the diff
+func (r *Request) ValidateConfigChange() error {
+	if !r.config.ConfigValidation.Enabled {
+		return nil
+	}
+
+	change, err := r.loadConfigChange()
+	if err != nil {
+		return err
+	}
+
+	if change == nil {
+		return nil
+	}
+
+	metrics.BackgroundRunInc("config_validation")
+
+	return r.provider.LeaveOrUpdateComment(r.info.ProjectID, r.info.ID, configValidationMarker, configChangeText(change))
+}
+
+func (r *Request) checkConfigChange() (CheckResult, error) {
+	change, err := r.loadConfigChange()
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	if change == nil {
+		return CheckResult{Passed: true, Required: false, Message: "Config is not changed"}, nil
+	}
+
+	if len(change.Issues) > 0 {
+		return CheckResult{
+			Passed:   false,
+			Required: true,
+			Message:  fmt.Sprintf("Changed config has %s", english.Plural(len(change.Issues), "problem", "")),
+		}, nil
+	}
+
+	return CheckResult{Passed: true, Required: true, Message: "Changed config is valid"}, nil
+}
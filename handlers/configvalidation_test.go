@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+			want: nil,
+		},
+		{
+			name: "changed line",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "x", "c"},
+			want: []string{" a", "-b", "+x", " c"},
+		},
+		{
+			name: "context is limited",
+			a:    []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			b:    []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+			want: []string{"+0", " 1", " 2", "@@", " 7", " 8", "-9"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, lineDiff(tt.a, tt.b))
+		})
+	}
+}
+
+func TestRequest_ValidateConfigChange(t *testing.T) {
+	const current = `
+rules:
+  min_approvals: 3
+  approvers: []
+config_validation:
+  enabled: true
+`
+
+	tests := []struct {
+		name         string
+		config       string
+		changedFiles []string
+		proposed     *string
+		wantComment  bool
+		wantContains []string
+		wantMissing  []string
+		wantCheck    string
+	}{
+		{
+			name:         "config is not changed",
+			config:       current,
+			changedFiles: []string{"main.go"},
+			wantComment:  false,
+		},
+		{
+			name:         "valid change",
+			config:       current,
+			changedFiles: []string{"main.go", configPath},
+			proposed:     new("rules:\n  min_approvals: 2\n  approvers: []\n"),
+			wantComment:  true,
+			wantContains: []string{"✅ Config is valid", "-    min_approvals: 3\n+    min_approvals: 2"},
+			wantCheck:    "Changed config is valid ✅",
+		},
+		{
+			name:         "invalid change",
+			config:       current,
+			changedFiles: []string{configPath},
+			proposed:     new("rules:\n  min_aprovals: 2\n  approvers: []\n"),
+			wantComment:  true,
+			wantContains: []string{"❌ Config has 1 problem", "line 2: unknown key `rules.min_aprovals`", "+    min_approvals: 1"},
+			wantCheck:    "Changed config has 1 problem ❌",
+		},
+		{
+			name:         "config is deleted",
+			config:       current,
+			changedFiles: []string{configPath},
+			wantComment:  true,
+			wantContains: []string{"Config is deleted", "-    min_approvals: 3"},
+			wantCheck:    "Changed config is valid ✅",
+		},
+		{
+			name:         "extends is not fetched",
+			config:       current,
+			changedFiles: []string{configPath},
+			proposed:     new("extends: private/project:.mrbot.yaml\nrules:\n  min_approvals: 3\n  approvers: []\n"),
+			wantComment:  true,
+			wantContains: []string{"✅ Config is valid", "Config extends `private/project:.mrbot.yaml`", "+extends: private/project:.mrbot.yaml"},
+			wantMissing:  []string{"secret", "plugin_vars"},
+			wantCheck:    "Changed config is valid ✅",
+		},
+		{
+			name:         "validation is disabled by default",
+			config:       "rules:\n  approvers: []\n",
+			changedFiles: []string{configPath},
+			proposed:     new("rules:\n  min_approvals: -1\n"),
+			wantComment:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{
+				state:        "opened",
+				config:       tt.config,
+				sourceBranch: "feature",
+				changedFiles: tt.changedFiles,
+				files: map[string]string{
+					ConfigRef{Project: "private/project", Path: configPath}.String(): "plugin_vars:\n  token: secret\n",
+				},
+			}
+			if tt.proposed != nil {
+				provider.files[ConfigRef{Project: "1", Path: configPath, Ref: "feature"}.String()] = *tt.proposed
+			}
+
+			r := &Request{provider: provider}
+			if err := r.LoadInfoAndConfig(1, 2); err != nil {
+				t.Fatalf("LoadInfoAndConfig failed: %v", err)
+			}
+
+			assert.NoError(t, r.ValidateConfigChange())
+			assert.Equal(t, tt.wantComment, provider.commentCalled)
+			if tt.wantComment {
+				assert.Equal(t, configValidationMarker, provider.lastMarker)
+			}
+			for _, s := range tt.wantContains {
+				assert.Contains(t, provider.lastComment, s)
+			}
+			for _, s := range tt.wantMissing {
+				assert.NotContains(t, provider.lastComment, s)
+			}
+
+			// blocking checker uses the same validation
+			r.config.ConfigValidation.Blocking = true
+			_, text, err := r.IsValid()
+			assert.NoError(t, err)
+			if tt.wantCheck != "" {
+				assert.Contains(t, text, tt.wantCheck)
+			} else {
+				assert.NotContains(t, text, "Changed config")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	conflictWarningMarker = "<!-- merge-bot:conflict-warning -->"
+
+	// conflictChecks is how many MRs are rechecked at once, every recheck polls the provider for a while
+	conflictChecks = 4
+)
+
+// conflictWarningsTimeout bounds all checks after a merge, MRs which aren't checked in time are skipped
+var conflictWarningsTimeout = 2 * time.Minute
+
+type ConflictWarnings struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxMergeRequests is how many open MRs are checked after every merge
+	MaxMergeRequests int `yaml:"max_merge_requests"`
+}
+
+func conflictWarningText(author string, causedBy int64, target string, files []string) string {
+	builder := &strings.Builder{}
+	builder.WriteString(conflictWarningMarker + "\n")
+	fmt.Fprintf(builder, "⚠️ @%s, this MR has conflicts with `%s` since !%d was merged.\n\n", author, target, causedBy)
+
+	if len(files) > 0 {
+		builder.WriteString("Conflicting files:\n")
+
+		for _, f := range files {
+			fmt.Fprintf(builder, "- `%s`\n", f)
+		}
+
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("Merge the target branch and resolve the conflicts.\n")
+	return builder.String()
+}
+
+// WarnConflicts notifies authors of open MRs into the same target branch which have become conflicted with the merged MR.
+// At most conflictChecks MRs are rechecked at once and until conflictWarningsTimeout expires;
+// MRs labelled merge-bot:auto-update are warned by the update.
+func (r Request) WarnConflicts() error {
+	settings := r.config.ConflictWarnings
+	if !settings.Enabled {
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, conflictChecks)
+		deadline = time.Now().Add(conflictWarningsTimeout)
+		checked  = 0
+	)
+
+	for mr := range r.provider.FindMergeRequestsWithoutPipeline(r.info.ProjectID, r.info.TargetBranch, "") {
+		if checked >= settings.MaxMergeRequests {
+			break
+		}
+
+		if mr.ID == r.info.ID || slices.Contains(mr.Labels, autoUpdateLabel) {
+			continue
+		}
+
+		sem <- struct{}{}
+
+		if time.Now().After(deadline) {
+			<-sem
+			logger.Info("conflict warnings are stopped by timeout", "mergeID", r.info.ID, "checked", checked)
+			break
+		}
+
+		checked++
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			if err := r.warnConflict(mr); err != nil {
+				logger.Error("conflict warning fails", "mergeID", mr.ID, "err", err)
+			}
+		})
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// warnConflict warns the author of MR if it has become conflicted, conflicting files are found by a trial merge
+func (r Request) warnConflict(mr MR) error {
+	conflicted, err := r.provider.HasConflicts(r.info.ProjectID, mr.ID)
+	if err != nil {
+		return fmt.Errorf("HasConflicts returns error: %w", err)
+	}
+
+	if !conflicted {
+		// the author is warned again when MR gets conflicted next time
+		return cache.UnmarkConflict(r.info.ProjectID, mr.ID)
+	}
+
+	warned, err := cache.IsConflictMarked(r.info.ProjectID, mr.ID)
+	if err != nil || warned {
+		return err
+	}
+
+	files, err := r.provider.ConflictingFiles(r.info.ProjectID, mr.ID)
+	if err != nil {
+		// the author is warned without the files
+		logger.Error("conflicting files can't be found", "mergeID", mr.ID, "err", err)
+	}
+
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	message := conflictWarningText(mr.Author, r.info.ID, r.info.TargetBranch, files)
+	if err := r.provider.LeaveOrUpdateComment(r.info.ProjectID, mr.ID, conflictWarningMarker, message); err != nil {
+		return fmt.Errorf("LeaveOrUpdateComment returns error: %w", err)
+	}
+
+	return cache.MarkConflict(r.info.ProjectID, mr.ID, r.info.ID)
+}
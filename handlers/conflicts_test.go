@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_WarnConflicts(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	const projectID = 4800
+
+	provider := &testProvider{
+		mergeRequests: []MR{
+			{ID: 2, Author: "bob"},
+			{ID: 3, Author: "carol"},
+			{ID: 4, Author: "dave", Labels: []string{autoUpdateLabel}},
+			{ID: 5, Author: "erin"},
+		},
+		conflicts: map[int64]bool{2: true, 3: true, 4: true},
+		conflictingFiles: map[int64][]string{
+			2: {"api/handler.go", "README.md", "api/handler.go"},
+		},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: projectID, ID: 1, TargetBranch: "main"}, config: defaultConfig()}
+
+	// disabled by default
+	assert.NoError(t, r.WarnConflicts())
+	assert.False(t, provider.commentCalled)
+
+	r.config.ConflictWarnings.Enabled = true
+	assert.NoError(t, r.WarnConflicts())
+
+	assert.Equal(t, conflictWarningMarker, provider.lastMarker)
+	assert.Equal(t, map[int64]string{
+		2: conflictWarningMarker + "\n⚠️ @bob, this MR has conflicts with `main` since !1 was merged.\n\nConflicting files:\n- `README.md`\n- `api/handler.go`\n\nMerge the target branch and resolve the conflicts.\n",
+		3: conflictWarningMarker + "\n⚠️ @carol, this MR has conflicts with `main` since !1 was merged.\n\nMerge the target branch and resolve the conflicts.\n",
+	}, provider.comments)
+
+	for id, want := range map[int64]bool{2: true, 3: true, 4: false, 5: false} {
+		marked, err := cache.IsConflictMarked(projectID, id)
+		assert.NoError(t, err)
+		assert.Equal(t, want, marked, "MR !%d", id)
+	}
+
+	// the author isn't warned twice
+	provider.commentCalled = false
+	assert.NoError(t, r.WarnConflicts())
+	assert.False(t, provider.commentCalled)
+
+	// resolved conflicts reset the warning
+	provider.conflicts[2] = false
+	assert.NoError(t, r.WarnConflicts())
+
+	marked, err := cache.IsConflictMarked(projectID, 2)
+	assert.NoError(t, err)
+	assert.False(t, marked)
+
+	// MRs aren't checked after the timeout
+	defer func(timeout time.Duration) { conflictWarningsTimeout = timeout }(conflictWarningsTimeout)
+	conflictWarningsTimeout = -time.Second
+	provider.conflicts[2] = true
+	provider.commentCalled = false
+	assert.NoError(t, r.WarnConflicts())
+	assert.False(t, provider.commentCalled)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	ContributionAuthored = "authored"
+	ContributionApproved = "approved"
+
+	// commitsPerFile limits history requests, the latest commits are the most relevant
+	commitsPerFile = 5
+	// historyCalls limits API calls of the history of changed files per spin
+	historyCalls      = 40
+	expertiseHalfLife = time.Hour * 24 * 90
+	maxReasons        = 2
+)
+
+var contributionWeights = map[string]float64{
+	ContributionAuthored: 1,
+	ContributionApproved: 0.5,
+}
+
+type Expertise struct {
+	Enabled bool `yaml:"enabled"`
+	// Weight is how many assignments the best expert may have more than others and still be picked first
+	Weight   int `yaml:"weight"`
+	Days     int `yaml:"days"`
+	MaxFiles int `yaml:"max_files"`
+}
+
+// Contribution is a change of the path authored or approved by the user
+type Contribution struct {
+	Username string    `json:"username"`
+	Kind     string    `json:"kind"`
+	Path     string    `json:"path"`
+	At       time.Time `json:"at"`
+}
+
+// CallBudget limits API calls of one operation, it may be shared by goroutines
+type CallBudget struct {
+	mu   sync.Mutex
+	left int
+}
+
+func NewCallBudget(calls int) *CallBudget {
+	return &CallBudget{left: calls}
+}
+
+// Take spends a call, it returns false if the budget is spent
+func (b *CallBudget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.left <= 0 {
+		return false
+	}
+
+	b.left--
+	return true
+}
+
+type reasonKey struct {
+	kind string
+	path string
+}
+
+type reason struct {
+	reasonKey
+	count int
+	score float64
+}
+
+type expert struct {
+	score   float64
+	reasons map[reasonKey]*reason
+}
+
+func (r reason) String() string {
+	changes := "change"
+	if r.count > 1 {
+		changes = "changes"
+	}
+
+	return fmt.Sprintf("%s %d %s of `%s`", r.kind, r.count, changes, r.path)
+}
+
+// pathHistory returns contributions to the path for the last days, a partial history is returned with BudgetError
+// and isn't cached, so next spins complete it
+func (r Request) pathHistory(path string, days int, now time.Time, budget *CallBudget) ([]Contribution, error) {
+	since := now.AddDate(0, 0, -days)
+	contributions := []Contribution{}
+
+	ok, err := cache.GetPathHistory(r.info.ProjectID, path, days, &contributions)
+	if err != nil {
+		logger.Info("history cache is unavailable", "path", path, "err", err)
+	}
+
+	var budgetErr error
+
+	if !ok {
+		contributions, err = r.provider.ListPathContributions(r.info.ProjectID, path, since, commitsPerFile, budget)
+		switch {
+		case errors.Is(err, BudgetError):
+			budgetErr = err
+		case err != nil:
+			return nil, err
+		default:
+			if err := cache.SetPathHistory(r.info.ProjectID, path, days, contributions); err != nil {
+				logger.Info("history can't be cached", "path", path, "err", err)
+			}
+		}
+	}
+
+	return slices.DeleteFunc(contributions, func(c Contribution) bool {
+		return c.At.Before(since)
+	}), budgetErr
+}
+
+// scoreContributions sums contributions of every user, recent ones weigh more
+func scoreContributions(contributions []Contribution, now time.Time) map[string]*expert {
+	experts := map[string]*expert{}
+
+	for _, c := range contributions {
+		age := max(now.Sub(c.At), 0)
+		score := contributionWeights[c.Kind] * math.Pow(0.5, float64(age)/float64(expertiseHalfLife))
+
+		e, ok := experts[c.Username]
+		if !ok {
+			e = &expert{reasons: map[reasonKey]*reason{}}
+			experts[c.Username] = e
+		}
+
+		e.score += score
+
+		key := reasonKey{kind: c.Kind, path: c.Path}
+		rs, ok := e.reasons[key]
+		if !ok {
+			rs = &reason{reasonKey: key}
+			e.reasons[key] = rs
+		}
+
+		rs.count++
+		rs.score += score
+	}
+
+	return experts
+}
+
+func (e *expert) topReasons() []string {
+	reasons := make([]*reason, 0, len(e.reasons))
+	for _, rs := range e.reasons {
+		reasons = append(reasons, rs)
+	}
+
+	slices.SortFunc(reasons, func(a, b *reason) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.path, b.path)
+	})
+
+	result := make([]string, 0, maxReasons)
+	for _, rs := range reasons[:min(maxReasons, len(reasons))] {
+		result = append(result, rs.String())
+	}
+
+	return result
+}
+
+// loadExpertise scores candidates by history of the changed files, it returns false if history is unknown
+func (r Request) loadExpertise(gamblers []Candidate, now time.Time) bool {
+	settings := r.config.AssignReviewers.Expertise
+
+	changedFiles, err := r.getChangedFiles()
+	if err != nil {
+		logger.Info("changed files are unknown, roulette ignores expertise", "err", err)
+		return false
+	}
+
+	contributions := []Contribution{}
+	budget := NewCallBudget(historyCalls)
+
+	for _, f := range changedFiles[:min(settings.MaxFiles, len(changedFiles))] {
+		history, err := r.pathHistory(f, settings.Days, now, budget)
+		contributions = append(contributions, history...)
+
+		if errors.Is(err, BudgetError) {
+			// cached histories of the rest of files are used, the others are completed by next spins
+			logger.Debug("history calls are spent, expertise is partial", "path", f)
+			continue
+		}
+
+		if err != nil {
+			logger.Info("ListPathContributions returns error, roulette ignores expertise", "path", f, "err", err)
+			return false
+		}
+	}
+
+	experts := scoreContributions(contributions, now)
+
+	best := 0.0
+	for _, e := range experts {
+		best = max(best, e.score)
+	}
+
+	if best == 0 {
+		return true
+	}
+
+	for i := range gamblers {
+		e, ok := experts[gamblers[i].Username]
+		if !ok {
+			continue
+		}
+
+		gamblers[i].Expertise = e.score / best
+		gamblers[i].Reasons = e.topReasons()
+	}
+
+	return true
+}
+
+// balance is lower for people who have less work and know the changed code better
+func (c Candidate) balance(weight int) float64 {
+	return float64(c.OpenReviews+c.Count) - float64(weight)*c.Expertise
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scoreContributions(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	experts := scoreContributions([]Contribution{
+		{Username: "alice", Kind: ContributionAuthored, Path: "a.go", At: now},
+		{Username: "alice", Kind: ContributionAuthored, Path: "a.go", At: now.Add(-expertiseHalfLife)},
+		{Username: "alice", Kind: ContributionApproved, Path: "b.go", At: now},
+		{Username: "bob", Kind: ContributionApproved, Path: "a.go", At: now},
+	}, now)
+
+	assert.InDelta(t, 1+0.5+0.5, experts["alice"].score, 0.0001)
+	assert.InDelta(t, 0.5, experts["bob"].score, 0.0001)
+	assert.Equal(t, []string{"authored 2 changes of `a.go`", "approved 1 change of `b.go`"}, experts["alice"].topReasons())
+}
+
+func TestRequest_spinRoulette_Expertise(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	candidates := []Candidate{
+		{Username: "author"},
+		{Username: "alice"},
+		{Username: "bob"},
+		{Username: "carol"},
+	}
+
+	history := map[string][]Contribution{
+		"api/handler.go": {
+			{Username: "bob", Kind: ContributionAuthored, Path: "api/handler.go", At: now.AddDate(0, 0, -3)},
+			{Username: "bob", Kind: ContributionAuthored, Path: "api/handler.go", At: now.AddDate(0, 0, -10)},
+			{Username: "carol", Kind: ContributionApproved, Path: "api/handler.go", At: now.AddDate(0, 0, -3)},
+			{Username: "author", Kind: ContributionAuthored, Path: "api/handler.go", At: now.AddDate(0, 0, -1)},
+			// out of the window
+			{Username: "alice", Kind: ContributionAuthored, Path: "api/handler.go", At: now.AddDate(-1, 0, 0)},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		openReviews map[string]int
+		wantWinners []string
+	}{
+		{
+			name:        "expert wins",
+			openReviews: map[string]int{"alice": 0, "bob": 1, "carol": 1},
+			wantWinners: []string{"bob", "alice"},
+		},
+		{
+			name:        "busy expert loses",
+			openReviews: map[string]int{"alice": 0, "bob": 3, "carol": 1},
+			wantWinners: []string{"alice", "carol"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{
+				provider: &testProvider{
+					candidates:   candidates,
+					openReviews:  tt.openReviews,
+					changedFiles: []string{"api/handler.go"},
+					history:      history,
+				},
+				info:   &MrInfo{ProjectID: int64(350 + i), ID: 2, Author: "author"},
+				config: defaultConfig(),
+			}
+			r.config.AssignReviewers.Expertise.Enabled = true
+
+			result, err := r.spinRoulette(2)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWinners, result.Winners)
+
+			if result.Winners[0] == "bob" {
+				assert.Contains(t, result.String(), "🔎 Expertise:\n- @bob authored 2 changes of `api/handler.go`")
+			}
+		})
+	}
+}
+
+func TestRequest_pathHistory(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	provider := &testProvider{history: map[string][]Contribution{
+		"main.go": {{Username: "bob", Kind: ContributionAuthored, Path: "main.go", At: now.AddDate(0, 0, -3)}},
+	}}
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 360}}
+
+	history, err := r.pathHistory("main.go", 90, now, NewCallBudget(historyCalls))
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// the history of a longer period isn't taken from the cache of the shorter one
+	provider.history["main.go"] = append(provider.history["main.go"], Contribution{Username: "alice", Kind: ContributionAuthored, Path: "main.go", At: now.AddDate(0, 0, -200)})
+
+	history, err = r.pathHistory("main.go", 90, now, NewCallBudget(historyCalls))
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	history, err = r.pathHistory("main.go", 365, now, NewCallBudget(historyCalls))
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	// the history isn't loaded and cached when calls are spent
+	history, err = r.pathHistory("util.go", 90, now, NewCallBudget(0))
+	assert.ErrorIs(t, err, BudgetError)
+	assert.Empty(t, history)
+
+	provider.history["util.go"] = []Contribution{{Username: "carol", Kind: ContributionAuthored, Path: "util.go", At: now.AddDate(0, 0, -1)}}
+	history, err = r.pathHistory("util.go", 90, now, NewCallBudget(historyCalls))
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestCallBudget_Take(t *testing.T) {
+	budget := NewCallBudget(2)
+	assert.True(t, budget.Take())
+	assert.True(t, budget.Take())
+	assert.False(t, budget.Take())
+}
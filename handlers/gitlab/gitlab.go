@@ -2,12 +2,16 @@ package gitlab
 
 import (
 	"bytes"
+	"cmp"
 	b64 "encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"iter"
+	"math"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/gasoid/merge-bot/v3/cache"
@@ -32,12 +36,26 @@ var (
 	gitlabToken string
 	gitlabURL   string
 	maxRepoSize string
+
+	accessLevels = map[string]gitlab.AccessLevelValue{
+		handlers.AccessGuest:      gitlab.GuestPermissions,
+		handlers.AccessReporter:   gitlab.ReporterPermissions,
+		handlers.AccessDeveloper:  gitlab.DeveloperPermissions,
+		handlers.AccessMaintainer: gitlab.MaintainerPermissions,
+		handlers.AccessOwner:      gitlab.OwnerPermissions,
+	}
+
+	// mergeStatusChecking are detailed merge statuses while GitLab checks whether MR can be merged
+	mergeStatusChecking = []string{"unchecked", "checking", "preparing", "approvals_syncing"}
 )
 
 const (
 	tokenUsername = "oauth2"
-	findMRSize    = 10
+	findMRSize    = 50
 	// sortDesc              = "desc"
+
+	conflictCheckAttempts = 5
+	conflictCheckDelay    = 3 * time.Second
 )
 
 type GitlabProvider struct {
@@ -55,33 +73,63 @@ func (g GitlabProvider) loadMR(projectID, mergeID int64) (*gitlab.MergeRequest,
 	return mr, nil
 }
 
-func (g GitlabProvider) UpdateFromMaster(projectID, mergeID int64) error {
-	mr, err := g.loadMR(projectID, mergeID)
-	if err != nil {
-		return err
-	}
-
+// repositoryURL returns the URL to clone the project, RepoSizeError if the repository is bigger than -gitlab-max-repo-size
+func (g GitlabProvider) repositoryURL(projectID int64) (string, error) {
 	project, _, err := g.client.Projects.GetProject(
 		projectID,
 		&gitlab.GetProjectOptions{Statistics: new(true)},
 	)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	bytes, err := humanize.ParseBytes(maxRepoSize)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if uint64(project.Statistics.RepositorySize) > bytes {
-		return handlers.RepoSizeError
+		return "", handlers.RepoSizeError
+	}
+
+	return project.HTTPURLToRepo, nil
+}
+
+func (g GitlabProvider) UpdateFromMaster(projectID, mergeID int64) error {
+	mr, err := g.loadMR(projectID, mergeID)
+	if err != nil {
+		return err
+	}
+
+	repoURL, err := g.repositoryURL(projectID)
+	if err != nil {
+		return err
 	}
 
 	return handlers.MergeMaster(
 		tokenUsername,
 		gitlabToken,
-		project.HTTPURLToRepo,
+		repoURL,
+		mr.SourceBranch,
+		mr.TargetBranch,
+	)
+}
+
+func (g GitlabProvider) ConflictingFiles(projectID, mergeID int64) ([]string, error) {
+	mr, err := g.loadMR(projectID, mergeID)
+	if err != nil {
+		return nil, err
+	}
+
+	repoURL, err := g.repositoryURL(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return handlers.ConflictingFiles(
+		tokenUsername,
+		gitlabToken,
+		repoURL,
 		mr.SourceBranch,
 		mr.TargetBranch,
 	)
@@ -188,6 +236,99 @@ func (g *GitlabProvider) LeaveComment(projectID, mergeID int64, message string)
 	return err
 }
 
+func (g *GitlabProvider) LeaveOrUpdateComment(projectID, mergeID int64, marker, message string) error {
+	const batch int64 = 50
+
+	// a note which can't be checked may exist, a new one would duplicate it
+	notes, err := g.listMergeRequestNotes(projectID, mergeID, batch)
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		if note.System || note.Author.ID != g.currentUserID {
+			continue
+		}
+
+		if !strings.Contains(note.Body, marker) {
+			continue
+		}
+
+		if note.Body == message {
+			return nil
+		}
+
+		_, _, err := g.client.Notes.UpdateMergeRequestNote(
+			projectID,
+			mergeID,
+			note.ID,
+			&gitlab.UpdateMergeRequestNoteOptions{Body: &message},
+		)
+
+		return err
+	}
+
+	return g.LeaveComment(projectID, mergeID, message)
+}
+
+func (g *GitlabProvider) LastComment(projectID, mergeID int64, marker string) (*handlers.Comment, error) {
+	const batch int64 = 50
+
+	notes, err := collect(func(page, perPage int64) ([]*gitlab.Note, *gitlab.Response, error) {
+		return g.client.Notes.ListMergeRequestNotes(projectID, mergeID, &gitlab.ListMergeRequestNotesOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+			OrderBy:     new("created_at"),
+			Sort:        new("desc"),
+		})
+	}, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, note := range notes {
+		if note.System || note.Author.ID != g.currentUserID || !strings.Contains(note.Body, marker) {
+			continue
+		}
+
+		comment := &handlers.Comment{Body: note.Body}
+		if note.CreatedAt != nil {
+			comment.CreatedAt = *note.CreatedAt
+		}
+
+		return comment, nil
+	}
+
+	return nil, nil
+}
+
+func (g *GitlabProvider) UpsertIssue(projectID int64, title, description string) error {
+	issues, _, err := g.client.Issues.ListProjectIssues(projectID, &gitlab.ListProjectIssuesOptions{
+		State:    new("opened"),
+		AuthorID: &g.currentUserID,
+		Search:   &title,
+		In:       new("title"),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		if issue.Title != title {
+			continue
+		}
+
+		if issue.Description == description {
+			return nil
+		}
+
+		_, _, err := g.client.Issues.UpdateIssue(projectID, issue.IID, &gitlab.UpdateIssueOptions{Description: &description})
+		return err
+	}
+
+	_, _, err = g.client.Issues.CreateIssue(projectID, &gitlab.CreateIssueOptions{Title: &title, Description: &description})
+	return err
+}
+
 func (g *GitlabProvider) AwardEmoji(projectID, mergeID, noteID int64, emoji string) error {
 	_, _, err := g.client.AwardEmoji.CreateMergeRequestAwardEmojiOnNote(
 		projectID, mergeID, noteID,
@@ -250,14 +391,87 @@ func (g *GitlabProvider) IsValid(projectID, mergeID int64) (bool, error) {
 	return !g.mr.HasConflicts, nil
 }
 
-func (g *GitlabProvider) GetFile(projectID int64, path string) ([]byte, error) {
+// projectHead returns the last commit of the default branch, it is cached until the branch is pushed
+func (g *GitlabProvider) projectHead(projectID int64) (*cache.ProjectHead, error) {
+	head, ok, err := cache.GetProjectHead(projectID)
+	if err != nil {
+		logger.Info("project cache is unavailable", "projectId", projectID, "err", err)
+	}
+
+	if ok {
+		return head, nil
+	}
+
 	project, _, err := g.client.Projects.GetProject(projectID, &gitlab.GetProjectOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	gitlabFile, _, err := g.client.RepositoryFiles.GetFile(projectID, path, &gitlab.GetFileOptions{Ref: &project.DefaultBranch})
+	branch, _, err := g.client.Branches.GetBranch(projectID, project.DefaultBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	head = &cache.ProjectHead{DefaultBranch: project.DefaultBranch, SHA: branch.Commit.ID}
+	if err := cache.SetProjectHead(projectID, *head); err != nil {
+		logger.Info("project head can't be cached", "projectId", projectID, "err", err)
+	}
+
+	return head, nil
+}
+
+// GetFile returns the file from the default branch, files are cached by the commit of the branch
+func (g *GitlabProvider) GetFile(projectID int64, path string) ([]byte, error) {
+	head, err := g.projectHead(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok, err := cache.GetFile(projectID, head.SHA, path)
+	if err != nil {
+		logger.Info("file cache is unavailable", "projectId", projectID, "path", path, "err", err)
+	}
+
+	if ok {
+		if !file.Exists {
+			return nil, fmt.Errorf("%w: %s@%s", handlers.NotFoundError, path, head.SHA)
+		}
+
+		return []byte(file.Content), nil
+	}
+
+	b, fileErr := g.getFile(projectID, path, head.SHA)
+	if fileErr != nil && !errors.Is(fileErr, handlers.NotFoundError) {
+		return nil, fileErr
+	}
+
+	if err := cache.SetFile(projectID, head.SHA, path, cache.File{Content: string(b), Exists: fileErr == nil}); err != nil {
+		logger.Info("file can't be cached", "projectId", projectID, "path", path, "err", err)
+	}
+
+	return b, fileErr
+}
+
+func (g *GitlabProvider) GetProjectFile(project, path, ref string) ([]byte, error) {
+	return g.getFile(project, path, ref)
+}
+
+func (g *GitlabProvider) getFile(pid any, path, ref string) ([]byte, error) {
+	if ref == "" {
+		project, _, err := g.client.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		ref = project.DefaultBranch
+	}
+
+	gitlabFile, resp, err := g.client.RepositoryFiles.GetFile(pid, path, &gitlab.GetFileOptions{Ref: &ref})
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s@%s", handlers.NotFoundError, path, ref)
+		}
+
 		return nil, err
 	}
 
@@ -284,8 +498,14 @@ func (g *GitlabProvider) GetMRInfo(projectID, mergeID int64, configPath string)
 	info.Labels = g.mr.Labels
 	info.TargetBranch = g.mr.TargetBranch
 	info.SourceBranch = g.mr.SourceBranch
+	info.SourceProjectID = g.mr.SourceProjectID
 	info.Author = g.mr.Author.Username
 
+	if g.mr.State == "merged" {
+		// fast-forward merges leave neither merge nor squash commit
+		info.MergedSHA = cmp.Or(g.mr.MergeCommitSHA, g.mr.SquashCommitSHA, g.mr.SHA)
+	}
+
 	for _, r := range g.mr.Reviewers {
 		info.Reviewers = append(info.Reviewers, r.Username)
 	}
@@ -312,6 +532,10 @@ func (g *GitlabProvider) GetMRInfo(projectID, mergeID int64, configPath string)
 	}
 
 	if g.mr.HeadPipeline != nil {
+		info.PipelineID = g.mr.HeadPipeline.ID
+		info.PipelineStatus = g.mr.HeadPipeline.Status
+		info.PipelineURL = g.mr.HeadPipeline.WebURL
+
 		report, _, err := g.client.Pipelines.GetPipelineTestReport(projectID, g.mr.HeadPipeline.IID)
 		if err != nil {
 			logger.Debug("GetPipelineTestReport returns error, but i am tolerating this issue", "error", err)
@@ -366,7 +590,7 @@ func (g GitlabProvider) ListBranches(projectID, size int64, protected bool) iter
 				}
 			}
 
-			if !yield(handlers.StaleBranch{Name: b.Name, LastUpdated: *b.Commit.CreatedAt, Protected: b.Protected}) {
+			if !yield(handlers.StaleBranch{Name: b.Name, Commit: b.Commit.ID, AuthorName: b.Commit.AuthorName, AuthorEmail: b.Commit.AuthorEmail, LastUpdated: *b.Commit.CreatedAt, Protected: b.Protected}) {
 				return
 			}
 		}
@@ -378,6 +602,127 @@ func (g *GitlabProvider) DeleteBranch(projectID int64, name string) error {
 	return err
 }
 
+// LastCommitter returns the committer of the head of the branch, the username is found by the public email
+func (g GitlabProvider) LastCommitter(projectID int64, branch string) (*handlers.Committer, error) {
+	b, _, err := g.client.Branches.GetBranch(projectID, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Commit == nil {
+		return nil, fmt.Errorf("%w: branch %s has no commits", handlers.NotFoundError, branch)
+	}
+
+	committer := &handlers.Committer{Name: b.Commit.CommitterName, Email: b.Commit.CommitterEmail}
+	committer.Username = g.usernameByEmail(committer.Email)
+
+	return committer, nil
+}
+
+// usernameByEmail finds the user by the public email, it returns empty string if the user isn't found
+// usernameByEmail returns the username of the commit email, empty if it's unknown; found usernames are cached
+func (g GitlabProvider) usernameByEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+
+	if username, ok, err := cache.GetUsernameByEmail(email); ok {
+		return username
+	} else if err != nil {
+		logger.Debug("emails cache is unavailable", "email", email, "err", err)
+	}
+
+	users, _, err := g.client.Users.ListUsers(&gitlab.ListUsersOptions{Search: &email})
+	if err != nil {
+		logger.Debug("user can't be found", "email", email, "err", err)
+		return ""
+	}
+
+	username := ""
+	if len(users) == 1 {
+		username = users[0].Username
+	}
+
+	if err := cache.SetUsernameByEmail(email, username); err != nil {
+		logger.Debug("username can't be cached", "email", email, "err", err)
+	}
+
+	return username
+}
+
+func (g *GitlabProvider) CommentCommit(projectID int64, sha, message string) error {
+	_, _, err := g.client.Commits.PostCommitComment(projectID, sha, &gitlab.PostCommitCommentOptions{Note: &message})
+	return err
+}
+
+func (g *GitlabProvider) CompareBranch(projectID int64, branch string) (*handlers.BranchDiff, error) {
+	head, err := g.projectHead(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	compare, _, err := g.client.Repositories.Compare(projectID, &gitlab.CompareOptions{From: &head.DefaultBranch, To: &branch})
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &handlers.BranchDiff{Unmerged: len(compare.Commits)}
+
+	for _, c := range compare.Commits {
+		if c.CreatedAt == nil {
+			continue
+		}
+
+		if diff.FirstCommitAt.IsZero() || c.CreatedAt.Before(diff.FirstCommitAt) {
+			diff.FirstCommitAt = *c.CreatedAt
+		}
+	}
+
+	return diff, nil
+}
+
+// alreadyExists reports whether GitLab refused to create a branch or a tag because of its name
+func alreadyExists(resp *gitlab.Response, err error) bool {
+	return resp != nil && resp.StatusCode == http.StatusBadRequest && strings.Contains(err.Error(), "already exists")
+}
+
+func (g *GitlabProvider) CreateBranch(projectID int64, name, ref string) error {
+	_, resp, err := g.client.Branches.CreateBranch(projectID, &gitlab.CreateBranchOptions{Branch: &name, Ref: &ref})
+	if err != nil && alreadyExists(resp, err) {
+		return fmt.Errorf("%w: branch %s", handlers.AlreadyExistsError, name)
+	}
+
+	return err
+}
+
+func (g *GitlabProvider) CreateTag(projectID int64, name, ref string) error {
+	_, resp, err := g.client.Tags.CreateTag(projectID, &gitlab.CreateTagOptions{TagName: &name, Ref: &ref})
+	if err != nil && alreadyExists(resp, err) {
+		return fmt.Errorf("%w: tag %s", handlers.AlreadyExistsError, name)
+	}
+
+	return err
+}
+
+func (g GitlabProvider) ListTags(projectID int64, prefix string) ([]string, error) {
+	const batch int64 = 100
+
+	// ^ makes GitLab search by prefix
+	found, err := g.listTags(projectID, batch, "^"+prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, t := range found {
+		if strings.HasPrefix(t.Name, prefix) {
+			tags = append(tags, t.Name)
+		}
+	}
+
+	return tags, nil
+}
+
 func (g GitlabProvider) ListMergeRequests(projectID, size int64, protected bool) iter.Seq[handlers.MR] {
 	listMr := g.listMergeRequests(projectID, size,
 		&gitlab.ListProjectMergeRequestsOptions{
@@ -400,11 +745,19 @@ func (g GitlabProvider) ListMergeRequests(projectID, size int64, protected bool)
 				}
 			}
 
+			author := ""
+			if mr.Author != nil {
+				author = mr.Author.Username
+			}
+
 			if !yield(handlers.MR{
 				ID:          mr.IID,
 				Labels:      mr.Labels,
 				Branch:      mr.SourceBranch,
+				SHA:         mr.SHA,
 				Protected:   b.Protected,
+				Draft:       mr.Draft,
+				Author:      author,
 				LastUpdated: *mr.UpdatedAt}) {
 				return
 			}
@@ -412,27 +765,61 @@ func (g GitlabProvider) ListMergeRequests(projectID, size int64, protected bool)
 	}
 }
 
-func (g GitlabProvider) FindMergeRequests(projectID int64, targetBranch, label string) ([]handlers.MR, error) {
-	mrs := make([]handlers.MR, 0)
+func (g GitlabProvider) FindMergeRequests(projectID int64, targetBranch, label string) iter.Seq[handlers.MR] {
+	return g.findMergeRequests(projectID, targetBranch, label, true)
+}
 
-	listMr := g.listMergeRequests(projectID, findMRSize,
-		&gitlab.ListProjectMergeRequestsOptions{
-			State:        new("opened"),
-			Labels:       &gitlab.LabelOptions{label},
-			TargetBranch: &targetBranch,
-		})
+func (g GitlabProvider) FindMergeRequestsWithoutPipeline(projectID int64, targetBranch, label string) iter.Seq[handlers.MR] {
+	return g.findMergeRequests(projectID, targetBranch, label, false)
+}
 
-	for mr := range listMr {
-		mrs = append(mrs, handlers.MR{
-			ID:          mr.IID,
-			Labels:      mr.Labels,
-			Branch:      mr.SourceBranch,
-			LastUpdated: *mr.UpdatedAt})
+// findMergeRequests loads every MR of the list to get its pipeline status if withPipeline is set
+func (g GitlabProvider) findMergeRequests(projectID int64, targetBranch, label string, withPipeline bool) iter.Seq[handlers.MR] {
+	options := &gitlab.ListProjectMergeRequestsOptions{
+		State:        new("opened"),
+		TargetBranch: &targetBranch,
 	}
 
-	logger.Debug("FindMergeRequests", "mrs", mrs)
+	if label != "" {
+		options.Labels = &gitlab.LabelOptions{label}
+	}
 
-	return mrs, nil
+	listMr := g.listMergeRequests(projectID, findMRSize, options)
+
+	return func(yield func(handlers.MR) bool) {
+		for mr := range listMr {
+			status := ""
+
+			if withPipeline {
+				// the list has no pipelines
+				full, err := g.loadMR(projectID, mr.IID)
+				if err != nil {
+					logger.Error("GetMergeRequest fails", "err", err)
+					continue
+				}
+
+				if full.HeadPipeline != nil {
+					status = full.HeadPipeline.Status
+				}
+			}
+
+			author := ""
+			if mr.Author != nil {
+				author = mr.Author.Username
+			}
+
+			if !yield(handlers.MR{
+				ID:             mr.IID,
+				Labels:         mr.Labels,
+				Branch:         mr.SourceBranch,
+				SHA:            mr.SHA,
+				Author:         author,
+				PipelineStatus: status,
+				LastUpdated:    *mr.UpdatedAt}) {
+				return
+			}
+		}
+	}
 }
 
 func (g GitlabProvider) CreateLabel(projectID int64, name, color string) error {
@@ -481,6 +868,74 @@ func (g GitlabProvider) AssignLabel(projectID, mergeID int64, name, color string
 	return nil
 }
 
+func (g GitlabProvider) RemoveLabel(projectID, mergeID int64, name string) error {
+	if _, _, err := g.client.MergeRequests.UpdateMergeRequest(
+		projectID,
+		mergeID,
+		&gitlab.UpdateMergeRequestOptions{RemoveLabels: &gitlab.LabelOptions{name}}); err != nil {
+		return fmt.Errorf("could't update mergeRequest: %w", err)
+	}
+	return nil
+}
+
+func (g GitlabProvider) CloseMergeRequest(projectID, mergeID int64) error {
+	if _, _, err := g.client.MergeRequests.UpdateMergeRequest(
+		projectID,
+		mergeID,
+		&gitlab.UpdateMergeRequestOptions{StateEvent: new("close")}); err != nil {
+		return fmt.Errorf("could't close mergeRequest: %w", err)
+	}
+	return nil
+}
+
+func (g GitlabProvider) SetDraft(projectID, mergeID int64) error {
+	mr, err := g.loadMR(projectID, mergeID)
+	if err != nil {
+		return err
+	}
+
+	if mr.Draft {
+		return nil
+	}
+
+	if _, _, err := g.client.MergeRequests.UpdateMergeRequest(
+		projectID,
+		mergeID,
+		&gitlab.UpdateMergeRequestOptions{Title: new("Draft: " + mr.Title)}); err != nil {
+		return fmt.Errorf("could't update mergeRequest: %w", err)
+	}
+	return nil
+}
+
+// HasConflicts asks GitLab to recheck the merge status of MR and polls it until the check is done
+func (g GitlabProvider) HasConflicts(projectID, mergeID int64) (bool, error) {
+	options := &gitlab.ListProjectMergeRequestsOptions{
+		IIDs:                   &[]int64{mergeID},
+		WithMergeStatusRecheck: new(true),
+	}
+
+	for attempt := range conflictCheckAttempts {
+		if attempt > 0 {
+			time.Sleep(conflictCheckDelay)
+		}
+
+		mrs, _, err := g.client.MergeRequests.ListProjectMergeRequests(projectID, options)
+		if err != nil {
+			return false, err
+		}
+
+		if len(mrs) == 0 {
+			return false, handlers.NotFoundError
+		}
+
+		if !slices.Contains(mergeStatusChecking, mrs[0].DetailedMergeStatus) {
+			return mrs[0].HasConflicts, nil
+		}
+	}
+
+	return false, handlers.CheckingError
+}
+
 func (g GitlabProvider) RerunPipeline(projectID, pipelineID int64, ref string) (string, error) {
 	pipelineVars, _, err := g.client.Pipelines.GetPipelineVariables(projectID, pipelineID)
 	if err != nil {
@@ -503,6 +958,40 @@ func (g GitlabProvider) RerunPipeline(projectID, pipelineID int64, ref string) (
 	return pipeline.WebURL, nil
 }
 
+func (g GitlabProvider) ListFailedJobs(projectID, pipelineID int64) ([]handlers.Job, error) {
+	const batch int64 = 50
+
+	failed, err := g.listPipelineJobs(projectID, pipelineID, batch, &gitlab.ListJobsOptions{
+		Scope: &[]gitlab.BuildStateValue{gitlab.Failed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []handlers.Job{}
+	for _, j := range failed {
+		jobs = append(jobs, handlers.Job{
+			ID:            j.ID,
+			Name:          j.Name,
+			Stage:         j.Stage,
+			WebURL:        j.WebURL,
+			FailureReason: j.FailureReason,
+			AllowFailure:  j.AllowFailure,
+		})
+	}
+
+	return jobs, nil
+}
+
+func (g GitlabProvider) GetJobTrace(projectID, jobID int64) ([]byte, error) {
+	trace, _, err := g.client.Jobs.GetTraceFile(projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(trace)
+}
+
 func (g GitlabProvider) GetRawDiffs(projectID, mergeID int64) ([]byte, error) {
 	result, _, err := g.client.MergeRequests.ShowMergeRequestRawDiffs(projectID, mergeID, &gitlab.ShowMergeRequestRawDiffsOptions{})
 	if err != nil {
@@ -512,14 +1001,16 @@ func (g GitlabProvider) GetRawDiffs(projectID, mergeID int64) ([]byte, error) {
 	return result, nil
 }
 
-func (g GitlabProvider) getChangedFiles(projectID, mergeID int64) ([]string, error) {
-	result, _, err := g.client.MergeRequests.ListMergeRequestDiffs(projectID, mergeID, &gitlab.ListMergeRequestDiffsOptions{})
+func (g GitlabProvider) GetChangedFiles(projectID, mergeID int64) ([]string, error) {
+	const batch int64 = 100
+
+	diffs, err := g.listMergeRequestDiffs(projectID, mergeID, batch)
 	if err != nil {
 		return nil, err
 	}
 
-	changedFiles := make([]string, 0, len(result))
-	for _, l := range result {
+	changedFiles := []string{}
+	for _, l := range diffs {
 		if l.NewPath == l.OldPath {
 			changedFiles = append(changedFiles, l.NewPath)
 			continue
@@ -542,14 +1033,14 @@ func (g GitlabProvider) codeOwners(projectID, mergeID int64) (map[string]struct{
 
 	b, err := g.GetFile(projectID, "CODEOWNERS")
 	if err != nil {
-		if errors.Is(err, gitlab.ErrNotFound) {
+		if errors.Is(err, handlers.NotFoundError) {
 			return nil, nil
 		}
 
 		return nil, err
 	}
 
-	changedFiles, err := g.getChangedFiles(projectID, mergeID)
+	changedFiles, err := g.GetChangedFiles(projectID, mergeID)
 	if err != nil {
 		return nil, err
 	}
@@ -594,14 +1085,315 @@ func (g GitlabProvider) AssignReviewers(projectID, mergeID int64, users []string
 	return err
 }
 
-func (g GitlabProvider) GetContributors(projectID, mergeID int64) ([]handlers.Candidate, error) {
+// localTimeOffset turns local time of the user profile like "3:04 PM" into UTC offset like UTC+02:00
+func localTimeOffset(localTime string, now time.Time) (string, bool) {
+	t, err := time.Parse(time.Kitchen, strings.ReplaceAll(localTime, " ", ""))
+	if err != nil {
+		return "", false
+	}
+
+	now = now.UTC()
+	minutes := (t.Hour()*60 + t.Minute()) - (now.Hour()*60 + now.Minute())
+
+	// local time may be on another day
+	if minutes > 14*60 {
+		minutes -= 24 * 60
+	}
+	if minutes < -12*60 {
+		minutes += 24 * 60
+	}
+
+	// profiles show minutes, timezones are aligned to quarters of hour
+	minutes = int(math.Round(float64(minutes)/15)) * 15
+
+	sign := "+"
+	if minutes < 0 {
+		sign, minutes = "-", -minutes
+	}
+
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, minutes/60, minutes%60), true
+}
+
+// userProfile returns UTC offset of the user and whether the user is a bot,
+// GitLab API exposes only local time of users who set a timezone
+func (g GitlabProvider) userProfile(userID int64) cache.User {
+	cached, ok, err := cache.GetUser(userID)
+	if err != nil {
+		logger.Info("users cache is unavailable", "userId", userID, "err", err)
+	}
+
+	if ok {
+		return *cached
+	}
+
+	req, err := g.client.NewRequest(http.MethodGet, fmt.Sprintf("users/%d", userID), nil, nil)
+	if err != nil {
+		return cache.User{}
+	}
+
+	user := struct {
+		LocalTime string `json:"local_time"`
+		Bot       bool   `json:"bot"`
+	}{}
+
+	if _, err := g.client.Do(req, &user); err != nil {
+		logger.Debug("user can't be fetched, timezone is unknown", "userId", userID, "err", err)
+		return cache.User{}
+	}
+
+	profile := cache.User{Bot: user.Bot}
+	profile.Timezone, _ = localTimeOffset(user.LocalTime, time.Now())
+
+	if err := cache.SetUser(userID, profile); err != nil {
+		logger.Info("user can't be cached", "userId", userID, "err", err)
+	}
+
+	return profile
+}
+
+// GetGroupMembers returns active members of the group including inherited ones
+func (g GitlabProvider) GetGroupMembers(group string) ([]string, error) {
+	const batch int64 = 100
+
+	// unknown groups must not look like empty ones
+	list, err := g.listAllGroupMembers(group, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []string{}
+	for _, m := range list {
+		if m.State != "active" {
+			continue
+		}
+
+		// IDs of members are needed to look them up among project members
+		if err := cache.SetUserID(m.Username, m.ID); err != nil {
+			logger.Info("user id can't be cached", "username", m.Username, "err", err)
+		}
+
+		members = append(members, m.Username)
+	}
+
+	return members, nil
+}
+
+// ListPathContributions returns authors of recent commits which changed the path and approvers of their merged MRs,
+// commit authors are found by public emails, the author of MR is credited for commits of unknown authors
+// ListPathContributions credits authors of the latest commits of the path and approvers of their merged MRs,
+// every API call spends the budget, the history found so far is returned with BudgetError when it is spent
+func (g GitlabProvider) ListPathContributions(projectID int64, path string, since time.Time, limit int, budget *handlers.CallBudget) ([]handlers.Contribution, error) {
+	if !budget.Take() {
+		return nil, handlers.BudgetError
+	}
+
+	commits, _, err := g.client.Commits.ListCommits(projectID, &gitlab.ListCommitsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: int64(limit)},
+		Path:        &path,
+		Since:       &since,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contributions := []handlers.Contribution{}
+	seen := map[int64]struct{}{}
+	usernames := map[string]string{}
+
+	for _, c := range commits {
+		username, ok := usernames[c.AuthorEmail]
+		if !ok {
+			if _, cached, _ := cache.GetUsernameByEmail(c.AuthorEmail); !cached && c.AuthorEmail != "" && !budget.Take() {
+				return contributions, handlers.BudgetError
+			}
+
+			username = g.usernameByEmail(c.AuthorEmail)
+			usernames[c.AuthorEmail] = username
+		}
+
+		if username != "" && c.AuthoredDate != nil {
+			contributions = append(contributions, handlers.Contribution{
+				Username: username,
+				Kind:     handlers.ContributionAuthored,
+				Path:     path,
+				At:       *c.AuthoredDate,
+			})
+		}
+
+		if !budget.Take() {
+			return contributions, handlers.BudgetError
+		}
+
+		mrs, _, err := g.client.Commits.ListMergeRequestsByCommit(projectID, c.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mr := range mrs {
+			if _, ok := seen[mr.IID]; ok || mr.State != "merged" || mr.MergedAt == nil {
+				continue
+			}
+			seen[mr.IID] = struct{}{}
+
+			if username == "" {
+				contributions = append(contributions, handlers.Contribution{
+					Username: mr.Author.Username,
+					Kind:     handlers.ContributionAuthored,
+					Path:     path,
+					At:       *mr.MergedAt,
+				})
+			}
+
+			if !budget.Take() {
+				return contributions, handlers.BudgetError
+			}
+
+			approvals, _, err := g.client.MergeRequests.GetMergeRequestApprovals(projectID, mr.IID)
+			if err != nil {
+				logger.Debug("GetMergeRequestApprovals returns error, approvers are skipped", "mergeId", mr.IID, "err", err)
+				continue
+			}
+
+			for _, a := range approvals.ApprovedBy {
+				if a.User.ID == mr.Author.ID || a.User.Username == username {
+					continue
+				}
+
+				contributions = append(contributions, handlers.Contribution{
+					Username: a.User.Username,
+					Kind:     handlers.ContributionApproved,
+					Path:     path,
+					At:       *mr.MergedAt,
+				})
+			}
+		}
+	}
+
+	return contributions, nil
+}
+
+// CountOpenReviews returns the number of open non-draft MRs across the instance where the user is a reviewer,
+// numbers are cached for a few minutes, so every spin doesn't request all candidates again
+func (g GitlabProvider) CountOpenReviews(usernames []string) (map[string]int, error) {
+	loads := make(map[string]int, len(usernames))
+
+	for _, u := range usernames {
+		count, ok, err := cache.GetOpenReviews(u)
+		if err != nil {
+			logger.Debug("open reviews cache is unavailable", "username", u, "err", err)
+		}
+
+		if ok {
+			loads[u] = count
+			continue
+		}
+
+		_, resp, err := g.client.MergeRequests.ListMergeRequests(&gitlab.ListMergeRequestsOptions{
+			ListOptions:      gitlab.ListOptions{PerPage: 1},
+			State:            new("opened"),
+			Scope:            new("all"),
+			ReviewerUsername: new(u),
+			Draft:            new(false),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		loads[u] = int(resp.TotalItems)
+
+		if err := cache.SetOpenReviews(u, loads[u]); err != nil {
+			logger.Info("open reviews can't be cached", "username", u, "err", err)
+		}
+	}
+
+	return loads, nil
+}
+
+// ListOpenReviews lists open non-draft MRs of the project which have reviewers
+func (g GitlabProvider) ListOpenReviews(projectID int64) iter.Seq[handlers.Review] {
+	const batch int64 = 50
+
+	listMr := g.listMergeRequests(projectID, batch,
+		&gitlab.ListProjectMergeRequestsOptions{
+			State:      new("opened"),
+			Draft:      new(false),
+			ReviewerID: gitlab.ReviewerID(gitlab.UserIDAny),
+		})
+
+	return func(yield func(handlers.Review) bool) {
+		for mr := range listMr {
+			if len(mr.Reviewers) == 0 {
+				continue
+			}
+
+			review := handlers.Review{ID: mr.IID, Reviewers: make([]string, 0, len(mr.Reviewers)), Timezones: map[string]string{}}
+			for _, u := range mr.Reviewers {
+				review.Reviewers = append(review.Reviewers, u.Username)
+
+				if tz := g.userProfile(u.ID).Timezone; tz != "" {
+					review.Timezones[u.Username] = tz
+				}
+			}
+
+			if mr.Author != nil {
+				review.Author = mr.Author.Username
+			}
+
+			if mr.CreatedAt != nil {
+				review.CreatedAt = *mr.CreatedAt
+			}
+
+			if !yield(review) {
+				return
+			}
+		}
+	}
+}
+
+// GetReviewActivity returns approvers of MR and the time of the last comment of every user, comments of the bot are skipped
+func (g GitlabProvider) GetReviewActivity(projectID, mergeID int64) (*handlers.ReviewActivity, error) {
+	const batch int64 = 50
+
+	approvals, _, err := g.client.MergeRequests.GetMergeRequestApprovals(projectID, mergeID)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &handlers.ReviewActivity{
+		Approved:  map[string]struct{}{},
+		Commented: map[string]time.Time{},
+	}
+
+	for _, a := range approvals.ApprovedBy {
+		activity.Approved[a.User.Username] = struct{}{}
+	}
+
+	// reviewers who commented must not be reminded because notes can't be listed
+	notes, err := g.listMergeRequestNotes(projectID, mergeID, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, note := range notes {
+		// bot accounts like project_1_bot_abc aren't matched by bot_usernames, so the bot is skipped by its ID
+		if note.System || note.CreatedAt == nil || note.Author.ID == g.currentUserID {
+			continue
+		}
+
+		if last, ok := activity.Commented[note.Author.Username]; !ok || note.CreatedAt.After(last) {
+			activity.Commented[note.Author.Username] = *note.CreatedAt
+		}
+	}
+
+	return activity, nil
+}
+
+func (g GitlabProvider) GetContributors(projectID, mergeID int64, settings handlers.Candidates) ([]handlers.Candidate, error) {
 	const (
 		batch int64 = 50
 	)
 
-	candidates := []handlers.Candidate{}
-
-	userIDs, err := cache.GetContributors(projectID)
+	userIDs, err := cache.GetContributors(projectID, settings.LookbackDays)
 	if err != nil {
 		return nil, err
 	}
@@ -609,11 +1401,10 @@ func (g GitlabProvider) GetContributors(projectID, mergeID int64) ([]handlers.Ca
 	// counts := map[string]int{}
 
 	if len(userIDs) == 0 {
-		now := time.Now()
-		months3back := now.Add(-1 * time.Hour * 24 * 30 * 3)
+		since := time.Now().AddDate(0, 0, -settings.LookbackDays)
 
 		for mr := range g.listMergeRequests(projectID, batch, &gitlab.ListProjectMergeRequestsOptions{
-			UpdatedAfter: &months3back,
+			UpdatedAfter: &since,
 		}) {
 			userIDs = append(userIDs, mr.Author.ID)
 			// for _, r := range mr.Reviewers {
@@ -632,25 +1423,92 @@ func (g GitlabProvider) GetContributors(projectID, mergeID int64) ([]handlers.Ca
 			uniqueIDs = append(uniqueIDs, k)
 		}
 
-		if err := cache.SetContributors(projectID, uniqueIDs); err != nil {
+		if err := cache.SetContributors(projectID, settings.LookbackDays, uniqueIDs); err != nil {
 			return nil, err
 		}
 
 		userIDs = uniqueIDs
 	}
 
+	return g.memberCandidates(projectID, mergeID, settings, userIDs)
+}
+
+// userID returns the ID of the username, it is 0 if there is no such user
+func (g GitlabProvider) userID(username string) (int64, error) {
+	id, ok, err := cache.GetUserID(username)
+	if err != nil {
+		logger.Info("users cache is unavailable", "username", username, "err", err)
+	}
+
+	if ok {
+		return id, nil
+	}
+
+	users, _, err := g.client.Users.ListUsers(&gitlab.ListUsersOptions{Username: &username})
+	if err != nil {
+		return 0, err
+	}
+
+	id = 0
+	if len(users) == 1 {
+		id = users[0].ID
+	}
+
+	if err := cache.SetUserID(username, id); err != nil {
+		logger.Info("user id can't be cached", "username", username, "err", err)
+	}
+
+	return id, nil
+}
+
+// GetMembers returns candidates among members of the project with the usernames, e.g. members of teams
+func (g GitlabProvider) GetMembers(projectID, mergeID int64, usernames []string, settings handlers.Candidates) ([]handlers.Candidate, error) {
+	userIDs := []int64{}
+	for _, u := range usernames {
+		id, err := g.userID(u)
+		if err != nil {
+			return nil, err
+		}
+
+		if id != 0 {
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	if len(userIDs) == 0 {
+		return []handlers.Candidate{}, nil
+	}
+
+	return g.memberCandidates(projectID, mergeID, settings, userIDs)
+}
+
+// memberCandidates returns active members of the project with the IDs who have the access level required by settings or own the code
+func (g GitlabProvider) memberCandidates(projectID, mergeID int64, settings handlers.Candidates, userIDs []int64) ([]handlers.Candidate, error) {
+	const (
+		batch int64 = 50
+	)
+
+	candidates := []handlers.Candidate{}
+
 	codeowners, err := g.codeOwners(projectID, mergeID)
 	if err != nil {
 		return nil, err
 	}
 
-	for m := range g.listAllProjectMembers(projectID, batch, &gitlab.ListProjectMembersOptions{
-		UserIDs: &userIDs,
-	}) {
+	minLevel, ok := accessLevels[settings.MinAccessLevel]
+	if !ok {
+		minLevel = gitlab.MaintainerPermissions
+	}
+
+	members, err := g.listAllProjectMembers(projectID, batch, &gitlab.ListProjectMembersOptions{UserIDs: &userIDs})
+	if err != nil {
+		return nil, err
+	}
 
+	for _, m := range members {
 		_, isCodeOwner := codeowners[m.Username]
 
-		if !isCodeOwner && m.AccessLevel < gitlab.MaintainerPermissions {
+		if !isCodeOwner && m.AccessLevel < minLevel {
 			continue
 		}
 
@@ -664,17 +1522,15 @@ func (g GitlabProvider) GetContributors(projectID, mergeID int64) ([]handlers.Ca
 			continue
 		}
 
-		// user, _, err := g.client.Users.GetUser(m.ID, &gitlab.GetUserOptions{})
-		// if err != nil {
-		// 	continue
-		// }
+		profile := g.userProfile(m.ID)
 
 		candidates = append(candidates, handlers.Candidate{
 			Username:    m.Username,
 			StatusEmoji: status.Emoji,
 			Status:      status.Message,
 			Count:       0, //counts[m.Username],
-			// Timezone:    user.Location,
+			Timezone:    profile.Timezone,
+			Bot:         profile.Bot,
 			IsCodeOwner: isCodeOwner})
 	}
 
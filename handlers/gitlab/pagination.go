@@ -35,6 +35,30 @@ func paginate[T any](
 	}
 }
 
+// collect pages through all items, unlike paginate it returns the error of any page,
+// it is used where a partial list would look like a valid one
+func collect[T any](
+	fetchPage func(page, perPage int64) ([]T, *gitlab.Response, error),
+	size int64,
+) ([]T, error) {
+	all := []T{}
+	var page int64 = 1
+
+	for {
+		items, resp, err := fetchPage(page, size)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, items...)
+
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		page = resp.NextPage
+	}
+}
+
 func (g GitlabProvider) listBranches(projectID, size int64) iter.Seq[*gitlab.Branch] {
 	return paginate(func(page, perPage int64) ([]*gitlab.Branch, *gitlab.Response, error) {
 		return g.client.Branches.ListBranches(projectID, &gitlab.ListBranchesOptions{
@@ -43,6 +67,15 @@ func (g GitlabProvider) listBranches(projectID, size int64) iter.Seq[*gitlab.Bra
 	}, size)
 }
 
+func (g GitlabProvider) listTags(projectID, size int64, search string) ([]*gitlab.Tag, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.Tag, *gitlab.Response, error) {
+		return g.client.Tags.ListTags(projectID, &gitlab.ListTagsOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+			Search:      &search,
+		})
+	}, size)
+}
+
 func (g GitlabProvider) listMergeRequests(projectID, size int64, options *gitlab.ListProjectMergeRequestsOptions) iter.Seq[*gitlab.BasicMergeRequest] {
 	return paginate(func(page, perPage int64) ([]*gitlab.BasicMergeRequest, *gitlab.Response, error) {
 		if options == nil {
@@ -53,8 +86,8 @@ func (g GitlabProvider) listMergeRequests(projectID, size int64, options *gitlab
 	}, size)
 }
 
-func (g GitlabProvider) listAllProjectMembers(projectID, size int64, options *gitlab.ListProjectMembersOptions) iter.Seq[*gitlab.ProjectMember] {
-	return paginate(func(page, perPage int64) ([]*gitlab.ProjectMember, *gitlab.Response, error) {
+func (g GitlabProvider) listAllProjectMembers(projectID, size int64, options *gitlab.ListProjectMembersOptions) ([]*gitlab.ProjectMember, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.ProjectMember, *gitlab.Response, error) {
 		if options == nil {
 			options = &gitlab.ListProjectMembersOptions{}
 		}
@@ -62,3 +95,37 @@ func (g GitlabProvider) listAllProjectMembers(projectID, size int64, options *gi
 		return g.client.ProjectMembers.ListAllProjectMembers(projectID, options)
 	}, size)
 }
+
+func (g GitlabProvider) listMergeRequestNotes(projectID, mergeID, size int64) ([]*gitlab.Note, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.Note, *gitlab.Response, error) {
+		return g.client.Notes.ListMergeRequestNotes(projectID, mergeID, &gitlab.ListMergeRequestNotesOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+		})
+	}, size)
+}
+
+func (g GitlabProvider) listPipelineJobs(projectID, pipelineID, size int64, options *gitlab.ListJobsOptions) ([]*gitlab.Job, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.Job, *gitlab.Response, error) {
+		if options == nil {
+			options = &gitlab.ListJobsOptions{}
+		}
+		options.ListOptions = gitlab.ListOptions{Page: page, PerPage: perPage}
+		return g.client.Jobs.ListPipelineJobs(projectID, pipelineID, options)
+	}, size)
+}
+
+func (g GitlabProvider) listAllGroupMembers(group string, size int64) ([]*gitlab.GroupMember, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.GroupMember, *gitlab.Response, error) {
+		return g.client.Groups.ListAllGroupMembers(group, &gitlab.ListGroupMembersOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+		})
+	}, size)
+}
+
+func (g GitlabProvider) listMergeRequestDiffs(projectID, mergeID, size int64) ([]*gitlab.MergeRequestDiff, error) {
+	return collect(func(page, perPage int64) ([]*gitlab.MergeRequestDiff, *gitlab.Response, error) {
+		return g.client.MergeRequests.ListMergeRequestDiffs(projectID, mergeID, &gitlab.ListMergeRequestDiffsOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+		})
+	}, size)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gobwas/glob"
+)
+
+// regexPrefix marks regexes in lists of glob patterns
+const regexPrefix = "re:"
+
+// matchPattern reports whether s matches the glob pattern, "*" doesn't cross "/" while "**" does
+func matchPattern(pattern, s string) bool {
+	g, err := glob.Compile(pattern, '/')
+	if err != nil {
+		logger.Info("pattern is invalid", "pattern", pattern, "err", err)
+		return false
+	}
+
+	return g.Match(s)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchBranch reports whether the branch matches the glob pattern or the regex prefixed with re:
+func matchBranch(pattern, branch string) bool {
+	expr, ok := strings.CutPrefix(pattern, regexPrefix)
+	if !ok {
+		return matchPattern(pattern, branch)
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		logger.Info("pattern is invalid", "pattern", pattern, "err", err)
+		return false
+	}
+
+	return re.MatchString(branch)
+}
@@ -1,99 +1,216 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
-	"os"
+	"slices"
+	"strings"
 
+	"github.com/gasoid/merge-bot/v3/config"
 	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/workspace"
 
-	"github.com/ldez/go-git-cmd-wrapper/v2/checkout"
-	"github.com/ldez/go-git-cmd-wrapper/v2/clone"
-	"github.com/ldez/go-git-cmd-wrapper/v2/config"
 	"github.com/ldez/go-git-cmd-wrapper/v2/git"
 	"github.com/ldez/go-git-cmd-wrapper/v2/global"
+	"github.com/ldez/go-git-cmd-wrapper/v2/lsfiles"
 	"github.com/ldez/go-git-cmd-wrapper/v2/merge"
 	"github.com/ldez/go-git-cmd-wrapper/v2/push"
 )
 
 const (
-	defaultRemote = "origin"
+	// GitBackendCLI runs the git binary
+	GitBackendCLI = "cli"
+	// GitBackendGoGit merges in-process, the git binary isn't needed
+	GitBackendGoGit = "go-git"
+
+	mergeErrorText = `
+🛠️ I failed to merge %s into your branch, you have to resolve conflicts manually
+%s
+<details>
+<summary>
+How to merge branch manually:
+</summary>
+<pre><code>git checkout %s
+git pull origin
+git checkout %s
+git merge %s
+# resolve conflicts
+git push origin</code></pre>
+
+</details>
+`
 )
 
+var (
+	gitBackend string
+	// updater is built by InitUpdater at startup
+	updater Updater
+)
+
+func init() {
+	config.StringVar(&gitBackend, "git-backend", GitBackendCLI, "implementation of branch updates: cli runs the git binary, go-git merges in-process (also via GIT_BACKEND)")
+}
+
+// Updater merges master into the branch of the repository and pushes the branch,
+// it returns MergeError if the branches can't be merged automatically
+type Updater interface {
+	Update(repoUrl, username, branchName, master string) error
+}
+
 type MergeError struct {
 	SourceBranch      string
 	DestinationBranch string
+	// Paths are conflicting files, empty if they are unknown
+	Paths []string
 }
 
 func (e *MergeError) Error() string {
 	return "you have to merge your destination branch manually"
 }
 
-//nolint:errcheck
-func MergeMaster(username, password, repoUrl, branchName, master string) error {
+// Comment explains how to resolve conflicts manually
+func (e *MergeError) Comment() string {
+	builder := &strings.Builder{}
+	if len(e.Paths) > 0 {
+		builder.WriteString("\nConflicting files:\n")
 
-	if username != "" && password != "" {
-		parsedUrl, err := url.Parse(repoUrl)
-		if err != nil {
-			return err
+		for _, p := range e.Paths {
+			fmt.Fprintf(builder, "- `%s`\n", p)
 		}
-		parsedUrl.User = url.UserPassword(username, password)
-		repoUrl = parsedUrl.String()
 	}
 
-	dir, err := os.MkdirTemp("", "merge-bot")
+	return fmt.Sprintf(mergeErrorText, e.DestinationBranch, builder.String(), e.DestinationBranch, e.SourceBranch, e.DestinationBranch)
+}
+
+// newUpdater returns the updater selected by -git-backend
+func newUpdater(backend string) (Updater, error) {
+	mirrors, err := workspace.Default()
 	if err != nil {
-		logger.Debug("temp dir error", "error", err)
-		return fmt.Errorf("temp dir error: %w", err)
+		return nil, err
 	}
 
-	workingDir := global.UpperC(dir)
+	switch backend {
+	case GitBackendCLI:
+		return cliUpdater{mirrors: mirrors}, nil
+	case GitBackendGoGit:
+		return goGitUpdater{mirrors: mirrors}, nil
+	}
 
-	defer os.RemoveAll(dir)
+	return nil, fmt.Errorf("git-backend %q is unknown, use %s or %s", backend, GitBackendCLI, GitBackendGoGit)
+}
 
-	if output, err := git.Clone(clone.Repository(repoUrl), clone.Directory(dir)); err != nil {
-		logger.Debug("git clone error", "dir", dir, "output", output)
-		return fmt.Errorf("git clone error: %w, output: %s", err, output)
+func withCredentials(repoUrl, username, password string) (string, error) {
+	if username == "" || password == "" {
+		return repoUrl, nil
 	}
 
-	if output, err := git.Config(workingDir, config.Entry("user.email", fmt.Sprintf("%s@localhost", username))); err != nil {
-		logger.Debug("git config error", "user.email", fmt.Sprintf("%s@localhost", username), "output", output)
-		return fmt.Errorf("git config error: %w, output: %s", err, output)
+	parsedUrl, err := url.Parse(repoUrl)
+	if err != nil {
+		return "", err
 	}
+	parsedUrl.User = url.UserPassword(username, password)
+
+	return parsedUrl.String(), nil
+}
 
-	if output, err := git.Config(workingDir, config.Entry("user.name", username)); err != nil {
-		logger.Debug("git config error", "user.name", username, "output", output)
-		return fmt.Errorf("git config error: %w, output: %s", err, output)
+// InitUpdater builds the updater selected by -git-backend, branches can't be updated without it
+func InitUpdater() error {
+	u, err := newUpdater(gitBackend)
+	if err != nil {
+		return err
 	}
 
-	if output, err := git.Checkout(workingDir, checkout.Branch(master)); err != nil {
-		logger.Debug("git checkout error", "branch", master, "output", output)
-		return fmt.Errorf("git checkout error: %w, output: %s", err, output)
+	updater = u
+	return nil
+}
+
+// MergeMaster merges master into the branch by the updater selected with -git-backend
+func MergeMaster(username, password, repoUrl, branchName, master string) error {
+	if updater == nil {
+		return errors.New("updater isn't initialized")
 	}
 
-	if output, err := git.Checkout(workingDir, checkout.Branch(branchName)); err != nil {
-		logger.Debug("git checkout error", "branch", branchName, "output", output)
-		return fmt.Errorf("git checkout error: %w, output: %s", err, output)
+	repoUrl, err := withCredentials(repoUrl, username, password)
+	if err != nil {
+		return err
 	}
 
-	if output, err := git.Merge(workingDir, merge.Commits(master), merge.M(fmt.Sprintf("✨ merged %s", master))); err != nil {
-		logger.Debug("git merge error", "output", output)
-		if output, err := git.Merge(workingDir, merge.NoFf, merge.Commits(master), merge.M(fmt.Sprintf("✨ merged %s", master))); err != nil {
-			logger.Debug("git merge --no-ff error", "output", output)
+	return updater.Update(repoUrl, username, branchName, master)
+}
 
-			mergeError := &MergeError{
-				DestinationBranch: master,
-				SourceBranch:      branchName,
+// ConflictingFiles merges master into the branch in the mirror of the repository without pushing,
+// it returns files which can't be merged automatically
+func ConflictingFiles(username, password, repoUrl, branchName, master string) ([]string, error) {
+	mirrors, err := workspace.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	if repoUrl, err = withCredentials(repoUrl, username, password); err != nil {
+		return nil, err
+	}
+
+	return trialMerge(mirrors, repoUrl, branchName, master)
+}
+
+// cliUpdater merges in a worktree of the mirror of the repository by the git binary
+type cliUpdater struct {
+	mirrors *workspace.Manager
+}
+
+func (u cliUpdater) Update(repoUrl, username, branchName, master string) error {
+	return u.mirrors.Run(repoUrl, branchName, func(dir string) error {
+		workingDir := global.UpperC(dir)
+		email := global.LowerC("user.email", fmt.Sprintf("%s@localhost", username))
+		name := global.LowerC("user.name", username)
+
+		if output, err := git.Merge(workingDir, email, name, merge.Commits(master), merge.M(fmt.Sprintf("✨ merged %s", master))); err != nil {
+			logger.Debug("git merge error", "output", output)
+
+			// a failed merge must be aborted before the next attempt
+			git.Merge(workingDir, merge.Abort) //nolint:errcheck
+
+			if output, err := git.Merge(workingDir, email, name, merge.NoFf, merge.Commits(master), merge.M(fmt.Sprintf("✨ merged %s", master))); err != nil {
+				logger.Debug("git merge --no-ff error", "output", output)
+
+				mergeError := &MergeError{
+					DestinationBranch: master,
+					SourceBranch:      branchName,
+					Paths:             unmergedPaths(dir),
+				}
+
+				return fmt.Errorf("git merge --no-ff error: %w, output: %s", mergeError, output)
 			}
+		}
 
-			return fmt.Errorf("git merge --no-ff error: %w, output: %s", mergeError, output)
+		// the worktree is detached, HEAD is the merged branch
+		if output, err := git.Push(workingDir, push.Remote(repoUrl), push.RefSpec("HEAD:refs/heads/"+branchName)); err != nil {
+			logger.Debug("git push error", "output", output)
+			return fmt.Errorf("git push error: %w, output: %s", err, output)
 		}
+
+		return nil
+	})
+}
+
+// unmergedPaths returns conflicting files of the failed merge
+func unmergedPaths(dir string) []string {
+	output, err := git.LsFiles(global.UpperC(dir), lsfiles.Unmerged, lsfiles.Z)
+	if err != nil {
+		logger.Debug("git ls-files error", "output", output)
+		return nil
 	}
 
-	if output, err := git.Push(workingDir, push.Remote(defaultRemote), push.RefSpec(branchName)); err != nil {
-		logger.Debug("git push error", "output", output)
-		return fmt.Errorf("git push error: %w, output: %s", err, output)
+	paths := []string{}
+
+	// every entry looks like "<mode> <object> <stage>\t<path>", there is an entry per stage
+	for entry := range strings.SplitSeq(output, "\x00") {
+		if _, path, ok := strings.Cut(entry, "\t"); ok {
+			paths = append(paths, path)
+		}
 	}
 
-	return nil
+	slices.Sort(paths)
+	return slices.Compact(paths)
 }
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/workspace"
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// local repositories are served in-process, the git binary isn't needed by go-git
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+var testSignature = object.Signature{Name: "test", Email: "test@localhost", When: time.Unix(1700000000, 0)}
+
+// commitFiles writes files to the worktree and commits them, empty content deletes the file
+func commitFiles(t *testing.T, wt *gogit.Worktree, dir string, files map[string]string) {
+	t.Helper()
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		var err error
+
+		if files[name] == "" {
+			_, err = wt.Remove(name)
+		} else {
+			path := filepath.Join(dir, name)
+			if err = os.MkdirAll(filepath.Dir(path), 0o750); err == nil {
+				err = os.WriteFile(path, []byte(files[name]), 0o600)
+			}
+
+			if err == nil {
+				_, err = wt.Add(name)
+			}
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := wt.Commit("update", &gogit.CommitOptions{Author: &testSignature}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestRepo returns a bare repository with master and feature branches which start from the base files
+func newTestRepo(t *testing.T, base, master, feature map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin.git")
+	dir := filepath.Join(root, "work")
+
+	if _, err := gogit.PlainInit(origin, true); err != nil {
+		t.Fatal(err)
+	}
+
+	work, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wt, err := work.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitFiles(t, wt, dir, base)
+
+	head, err := work.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := work.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature"), head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	if master != nil {
+		commitFiles(t, wt, dir, master)
+	}
+
+	if feature != nil {
+		if err := wt.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature")}); err != nil {
+			t.Fatal(err)
+		}
+
+		commitFiles(t, wt, dir, feature)
+	}
+
+	remote := gogit.NewRemote(work.Storer, &gitconfig.RemoteConfig{Name: "origin", URLs: []string{origin}})
+	if err := remote.Push(&gogit.PushOptions{RefSpecs: []gitconfig.RefSpec{"refs/heads/*:refs/heads/*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return origin
+}
+
+func branchHead(t *testing.T, origin, branch string) *object.Commit {
+	t.Helper()
+
+	repo, err := gogit.PlainOpen(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := branchCommit(repo, branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+func commitContents(t *testing.T, commit *object.Commit) map[string]string {
+	t.Helper()
+
+	files, err := commit.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string]string{}
+	err = files.ForEach(func(f *object.File) error {
+		var err error
+		contents[f.Name], err = f.Contents()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contents
+}
+
+func TestUpdaters(t *testing.T) {
+	type expected struct {
+		files map[string]string
+		// head is the branch which the feature branch is expected to point to, empty for merge commit
+		head      string
+		conflicts []string
+	}
+
+	tests := []struct {
+		name     string
+		base     map[string]string
+		master   map[string]string
+		feature  map[string]string
+		expected expected
+	}{
+		{
+			name:    "different files",
+			base:    map[string]string{"a.txt": "a\n", "b.txt": "b\n", "old.txt": "old\n"},
+			master:  map[string]string{"a.txt": "a2\n", "dir/c.txt": "c\n", "old.txt": ""},
+			feature: map[string]string{"b.txt": "b2\n", "dir/d.txt": "d\n"},
+			expected: expected{
+				files: map[string]string{"a.txt": "a2\n", "b.txt": "b2\n", "dir/c.txt": "c\n", "dir/d.txt": "d\n"},
+			},
+		},
+		{
+			name:    "different lines of the same file",
+			base:    map[string]string{"a.txt": "1\n2\n3\n4\n5\n"},
+			master:  map[string]string{"a.txt": "one\n2\n3\n4\n5\n"},
+			feature: map[string]string{"a.txt": "1\n2\n3\n4\nfive\n"},
+			expected: expected{
+				files: map[string]string{"a.txt": "one\n2\n3\n4\nfive\n"},
+			},
+		},
+		{
+			name:    "renamed on one side and changed on another one",
+			base:    map[string]string{"a.txt": "1\n2\n3\n", "docs/b.txt": "b\n", "lib/c.txt": "c\n"},
+			master:  map[string]string{"a.txt": "1\ntwo\n3\n", "lib/c.txt": "", "lib/d.txt": "c\n"},
+			feature: map[string]string{"a.txt": "", "dir/a.txt": "1\n2\n3\n", "lib/c.txt": "c\nc2\n"},
+			expected: expected{
+				files: map[string]string{"dir/a.txt": "1\ntwo\n3\n", "docs/b.txt": "b\n", "lib/d.txt": "c\nc2\n"},
+			},
+		},
+		{
+			name:    "renamed by both sides differently",
+			base:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			master:  map[string]string{"a.txt": "", "c.txt": "a\n"},
+			feature: map[string]string{"a.txt": "", "d.txt": "a\n"},
+			expected: expected{
+				files:     map[string]string{"d.txt": "a\n", "b.txt": "b\n"},
+				head:      "feature",
+				conflicts: []string{"a.txt", "c.txt", "d.txt"},
+			},
+		},
+		{
+			name:   "fast-forward",
+			base:   map[string]string{"a.txt": "a\n"},
+			master: map[string]string{"a.txt": "a2\n"},
+			expected: expected{
+				files: map[string]string{"a.txt": "a2\n"},
+				head:  "master",
+			},
+		},
+		{
+			name:    "up to date",
+			base:    map[string]string{"a.txt": "a\n"},
+			feature: map[string]string{"a.txt": "a2\n"},
+			expected: expected{
+				files: map[string]string{"a.txt": "a2\n"},
+				head:  "feature",
+			},
+		},
+		{
+			name:    "conflicts",
+			base:    map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "b\n", "c.txt": "c\n"},
+			master:  map[string]string{"a.txt": "1\nX\n3\n", "b.txt": "b1\n", "c.txt": "c1\n"},
+			feature: map[string]string{"a.txt": "1\nY\n3\n", "b.txt": "", "c.txt": "c1\n"},
+			expected: expected{
+				files:     map[string]string{"a.txt": "1\nY\n3\n", "c.txt": "c1\n"},
+				head:      "feature",
+				conflicts: []string{"a.txt", "b.txt"},
+			},
+		},
+	}
+
+	backends := map[string]func(*workspace.Manager) Updater{
+		GitBackendCLI:   func(m *workspace.Manager) Updater { return cliUpdater{mirrors: m} },
+		GitBackendGoGit: func(m *workspace.Manager) Updater { return goGitUpdater{mirrors: m} },
+	}
+
+	for backend, newBackend := range backends {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				origin := newTestRepo(t, tt.base, tt.master, tt.feature)
+				expectedHead := tt.expected.head
+				if expectedHead == "" {
+					expectedHead = "feature"
+				}
+				before := branchHead(t, origin, expectedHead)
+
+				updater := newBackend(workspace.New(t.TempDir(), 1<<30))
+				err := updater.Update(origin, "bot", "feature", "master")
+
+				mergeError := &MergeError{}
+				if tt.expected.conflicts != nil {
+					if assert.True(t, errors.As(err, &mergeError), "err: %v", err) {
+						assert.Equal(t, tt.expected.conflicts, mergeError.Paths)
+						assert.Equal(t, "feature", mergeError.SourceBranch)
+						assert.Equal(t, "master", mergeError.DestinationBranch)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+
+				head := branchHead(t, origin, "feature")
+				assert.Equal(t, tt.expected.files, commitContents(t, head))
+
+				if tt.expected.head != "" {
+					assert.Equal(t, before.Hash, head.Hash)
+				} else {
+					assert.Equal(t, []plumbing.Hash{before.Hash, branchHead(t, origin, "master").Hash}, head.ParentHashes)
+					assert.Equal(t, "✨ merged master\n", head.Message)
+				}
+			})
+		}
+	}
+}
+
+func Test_trialMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		master  map[string]string
+		feature map[string]string
+		want    []string
+	}{
+		{
+			name:    "conflicts",
+			master:  map[string]string{"a.txt": "1\nX\n3\n", "b.txt": "b1\n"},
+			feature: map[string]string{"a.txt": "1\nY\n3\n", "b.txt": ""},
+			want:    []string{"a.txt", "b.txt"},
+		},
+		{
+			name:    "no conflicts",
+			master:  map[string]string{"a.txt": "one\n2\n3\n"},
+			feature: map[string]string{"b.txt": "b2\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := newTestRepo(t, map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "b\n"}, tt.master, tt.feature)
+			before := branchHead(t, origin, "feature")
+
+			got, err := trialMerge(workspace.New(t.TempDir(), 1<<30), origin, "feature", "master")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// nothing is pushed
+			assert.Equal(t, before.Hash, branchHead(t, origin, "feature").Hash)
+		})
+	}
+}
+
+func TestMergeError_Comment(t *testing.T) {
+	withPaths := (&MergeError{SourceBranch: "feature", DestinationBranch: "main", Paths: []string{"a.txt", "dir/b.txt"}}).Comment()
+	assert.Contains(t, withPaths, "Conflicting files:\n- `a.txt`\n- `dir/b.txt`\n")
+	assert.Contains(t, withPaths, "git checkout feature")
+
+	withoutPaths := (&MergeError{SourceBranch: "feature", DestinationBranch: "main"}).Comment()
+	assert.NotContains(t, withoutPaths, "Conflicting files")
+	assert.Contains(t, withoutPaths, "I failed to merge main into your branch")
+}
+
+func TestNewUpdater(t *testing.T) {
+	for _, backend := range []string{GitBackendCLI, GitBackendGoGit} {
+		updater, err := newUpdater(backend)
+		assert.NoError(t, err)
+		assert.NotNil(t, updater)
+	}
+
+	_, err := newUpdater("libgit2")
+	assert.ErrorContains(t, err, `git-backend "libgit2" is unknown`)
+}
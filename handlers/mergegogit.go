@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+	"github.com/gasoid/merge-bot/v3/workspace"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// goGitUpdater merges objects of the mirror of the repository in-process,
+// the merge commit is pushed by its hash, so neither worktree nor branches of the mirror are changed
+type goGitUpdater struct {
+	mirrors *workspace.Manager
+}
+
+func (u goGitUpdater) Update(repoUrl, username, branchName, master string) error {
+	return u.mirrors.Use(repoUrl, func(mirror string) error {
+		repo, remote, err := fetchMirror(mirror, repoUrl)
+		if err != nil {
+			return err
+		}
+
+		ours, err := branchCommit(repo, branchName)
+		if err != nil {
+			return err
+		}
+
+		theirs, err := branchCommit(repo, master)
+		if err != nil {
+			return err
+		}
+
+		signature := object.Signature{Name: username, Email: fmt.Sprintf("%s@localhost", username), When: time.Now()}
+
+		merged, conflicts, err := mergeCommits(repo.Storer, ours, theirs, signature, fmt.Sprintf("✨ merged %s\n", master))
+		if len(conflicts) > 0 || errors.Is(err, errUnrelatedHistories) || errors.Is(err, errSeveralBases) {
+			logger.Debug("go-git merge error", "conflicts", conflicts, "err", err)
+
+			mergeError := &MergeError{
+				DestinationBranch: master,
+				SourceBranch:      branchName,
+				Paths:             conflicts,
+			}
+
+			return fmt.Errorf("go-git merge error: %w", mergeError)
+		}
+
+		if err != nil {
+			return fmt.Errorf("go-git merge error: %w", err)
+		}
+
+		if merged == ours.Hash {
+			return nil
+		}
+
+		refSpec := gitconfig.RefSpec(fmt.Sprintf("%s:%s", merged, plumbing.NewBranchReferenceName(branchName)))
+		if err := remote.Push(&gogit.PushOptions{RefSpecs: []gitconfig.RefSpec{refSpec}}); err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("go-git push error: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// trialMerge merges master into the branch in-process and returns conflicting files, nothing is pushed,
+// objects of the merge stay unreferenced in the mirror
+func trialMerge(mirrors *workspace.Manager, repoUrl, branchName, master string) ([]string, error) {
+	var conflicts []string
+
+	err := mirrors.Use(repoUrl, func(mirror string) error {
+		repo, _, err := fetchMirror(mirror, repoUrl)
+		if err != nil {
+			return err
+		}
+
+		ours, err := branchCommit(repo, branchName)
+		if err != nil {
+			return err
+		}
+
+		theirs, err := branchCommit(repo, master)
+		if err != nil {
+			return err
+		}
+
+		signature := object.Signature{Name: "merge-bot", Email: "merge-bot@localhost", When: time.Now()}
+
+		_, conflicts, err = mergeCommits(repo.Storer, ours, theirs, signature, fmt.Sprintf("✨ merged %s\n", master))
+		if errors.Is(err, errUnrelatedHistories) || errors.Is(err, errSeveralBases) {
+			// conflicting files are unknown
+			return nil
+		}
+
+		return err
+	})
+
+	return conflicts, err
+}
+
+// fetchMirror clones the repository into a bare mirror on the first use and fetches it afterwards,
+// the remote isn't stored in the mirror because its URL may contain credentials
+func fetchMirror(mirror, repoUrl string) (*gogit.Repository, *gogit.Remote, error) {
+	operation := "fetch"
+	start := time.Now()
+
+	repo, err := gogit.PlainOpen(mirror)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		operation = "clone"
+		repo, err = gogit.PlainInit(mirror, true)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("go-git open error: %w", err)
+	}
+
+	remote := gogit.NewRemote(repo.Storer, &gitconfig.RemoteConfig{
+		Name: gogit.DefaultRemoteName,
+		URLs: []string{repoUrl},
+	})
+
+	err = remote.Fetch(&gogit.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{workspace.HeadsRefSpec},
+		Tags:     gogit.NoTags,
+		Force:    true,
+		Prune:    true,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		if operation == "clone" {
+			// the next operation starts from scratch
+			if err := os.RemoveAll(mirror); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				logger.Debug("go-git mirror can't be removed", "dir", mirror, "err", err)
+			}
+		}
+
+		return nil, nil, fmt.Errorf("go-git %s error: %w", operation, err)
+	}
+
+	metrics.GitDuration(operation, time.Since(start))
+	return repo, remote, nil
+}
+
+func branchCommit(repo *gogit.Repository, branch string) (*object.Commit, error) {
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("branch %s error: %w", branch, err)
+	}
+
+	return repo.CommitObject(ref.Hash())
+}
@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// lineDiffTimeout bounds diffs of huge files, a longer diff is coarser and may report a conflict
+const lineDiffTimeout = 10 * time.Second
+
+var (
+	errUnrelatedHistories = errors.New("branches have no common ancestor")
+	// errSeveralBases is returned for criss-cross histories, git merges their bases recursively and go-git can't
+	errSeveralBases = errors.New("branches have several merge bases")
+)
+
+// mergeCommits merges theirs into ours like git merge does: it fast-forwards if it's possible,
+// otherwise it merges trees and creates a merge commit. It returns the resulting commit,
+// which is ours if theirs is merged already, and conflicting paths if the trees can't be merged.
+// Unlike git, only renames of files which aren't changed by the renaming side are detected.
+func mergeCommits(s storer.EncodedObjectStorer, ours, theirs *object.Commit, signature object.Signature, message string) (plumbing.Hash, []string, error) {
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	if len(bases) == 0 {
+		return plumbing.ZeroHash, nil, errUnrelatedHistories
+	}
+
+	if len(bases) > 1 {
+		return plumbing.ZeroHash, nil, errSeveralBases
+	}
+
+	base := bases[0]
+
+	switch base.Hash {
+	case theirs.Hash:
+		return ours.Hash, nil, nil
+	case ours.Hash:
+		return theirs.Hash, nil, nil
+	}
+
+	trees := [3]*object.Tree{}
+	entries := [3]map[string]object.TreeEntry{}
+	for i, c := range []*object.Commit{base, ours, theirs} {
+		if trees[i], err = c.Tree(); err != nil {
+			return plumbing.ZeroHash, nil, err
+		}
+
+		entries[i] = map[string]object.TreeEntry{}
+	}
+
+	if err := treeEntries(s, "", trees, entries); err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	renameConflicts := applyRenames(entries[0], entries[1], entries[2])
+
+	merged, conflicts, err := mergeEntries(s, entries[0], entries[1], entries[2])
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	conflicts = append(conflicts, renameConflicts...)
+
+	root := &treeNode{}
+	for path, entry := range merged {
+		if !root.insert(strings.Split(path, "/"), entry) {
+			conflicts = append(conflicts, path)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		slices.Sort(conflicts)
+		return plumbing.ZeroHash, slices.Compact(conflicts), nil
+	}
+
+	tree, err := root.write(s)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{ours.Hash, theirs.Hash},
+	}
+
+	hash, err := storeObject(s, commit.Encode)
+	return hash, nil, err
+}
+
+// treeEntries adds files of base, ours and theirs trees to entries by their paths,
+// a directory which is the same in ours and theirs isn't walked, it's added as an entry itself
+func treeEntries(s storer.EncodedObjectStorer, prefix string, trees [3]*object.Tree, entries [3]map[string]object.TreeEntry) error {
+	byName := [3]map[string]object.TreeEntry{}
+	for i, tree := range trees {
+		byName[i] = map[string]object.TreeEntry{}
+
+		if tree != nil {
+			for _, entry := range tree.Entries {
+				byName[i][entry.Name] = entry
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for _, entries := range byName {
+		for name := range entries {
+			names[name] = true
+		}
+	}
+
+	for name := range names {
+		path := prefix + name
+
+		o, inOurs := byName[1][name]
+		t, inTheirs := byName[2][name]
+		if inOurs && inTheirs && o.Mode == filemode.Dir && o == t {
+			entries[1][path] = o
+			entries[2][path] = t
+			continue
+		}
+
+		subtrees := [3]*object.Tree{}
+		hasDir := false
+
+		for i := range trees {
+			entry, ok := byName[i][name]
+			if !ok {
+				continue
+			}
+
+			if entry.Mode != filemode.Dir {
+				entries[i][path] = entry
+				continue
+			}
+
+			subtree, err := object.GetTree(s, entry.Hash)
+			if err != nil {
+				return err
+			}
+
+			subtrees[i] = subtree
+			hasDir = true
+		}
+
+		if hasDir {
+			if err := treeEntries(s, path+"/", subtrees, entries); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// findRenames returns new paths of files which the side has moved without changing them by their old paths
+func findRenames(base, side map[string]object.TreeEntry) map[string]string {
+	added := map[plumbing.Hash][]string{}
+	for _, path := range slices.Sorted(maps.Keys(side)) {
+		if _, ok := base[path]; !ok && isRegular(side[path].Mode) {
+			added[side[path].Hash] = append(added[side[path].Hash], path)
+		}
+	}
+
+	renames := map[string]string{}
+	for _, path := range slices.Sorted(maps.Keys(base)) {
+		entry := base[path]
+		if _, ok := side[path]; ok || !isRegular(entry.Mode) || len(added[entry.Hash]) == 0 {
+			continue
+		}
+
+		renames[path] = added[entry.Hash][0]
+		added[entry.Hash] = added[entry.Hash][1:]
+	}
+
+	return renames
+}
+
+// applyRenames moves files renamed by one side in base and the other side, so changes of the other side
+// are merged into the renamed file. It returns old and new paths of files renamed by both sides differently.
+func applyRenames(base, ours, theirs map[string]object.TreeEntry) []string {
+	oursRenames, theirsRenames := findRenames(base, ours), findRenames(base, theirs)
+	conflicts := []string{}
+
+	move := func(renames, otherRenames map[string]string, other map[string]object.TreeEntry) {
+		for oldPath, newPath := range renames {
+			if otherPath, ok := otherRenames[oldPath]; ok {
+				if otherPath != newPath {
+					conflicts = append(conflicts, oldPath, newPath, otherPath)
+				}
+
+				continue
+			}
+
+			entry, ok := other[oldPath]
+			if _, exists := other[newPath]; !ok || exists {
+				// the other side has deleted the file or added its own one
+				continue
+			}
+
+			other[newPath] = entry
+			delete(other, oldPath)
+			base[newPath] = base[oldPath]
+			delete(base, oldPath)
+		}
+	}
+
+	move(oursRenames, theirsRenames, theirs)
+	move(theirsRenames, oursRenames, ours)
+
+	return conflicts
+}
+
+// mergeEntries takes every file from the side which has changed it,
+// files changed by both sides are merged line by line
+func mergeEntries(s storer.EncodedObjectStorer, base, ours, theirs map[string]object.TreeEntry) (map[string]object.TreeEntry, []string, error) {
+	paths := map[string]bool{}
+	for _, entries := range []map[string]object.TreeEntry{base, ours, theirs} {
+		for path := range entries {
+			paths[path] = true
+		}
+	}
+
+	merged := map[string]object.TreeEntry{}
+	conflicts := []string{}
+
+	for path := range paths {
+		b, inBase := base[path]
+		o, inOurs := ours[path]
+		t, inTheirs := theirs[path]
+
+		switch {
+		case inOurs == inTheirs && o == t:
+			// both sides have the same file or both have deleted it
+			if inOurs {
+				merged[path] = o
+			}
+		case inBase == inTheirs && b == t:
+			if inOurs {
+				merged[path] = o
+			}
+		case inBase == inOurs && b == o:
+			if inTheirs {
+				merged[path] = t
+			}
+		case inBase && inOurs && inTheirs:
+			entry, ok, err := mergeFile(s, b, o, t)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !ok {
+				conflicts = append(conflicts, path)
+				continue
+			}
+
+			merged[path] = entry
+		default:
+			// added by both sides or changed by one side and deleted by another one
+			conflicts = append(conflicts, path)
+		}
+	}
+
+	slices.Sort(conflicts)
+	return merged, conflicts, nil
+}
+
+func isRegular(m filemode.FileMode) bool {
+	return m == filemode.Regular || m == filemode.Executable || m == filemode.Deprecated
+}
+
+// mergeFile merges contents of the file changed by both sides, binary files, symlinks and submodules aren't merged
+func mergeFile(s storer.EncodedObjectStorer, base, ours, theirs object.TreeEntry) (object.TreeEntry, bool, error) {
+	if !isRegular(base.Mode) || !isRegular(ours.Mode) || !isRegular(theirs.Mode) {
+		return object.TreeEntry{}, false, nil
+	}
+
+	mode := ours.Mode
+	if ours.Mode == base.Mode {
+		mode = theirs.Mode
+	} else if theirs.Mode != base.Mode && theirs.Mode != ours.Mode {
+		return object.TreeEntry{}, false, nil
+	}
+
+	contents := [3][]byte{}
+	for i, entry := range []object.TreeEntry{base, ours, theirs} {
+		blob, err := object.GetBlob(s, entry.Hash)
+		if err != nil {
+			return object.TreeEntry{}, false, err
+		}
+
+		r, err := blob.Reader()
+		if err != nil {
+			return object.TreeEntry{}, false, err
+		}
+
+		contents[i], err = io.ReadAll(r)
+		r.Close() //nolint:errcheck
+		if err != nil {
+			return object.TreeEntry{}, false, err
+		}
+
+		if bytes.IndexByte(contents[i], 0) >= 0 {
+			return object.TreeEntry{}, false, nil
+		}
+	}
+
+	merged, ok := mergeLines(string(contents[0]), string(contents[1]), string(contents[2]))
+	if !ok {
+		return object.TreeEntry{}, false, nil
+	}
+
+	hash, err := storeObject(s, func(obj plumbing.EncodedObject) error {
+		obj.SetType(plumbing.BlobObject)
+
+		w, err := obj.Writer()
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, merged); err != nil {
+			w.Close() //nolint:errcheck
+			return err
+		}
+
+		return w.Close()
+	})
+	if err != nil {
+		return object.TreeEntry{}, false, err
+	}
+
+	return object.TreeEntry{Name: ours.Name, Mode: mode, Hash: hash}, true, nil
+}
+
+// matchLines returns index of the same line of other for every line of base, -1 if the line is changed
+func matchLines(dmp *diffmatchpatch.DiffMatchPatch, base, other string) []int {
+	baseRunes, otherRunes, _ := dmp.DiffLinesToRunes(base, other)
+	matches := make([]int, len(baseRunes))
+
+	i, j := 0, 0
+	for _, d := range dmp.DiffMainRunes(baseRunes, otherRunes, false) {
+		// every rune is a line
+		n := utf8.RuneCountInString(d.Text)
+
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for k := range n {
+				matches[i+k] = j + k
+			}
+			i += n
+			j += n
+		case diffmatchpatch.DiffDelete:
+			for k := range n {
+				matches[i+k] = -1
+			}
+			i += n
+		case diffmatchpatch.DiffInsert:
+			j += n
+		}
+	}
+
+	return matches
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// mergeLines is a three-way merge of texts: lines unchanged by both sides split texts into chunks,
+// a chunk changed by one side is taken from it, a chunk changed by both sides differently is a conflict
+func mergeLines(base, ours, theirs string) (string, bool) {
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = lineDiffTimeout
+
+	oursMatches := matchLines(dmp, base, ours)
+	theirsMatches := matchLines(dmp, base, theirs)
+
+	baseLines, oursLines, theirsLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	builder := &strings.Builder{}
+
+	i, j, k := 0, 0, 0
+	for {
+		// the next line which both sides have kept
+		n := i
+		for n < len(baseLines) && (oursMatches[n] < 0 || theirsMatches[n] < 0) {
+			n++
+		}
+
+		nextOurs, nextTheirs := len(oursLines), len(theirsLines)
+		if n < len(baseLines) {
+			nextOurs, nextTheirs = oursMatches[n], theirsMatches[n]
+		}
+
+		baseChunk, oursChunk, theirsChunk := baseLines[i:n], oursLines[j:nextOurs], theirsLines[k:nextTheirs]
+
+		switch {
+		case slices.Equal(oursChunk, baseChunk):
+			builder.WriteString(strings.Join(theirsChunk, ""))
+		case slices.Equal(theirsChunk, baseChunk), slices.Equal(oursChunk, theirsChunk):
+			builder.WriteString(strings.Join(oursChunk, ""))
+		default:
+			return "", false
+		}
+
+		if n == len(baseLines) {
+			return builder.String(), true
+		}
+
+		builder.WriteString(baseLines[n])
+		i, j, k = n+1, nextOurs+1, nextTheirs+1
+	}
+}
+
+// treeNode is a directory of the merged tree
+type treeNode struct {
+	files map[string]object.TreeEntry
+	dirs  map[string]*treeNode
+}
+
+// insert adds the file to the tree, it returns false if a file and a directory have the same path
+func (n *treeNode) insert(path []string, entry object.TreeEntry) bool {
+	name := path[0]
+
+	if len(path) == 1 {
+		if _, ok := n.dirs[name]; ok {
+			return false
+		}
+
+		if n.files == nil {
+			n.files = map[string]object.TreeEntry{}
+		}
+
+		n.files[name] = entry
+		return true
+	}
+
+	if _, ok := n.files[name]; ok {
+		return false
+	}
+
+	if n.dirs == nil {
+		n.dirs = map[string]*treeNode{}
+	}
+
+	dir, ok := n.dirs[name]
+	if !ok {
+		dir = &treeNode{}
+		n.dirs[name] = dir
+	}
+
+	return dir.insert(path[1:], entry)
+}
+
+func (n *treeNode) write(s storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+
+	for name, entry := range n.files {
+		entry.Name = name
+		tree.Entries = append(tree.Entries, entry)
+	}
+
+	for name, dir := range n.dirs {
+		hash, err := dir.write(s)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+
+	sort.Sort(object.TreeEntrySorter(tree.Entries))
+
+	return storeObject(s, tree.Encode)
+}
+
+// storeObject encodes the object and stores it unless the storer has it already, e.g. a tree which no side has changed
+func storeObject(s storer.EncodedObjectStorer, encode func(plumbing.EncodedObject) error) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if s.HasEncodedObject(obj.Hash()) == nil {
+		return obj.Hash(), nil
+	}
+
+	return s.SetEncodedObject(obj)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		ours     string
+		theirs   string
+		expected string
+		ok       bool
+	}{
+		{"only ours changed", "1\n2\n3\n", "1\ntwo\n3\n", "1\n2\n3\n", "1\ntwo\n3\n", true},
+		{"only theirs changed", "1\n2\n3\n", "1\n2\n3\n", "1\n2\nthree\n", "1\n2\nthree\n", true},
+		{"different lines", "1\n2\n3\n4\n", "one\n2\n3\n4\n", "1\n2\n3\nfour\n", "one\n2\n3\nfour\n", true},
+		{"same change", "1\n2\n3\n", "1\ntwo\n3\n", "1\ntwo\n3\n", "1\ntwo\n3\n", true},
+		{"insertions and deletions", "1\n2\n3\n4\n5\n", "0\n1\n2\n3\n4\n5\n", "1\n2\n3\n5\n", "0\n1\n2\n3\n5\n", true},
+		{"no newline at the end", "1\n2\n3", "one\n2\n3", "1\n2\n3\n", "one\n2\n3\n", true},
+		{"empty base", "", "", "1\n", "1\n", true},
+		{"same line changed", "1\n2\n3\n", "1\ntwo\n3\n", "1\nTWO\n3\n", "", false},
+		{"insertions at the same place", "1\n2\n", "1\na\n2\n", "1\nb\n2\n", "", false},
+		{"adjacent lines", "1\n2\n3\n", "1\ntwo\n3\n", "1\n2\nthree\n", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, ok := mergeLines(tt.base, tt.ours, tt.theirs)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, merged)
+		})
+	}
+}
+
+// storeCommit stores a commit of the empty tree with the parents
+func storeCommit(t *testing.T, s storer.EncodedObjectStorer, message string, parents ...*object.Commit) *object.Commit {
+	t.Helper()
+
+	tree := s.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+
+	treeHash, err := s.SetEncodedObject(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := &object.Commit{Author: testSignature, Committer: testSignature, Message: message, TreeHash: treeHash}
+	for _, p := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, p.Hash)
+	}
+
+	obj := s.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := object.GetCommit(s, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func Test_mergeCommits_SeveralBases(t *testing.T) {
+	s := memory.NewStorage()
+
+	// master and feature are merged into each other at the same time
+	base := storeCommit(t, s, "base")
+	master := storeCommit(t, s, "master", base)
+	feature := storeCommit(t, s, "feature", base)
+	ours := storeCommit(t, s, "merge master", feature, master)
+	theirs := storeCommit(t, s, "merge feature", master, feature)
+
+	_, _, err := mergeCommits(s, ours, theirs, testSignature, "merge")
+	assert.ErrorIs(t, err, errSeveralBases)
+
+	merged, _, err := mergeCommits(s, ours, master, testSignature, "merge")
+	assert.NoError(t, err)
+	assert.Equal(t, ours.Hash, merged)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+const (
+	pipelineFailedStatus  = "failed"
+	pipelineFailureMarker = "<!-- merge-bot:pipeline-failure -->"
+	maxErrorLines         = 15
+	fallbackTraceLines    = 10
+)
+
+var (
+	defaultErrorPatterns = []string{
+		`(?i)\berror\b`,
+		`(?i)\bfail(ed|ure)?\b`,
+		`(?i)\bpanic:`,
+		`(?i)\bexception\b`,
+	}
+
+	// ansiEscape matches color codes and GitLab collapsible section markers
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]|section_(start|end):[0-9]+:[A-Za-z0-9_.-]+(\[[^\]]*\])?\r?`)
+)
+
+type Job struct {
+	ID            int64
+	Name          string
+	Stage         string
+	WebURL        string
+	FailureReason string
+	AllowFailure  bool
+}
+
+type failedJob struct {
+	Job
+	Lines []string
+}
+
+func traceTail(trace []byte, size int) []string {
+	if size <= 0 {
+		return nil
+	}
+
+	text := ansiEscape.ReplaceAllString(string(trace), "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	lines := make([]string, 0, size)
+	for _, l := range strings.Split(text, "\n") {
+		// carriage returns are used by progress bars, only the last state is visible
+		if i := strings.LastIndex(l, "\r"); i >= 0 {
+			l = l[i+1:]
+		}
+
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		lines = append(lines, l)
+	}
+
+	if len(lines) > size {
+		lines = lines[len(lines)-size:]
+	}
+
+	return lines
+}
+
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			logger.Info("error pattern is invalid", "pattern", p, "err", err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled
+}
+
+func extractErrorLines(lines []string, patterns []*regexp.Regexp) []string {
+	found := make([]string, 0, maxErrorLines)
+
+	for _, l := range lines {
+		for _, re := range patterns {
+			if re.MatchString(l) {
+				found = append(found, l)
+				break
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		if len(lines) > fallbackTraceLines {
+			return lines[len(lines)-fallbackTraceLines:]
+		}
+		return lines
+	}
+
+	if len(found) > maxErrorLines {
+		found = found[len(found)-maxErrorLines:]
+	}
+
+	return found
+}
+
+func pipelineFailureText(pipelineID int64, pipelineURL string, jobs []failedJob, total int) string {
+	const jobText = `
+<details>
+<summary>
+%s
+</summary>
+
+%s
+</details>
+`
+
+	builder := &strings.Builder{}
+	builder.WriteString(pipelineFailureMarker)
+	builder.WriteString("\n")
+
+	pipeline := fmt.Sprintf("#%d", pipelineID)
+	if pipelineURL != "" {
+		pipeline = fmt.Sprintf("[#%d](%s)", pipelineID, pipelineURL)
+	}
+
+	fmt.Fprintf(builder, "🚨 **Pipeline %s failed** — %s\n", pipeline, english.Plural(total, "failed job", ""))
+
+	for _, j := range jobs {
+		summary := fmt.Sprintf("%s: <a href=\"%s\">%s</a>", j.Stage, j.WebURL, j.Name)
+		if j.FailureReason != "" {
+			summary = fmt.Sprintf("%s (%s)", summary, strings.ReplaceAll(j.FailureReason, "_", " "))
+		}
+
+		trace := "_trace is empty_"
+		if len(j.Lines) > 0 {
+			trace = "```\n" + strings.Join(j.Lines, "\n") + "\n```"
+		}
+
+		fmt.Fprintf(builder, jobText, summary, trace)
+	}
+
+	if total > len(jobs) {
+		rest := total - len(jobs)
+		fmt.Fprintf(builder, "\n…and %d more %s\n", rest, english.PluralWord(rest, "job", ""))
+	}
+
+	return builder.String()
+}
+
+func (r Request) SummarizePipelineFailure() error {
+	settings := r.config.PipelineFailureSummary
+	if !settings.Enabled {
+		return nil
+	}
+
+	if r.info.PipelineID == 0 || r.info.PipelineStatus != pipelineFailedStatus {
+		return nil
+	}
+
+	metrics.BackgroundRunInc("pipeline_failure_summary")
+
+	jobs, err := r.provider.ListFailedJobs(r.info.ProjectID, r.info.PipelineID)
+	if err != nil {
+		return fmt.Errorf("ListFailedJobs returns error: %w", err)
+	}
+
+	patterns := compilePatterns(settings.ErrorPatterns)
+	// at least one job is listed, otherwise the summary says nothing
+	maxJobs := max(settings.MaxJobs, 1)
+	failed := make([]failedJob, 0, len(jobs))
+	total := 0
+
+	for _, j := range jobs {
+		if j.AllowFailure {
+			continue
+		}
+
+		total++
+		if len(failed) >= maxJobs {
+			continue
+		}
+
+		job := failedJob{Job: j}
+		trace, err := r.provider.GetJobTrace(r.info.ProjectID, j.ID)
+		if err != nil {
+			logger.Info("GetJobTrace returns error, job is listed without trace", "job", j.ID, "err", err)
+		} else {
+			job.Lines = extractErrorLines(traceTail(trace, settings.TraceLines), patterns)
+		}
+
+		failed = append(failed, job)
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	return r.provider.LeaveOrUpdateComment(
+		r.info.ProjectID,
+		r.info.ID,
+		pipelineFailureMarker,
+		pipelineFailureText(r.info.PipelineID, r.info.PipelineURL, failed, total),
+	)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_traceTail(t *testing.T) {
+	trace := "\x1b[0Ksection_start:1700000000:step_script\r\x1b[0K\x1b[32;1m$ go test ./...\x1b[0;m\n" +
+		"ok  \tpkg/a\t0.1s\n" +
+		"downloading 10%\rdownloading 100%\n" +
+		"\n" +
+		"--- FAIL: TestB (0.00s)\n" +
+		"section_end:1700000001:step_script\r\x1b[0K\n"
+
+	assert.Equal(t, []string{"ok  \tpkg/a\t0.1s", "downloading 100%", "--- FAIL: TestB (0.00s)"}, traceTail([]byte(trace), 3))
+	assert.Equal(t, []string{"--- FAIL: TestB (0.00s)"}, traceTail([]byte(trace), 1))
+	assert.Nil(t, traceTail([]byte(trace), 0))
+	assert.Nil(t, traceTail([]byte(trace), -1))
+}
+
+func Test_extractErrorLines(t *testing.T) {
+	patterns := compilePatterns(append(defaultErrorPatterns, "("))
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "matching lines",
+			lines: []string{"building", "main.go:10: undefined: foo", "Error: exit status 1", "done"},
+			want:  []string{"Error: exit status 1"},
+		},
+		{
+			name:  "fallback to tail",
+			lines: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+			want:  []string{"3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, extractErrorLines(tt.lines, patterns))
+		})
+	}
+}
+
+func TestRequest_SummarizePipelineFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      *testProvider
+		wantComment   bool
+		wantSubstring []string
+	}{
+		{
+			name: "disabled",
+			provider: &testProvider{
+				state:          "opened",
+				pipelineID:     10,
+				pipelineStatus: "failed",
+				jobs:           []Job{{ID: 1, Name: "test", Stage: "test"}},
+			},
+			wantComment: false,
+		},
+		{
+			name: "pipeline succeeded",
+			provider: &testProvider{
+				state:          "opened",
+				config:         "pipeline_failure_summary: {enabled: true}",
+				pipelineID:     10,
+				pipelineStatus: "success",
+			},
+			wantComment: false,
+		},
+		{
+			name: "only allowed failures",
+			provider: &testProvider{
+				state:          "opened",
+				config:         "pipeline_failure_summary: {enabled: true}",
+				pipelineID:     10,
+				pipelineStatus: "failed",
+				jobs:           []Job{{ID: 1, Name: "lint", Stage: "test", AllowFailure: true}},
+			},
+			wantComment: false,
+		},
+		{
+			name: "failed jobs",
+			provider: &testProvider{
+				state:          "opened",
+				config:         "pipeline_failure_summary: {enabled: true, max_jobs: 1, error_patterns: ['^E:']}",
+				pipelineID:     10,
+				pipelineStatus: "failed",
+				jobs: []Job{
+					{ID: 1, Name: "build", Stage: "build", WebURL: "https://gitlab/jobs/1", FailureReason: "script_failure"},
+					{ID: 2, Name: "deploy", Stage: "deploy"},
+				},
+				traces: map[int64]string{1: "compiling\nE: no space left on device\nexit 1\n"},
+			},
+			wantComment: true,
+			wantSubstring: []string{
+				pipelineFailureMarker,
+				"**Pipeline #10 failed** — 2 failed jobs",
+				`build: <a href="https://gitlab/jobs/1">build</a> (script failure)`,
+				"```\nE: no space left on device\n```",
+				"…and 1 more job",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{provider: tt.provider}
+			if err := r.LoadInfoAndConfig(1, 2); err != nil {
+				t.Fatalf("LoadInfoAndConfig failed: %v", err)
+			}
+
+			assert.NoError(t, r.SummarizePipelineFailure())
+			assert.Equal(t, tt.wantComment, tt.provider.commentCalled)
+
+			if tt.wantComment {
+				assert.Equal(t, pipelineFailureMarker, tt.provider.lastMarker)
+				for _, s := range tt.wantSubstring {
+					assert.Contains(t, tt.provider.lastComment, s)
+				}
+				assert.NotContains(t, tt.provider.lastComment, "deploy</a>")
+			}
+		})
+	}
+}
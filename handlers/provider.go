@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dustin/go-humanize/english"
 )
@@ -25,6 +26,11 @@ var (
 	DiscussionError        = &Error{"Could not find resolvable discussion for merge request"}
 	CommitNotFoundError    = &Error{"Commit was not found"}
 	ReviewersAssignedError = &Error{"MR has reviewers"}
+	NotReviewerError       = &Error{"User is not a reviewer of MR"}
+	AlreadyExistsError     = &Error{"Resource already exists"}
+	PeriodError            = &Error{"Period is invalid"}
+	CheckingError          = &Error{"Merge status is being checked"}
+	BudgetError            = &Error{"API calls of the operation are spent"}
 )
 
 type Error struct {
@@ -47,6 +53,7 @@ type MrInfo struct {
 	Labels          []string
 	TargetBranch    string
 	SourceBranch    string
+	SourceProjectID int64
 	Approvals       map[string]struct{}
 	Reviewers       []string
 	Author          string
@@ -55,7 +62,26 @@ type MrInfo struct {
 	Title           string
 	Description     string
 	ConfigContent   string
-	IsValid         bool
+	PipelineID      int64
+	PipelineStatus  string
+	PipelineURL     string
+	// MergedSHA is the head of the target branch after MR is merged, empty until then
+	MergedSHA string
+	IsValid   bool
+}
+
+// Committer is the author of the last commit of a branch
+type Committer struct {
+	Name  string
+	Email string
+	// Username is empty if the email doesn't belong to a known user
+	Username string
+}
+
+// Comment is a note of the bot in MR
+type Comment struct {
+	Body      string
+	CreatedAt time.Time
 }
 
 type Candidate struct {
@@ -65,33 +91,30 @@ type Candidate struct {
 	Status      string
 	Timezone    string
 	IsCodeOwner bool
-}
-
-func (c Candidate) IsAvailable() bool {
-	status := strings.ToLower(c.Status)
-
-	for _, s := range vacationStatuses {
-		if strings.Contains(status, s) {
-			return false
-		}
-	}
-
-	return !slices.Contains(emojiStatuses, c.StatusEmoji)
-}
-
-func (c Candidate) IsBot() bool {
-	for _, s := range botNicks {
-		if strings.Contains(strings.ToLower(c.Username), s) {
-			return true
-		}
-	}
-	return false
+	OpenReviews int
+	WorkingTier int
+	// Expertise is from 0 to 1, 1 is the best expert in the changed files
+	Expertise float64
+	Reasons   []string
+	// OutOfOffice is the vacation event from the personal calendar
+	OutOfOffice string
+	// Bot is true for bot and service accounts of the platform
+	Bot bool
 }
 
 type RouletteResult struct {
 	TotalPlayers       int
 	UnavailablePlayers int
+	OverloadedPlayers  int
 	Winners            []string
+	// OffHours are winners who are out of working hours now
+	OffHours []string
+	// Pools explain which teams reviewers were picked from
+	Pools []string
+	// Reasons explain expertise of winners
+	Reasons map[string][]string
+	// OpenReviews is the number of open MRs awaiting review of every winner, nil if it is unknown
+	OpenReviews map[string]int
 }
 
 func (r RouletteResult) String() string {
@@ -101,21 +124,37 @@ func (r RouletteResult) String() string {
 Roulette rules:
 </summary>
 <pre>
-- Fetched all MR authors for last 3 months
-- Filtered only users with contributors permissions
+- Fetched all MR authors for last lookback_days days
+- Filtered only users with min_access_level or higher
 - Excluded:
   - usernames from .mrbot.yaml config
-  - inactive users and bots
-  - users with emoji status: 🏖️, 🔴, ⛔, 🌴
-  - users with status: ooo, vacation, travel and parental leave
+  - inactive users, bot accounts and bot_usernames
+  - users with emoji_statuses, e.g. 🏖️, 🔴, ⛔, 🌴
+  - users with vacation_statuses, e.g. ooo, vacation, travel and parental leave
+  - users on vacation according to their calendars
+  - users who have max_open_reviews or more open MRs to review
+- With teams, reviewers are picked from the teams owning the changed files
 - CODEOWNERS have higher priority
+- Users in working hours now (or soon together with the author) have higher priority
+- Users with fewer open MRs to review have higher priority
+- Users with fewer assignments for last fairness_days days have higher priority
+- With expertise, people who recently changed or approved the changed files have higher priority
 </pre>
 </details>
 `
 
 	formatUsernames := make([]string, 0, len(r.Winners))
 	for _, u := range r.Winners {
-		formatUsernames = append(formatUsernames, "@"+u)
+		name := "@" + u
+		if load, ok := r.OpenReviews[u]; ok {
+			name += fmt.Sprintf(" (%s)", english.Plural(load, "open review", ""))
+		}
+
+		if slices.Contains(r.OffHours, u) {
+			name += " 🌙"
+		}
+
+		formatUsernames = append(formatUsernames, name)
 	}
 
 	unavailableMessage := ""
@@ -124,11 +163,33 @@ Roulette rules:
 		unavailableMessage = fmt.Sprintf(", %s - unavailable", players)
 	}
 
+	if r.OverloadedPlayers > 0 {
+		players := english.Plural(r.OverloadedPlayers, "player", "")
+		unavailableMessage += fmt.Sprintf(", %s - overloaded", players)
+	}
+
+	poolsMessage := ""
+	if len(r.Pools) > 0 {
+		poolsMessage = "\n\n 👥 Teams:\n- " + strings.Join(r.Pools, "\n- ")
+	}
+
+	reasons := []string{}
+	for _, u := range r.Winners {
+		if len(r.Reasons[u]) > 0 {
+			reasons = append(reasons, fmt.Sprintf("@%s %s", u, strings.Join(r.Reasons[u], ", ")))
+		}
+	}
+
+	if len(reasons) > 0 {
+		poolsMessage += "\n\n 🔎 Expertise:\n- " + strings.Join(reasons, "\n- ")
+	}
+
 	return fmt.Sprintf(
-		"🎲 **Review Roulette** — %d contributors in the pool%s\n\n 🧠 Reviewers selected: %s\n\n %s",
+		"🎲 **Review Roulette** — %d contributors in the pool%s\n\n 🧠 Reviewers selected: %s%s\n\n %s",
 		r.TotalPlayers,
 		unavailableMessage,
 		strings.Join(formatUsernames, ", "),
+		poolsMessage,
 		rules,
 	)
 }
@@ -136,10 +197,21 @@ Roulette rules:
 type Branches interface {
 	ListBranches(projectID, size int64, protected bool) iter.Seq[StaleBranch]
 	DeleteBranch(projectID int64, name string) error
+	CreateBranch(projectID int64, name, ref string) error
+	CreateTag(projectID int64, name, ref string) error
+	// ListTags returns names of tags starting with the prefix
+	ListTags(projectID int64, prefix string) ([]string, error)
+	LastCommitter(projectID int64, branch string) (*Committer, error)
+	CommentCommit(projectID int64, sha, message string) error
+	// CompareBranch compares the branch with the default branch
+	CompareBranch(projectID int64, branch string) (*BranchDiff, error)
 }
 
 type Comments interface {
 	LeaveComment(projectID, mergeID int64, message string) error
+	LeaveOrUpdateComment(projectID, mergeID int64, marker, message string) error
+	// LastComment returns the latest comment of the bot which contains the marker, nil if there is none
+	LastComment(projectID, mergeID int64, marker string) (*Comment, error)
 	AwardEmoji(projectID, mergeID, noteID int64, emoji string) error
 }
 
@@ -153,11 +225,26 @@ type MergeRequest interface {
 	Merge(projectID, mergeID int64, message string) error
 	GetMRInfo(projectID, mergeID int64, path string) (*MrInfo, error)
 	ListMergeRequests(projectID, size int64, protected bool) iter.Seq[MR]
-	FindMergeRequests(projectID int64, targetBranch, label string) ([]MR, error)
+	// FindMergeRequests returns open MRs into the target branch with the label, any MRs if the label is empty
+	FindMergeRequests(projectID int64, targetBranch, label string) iter.Seq[MR]
+	// FindMergeRequestsWithoutPipeline is FindMergeRequests which doesn't load pipeline statuses of MRs
+	FindMergeRequestsWithoutPipeline(projectID int64, targetBranch, label string) iter.Seq[MR]
 	UpdateFromMaster(projectID, mergeID int64) error
 	AssignLabel(projectID, mergeID int64, name, color string) error
+	RemoveLabel(projectID, mergeID int64, name string) error
+	CloseMergeRequest(projectID, mergeID int64) error
+	// HasConflicts waits until the provider checks whether MR can be merged
+	HasConflicts(projectID, mergeID int64) (bool, error)
+	// ConflictingFiles merges the target branch into the source one without pushing and returns files with conflicts
+	ConflictingFiles(projectID, mergeID int64) ([]string, error)
+	// SetDraft marks MR as draft, it does nothing with drafts
+	SetDraft(projectID, mergeID int64) error
 	GetRawDiffs(projectID, mergeID int64) ([]byte, error)
+	ListOpenReviews(projectID int64) iter.Seq[Review]
+	GetReviewActivity(projectID, mergeID int64) (*ReviewActivity, error)
+	GetChangedFiles(projectID, mergeID int64) ([]string, error)
 	AssignReviewers(projectID, mergeID int64, users []string) error
+	CountOpenReviews(usernames []string) (map[string]int, error)
 }
 
 type Project interface {
@@ -165,8 +252,25 @@ type Project interface {
 	GetVar(projectID int64, varName string) (string, error)
 	RerunPipeline(projectID, pipelineID int64, ref string) (string, error)
 	GetFile(projectID int64, path string) ([]byte, error)
+	GetProjectFile(project, path, ref string) ([]byte, error)
+	// UpsertIssue updates the open issue of the bot with the title or creates it
+	UpsertIssue(projectID int64, title, description string) error
 	IsHealthy() bool
-	GetContributors(projectID, mergeID int64) ([]Candidate, error)
+	GetContributors(projectID, mergeID int64, settings Candidates) ([]Candidate, error)
+	// GetMembers returns candidates among members of the project with the usernames
+	GetMembers(projectID, mergeID int64, usernames []string, settings Candidates) ([]Candidate, error)
+	GetGroupMembers(group string) ([]string, error)
+}
+
+type History interface {
+	// ListPathContributions spends the budget on API calls, it returns contributions found so far and BudgetError
+	// when the budget runs out
+	ListPathContributions(projectID int64, path string, since time.Time, limit int, budget *CallBudget) ([]Contribution, error)
+}
+
+type Pipelines interface {
+	ListFailedJobs(projectID, pipelineID int64) ([]Job, error)
+	GetJobTrace(projectID, jobID int64) ([]byte, error)
 }
 
 type RequestProvider interface {
@@ -175,6 +279,8 @@ type RequestProvider interface {
 	MergeRequest
 	Project
 	Discussions
+	Pipelines
+	History
 }
 
 type Rules struct {
@@ -187,13 +293,36 @@ type Rules struct {
 }
 
 type AssignReviewers struct {
-	Enabled          bool     `yaml:"enabled"`
-	UseCodeowners    bool     `yaml:"use_codeowners"`
-	ReviewerNumber   int      `yaml:"reviewer_number"`
-	ExcludeUsernames []string `yaml:"exclude_usernames"`
+	Enabled          bool         `yaml:"enabled"`
+	UseCodeowners    bool         `yaml:"use_codeowners"`
+	ReviewerNumber   int          `yaml:"reviewer_number"`
+	ExcludeUsernames []string     `yaml:"exclude_usernames"`
+	MaxOpenReviews   int          `yaml:"max_open_reviews"`
+	WorkingHours     WorkingHours `yaml:"working_hours"`
+	Teams            []Team       `yaml:"teams"`
+	Expertise        Expertise    `yaml:"expertise"`
+	// CrossTeamReviewers are picked from outside of the author's team in addition to reviewer_number
+	CrossTeamReviewers int `yaml:"cross_team_reviewers"`
+	// FairnessDays is how long assignments count against reviewers, the assignment log is kept across restarts by Redis only
+	FairnessDays int        `yaml:"fairness_days"`
+	Candidates   Candidates `yaml:"candidates"`
+}
+
+type PipelineFailureSummary struct {
+	Enabled       bool     `yaml:"enabled"`
+	ErrorPatterns []string `yaml:"error_patterns"`
+	TraceLines    int      `yaml:"trace_lines"`
+	MaxJobs       int      `yaml:"max_jobs"`
+}
+
+type ConfigValidation struct {
+	Enabled  bool `yaml:"enabled"`
+	Blocking bool `yaml:"blocking"`
 }
 
 type Config struct {
+	Extends string `yaml:"extends,omitempty"`
+
 	Rules Rules `yaml:"rules"`
 
 	Greetings struct {
@@ -202,18 +331,43 @@ type Config struct {
 		Template   string `yaml:"template"`
 	} `yaml:"greetings"`
 
-	AutoMasterMerge bool            `yaml:"auto_master_merge"`
-	AssignReviewers AssignReviewers `yaml:"review_roulette"`
+	AutoMasterMerge bool                `yaml:"auto_master_merge"`
+	AssignReviewers AssignReviewers     `yaml:"review_roulette"`
+	Reviewers       map[string]Reviewer `yaml:"reviewers"`
+	ReviewReminders ReviewReminders     `yaml:"review_reminders"`
 
 	StaleBranchesDeletion struct {
-		Enabled         bool     `yaml:"enabled"`
+		Enabled bool `yaml:"enabled"`
+		// ExcludeBranches are names, globs like release/* and regexes prefixed with re:
 		ExcludeBranches []string `yaml:"exclude_branches"`
-		Protected       bool     `yaml:"protected"`
-		Days            int      `yaml:"days"`
-		BatchSize       int64    `yaml:"batch_size"`
-		WaitDays        int      `yaml:"wait_days"`
+		// ExcludeAuthors are case-insensitive globs of usernames, names and emails of authors of MRs and last commits
+		ExcludeAuthors []string `yaml:"exclude_authors"`
+		// MinAgeDays keeps branches whose first commit which isn't on the default branch is younger
+		MinAgeDays int `yaml:"min_age_days"`
+		// ExcludeUnmerged keeps branches without MRs which have commits that aren't on the default branch
+		ExcludeUnmerged bool  `yaml:"exclude_unmerged"`
+		Protected       bool  `yaml:"protected"`
+		Days            int   `yaml:"days"`
+		BatchSize       int64 `yaml:"batch_size"`
+		// WaitDays is the grace period after a stale MR is labelled or the author of a stale branch is notified
+		WaitDays int `yaml:"wait_days"`
+		// DryRun reports what would be deleted in a project issue instead of deleting
+		DryRun bool `yaml:"dry_run"`
+		// Archive keeps deleted branches, "tag" or empty
+		Archive string `yaml:"archive"`
+		// MRAction is done with stale MRs after wait_days: delete_branch, close, close_and_delete_branch or draft
+		MRAction string `yaml:"mr_action"`
 	} `yaml:"stale_branches_deletion"`
 
+	PipelineFailureSummary PipelineFailureSummary `yaml:"pipeline_failure_summary"`
+
+	ConflictWarnings ConflictWarnings `yaml:"conflict_warnings"`
+
+	ConfigValidation ConfigValidation `yaml:"config_validation"`
+
+	BranchRules       []BranchRule `yaml:"branch_rules"`
+	AppliedBranchRule string       `yaml:"-"`
+
 	PluginVars map[string]string `yaml:"plugin_vars"`
 }
 
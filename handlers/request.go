@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"math/rand"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/dustin/go-humanize/english"
 	"github.com/gasoid/merge-bot/v3/cache"
 	"github.com/gasoid/merge-bot/v3/logger"
 	"github.com/gasoid/merge-bot/v3/metrics"
@@ -25,16 +28,14 @@ const (
 	IncrCount            = "update"
 )
 
-var (
-	vacationStatuses = []string{"ooo", "vacation", "travel", "parental leave"}
-	emojiStatuses    = []string{"beach", "beach_umbrella", "palm_tree", "red_circle", "no_entry"}
-	botNicks         = []string{"bot", "jira", "gitlab", "github"}
-)
-
 type Request struct {
-	provider RequestProvider
-	info     *MrInfo
-	config   *Config
+	provider     RequestProvider
+	info         *MrInfo
+	config       *Config
+	configIssues []ConfigIssue
+	// configFallback describes the config which is used instead of the invalid one, empty if the config is used
+	configFallback string
+	changedFiles   []string
 }
 
 func (r *Request) LoadInfoAndConfig(projectId, id int64) error {
@@ -44,11 +45,84 @@ func (r *Request) LoadInfoAndConfig(projectId, id int64) error {
 		return err
 	}
 
-	r.config, err = r.ParseConfig(r.info.ConfigContent)
-	if err != nil {
+	if err := r.loadConfig(r.info.ConfigContent); err != nil {
+		return err
+	}
+
+	return r.applyBranchRules()
+}
+
+// LoadProject prepares the request for project events which don't belong to any MR, e.g. branch pushes
+func (r *Request) LoadProject(projectId int64) error {
+	r.info = &MrInfo{ProjectID: projectId}
+	r.config = defaultConfig()
+	return nil
+}
+
+// LoadProjectConfig loads the config from the default branch, it is used by jobs which don't belong to MR
+func (r *Request) LoadProjectConfig(projectId int64) error {
+	r.info = &MrInfo{ProjectID: projectId}
+
+	content, err := r.provider.GetFile(projectId, configPath)
+	if err != nil && !errors.Is(err, NotFoundError) {
+		return err
+	}
+
+	return r.loadConfig(string(content))
+}
+
+// loadConfig parses the config of the project. A config with values which can't be used isn't used at all:
+// the last valid config of the project replaces it, or defaults if there is none, and problems of merge rules block merging.
+func (r *Request) loadConfig(content string) error {
+	config, err := r.ParseConfig(content)
+	if err == nil {
+		r.config = config
+
+		if err := cache.SetValidConfig(r.info.ProjectID, content); err != nil {
+			logger.Info("valid config can't be saved", "projectId", r.info.ProjectID, "err", err)
+		}
+
+		return nil
+	}
+
+	configErr := &ConfigError{}
+	if !errors.As(err, &configErr) || config == nil {
 		return err
 	}
 
+	logger.Info("config has problems", "projectId", r.info.ProjectID, "err", err)
+	r.config = config
+	r.configIssues = configErr.Issues
+
+	if configErr.hasInvalidValues() {
+		r.config, r.configFallback = r.lastValidConfig()
+	}
+
+	return nil
+}
+
+// lastValidConfig returns the last config of the project without problems and its description, defaults if it is unknown
+func (r *Request) lastValidConfig() (*Config, string) {
+	content, ok, err := cache.GetValidConfig(r.info.ProjectID)
+	if err != nil {
+		logger.Info("valid config can't be loaded", "projectId", r.info.ProjectID, "err", err)
+	}
+
+	if ok {
+		if config, err := r.ParseConfig(content); err == nil {
+			return config, "the last valid config is used instead"
+		}
+	}
+
+	return defaultConfig(), "default settings are used instead"
+}
+
+// BranchPushed keeps cached files of the project in sync with its default branch
+func (r *Request) BranchPushed(branch, sha string) error {
+	if err := cache.UpdateProjectHead(r.info.ProjectID, branch, sha); err != nil {
+		return fmt.Errorf("project cache can't be updated: %w", err)
+	}
+
 	return nil
 }
 
@@ -77,11 +151,46 @@ func (r *Request) IsValid() (bool, string, error) {
 		resultOk = false
 	}
 
+	if r.config.AppliedBranchRule != "" {
+		result = append(result, fmt.Sprintf("ℹ️ Rules are overridden by branch_rules: `%s`", r.config.AppliedBranchRule))
+	}
+
+	if len(r.configIssues) > 0 {
+		message := fmt.Sprintf("⚠️ Config has %s, send `!config` to see details", english.Plural(len(r.configIssues), "problem", ""))
+		if r.configFallback != "" {
+			message += ", " + r.configFallback
+		}
+
+		result = append(result, message)
+	}
+
+	// a typo in rules must not remove a merge gate
+	if slices.ContainsFunc(r.configIssues, ConfigIssue.inRules) {
+		result = append(result, "Merge rules of the config have problems ❌")
+		resultOk = false
+	}
+
+	if r.config.ConfigValidation.Enabled && r.config.ConfigValidation.Blocking {
+		check, err := r.checkConfigChange()
+		if err != nil {
+			return false, "", err
+		}
+
+		if check.Required {
+			if check.Passed {
+				result = append(result, check.Message+" ✅")
+			} else {
+				result = append(result, check.Message+" ❌")
+				resultOk = false
+			}
+		}
+	}
+
 	return resultOk, strings.Join(result, "\n\n"), nil
 }
 
-func (r *Request) ParseConfig(content string) (*Config, error) {
-	mrConfig := &Config{
+func defaultConfig() *Config {
+	return &Config{
 		Rules: Rules{
 			MinApprovals:          1,
 			AllowFailingPipelines: true,
@@ -103,30 +212,152 @@ func (r *Request) ParseConfig(content string) (*Config, error) {
 			UseCodeowners:    true,
 			ReviewerNumber:   2,
 			ExcludeUsernames: []string{},
+			FairnessDays:     30,
+			Candidates:       defaultCandidates(),
+			WorkingHours: WorkingHours{
+				Enabled:  false,
+				Start:    "09:00",
+				End:      "18:00",
+				Days:     []string{"mon", "tue", "wed", "thu", "fri"},
+				Timezone: "UTC",
+				Holidays: []string{},
+			},
+			Expertise: Expertise{
+				Enabled:  false,
+				Weight:   2,
+				Days:     180,
+				MaxFiles: 10,
+			},
 		},
 		StaleBranchesDeletion: struct {
-			Enabled         bool     `yaml:"enabled"`
+			Enabled bool `yaml:"enabled"`
+			// ExcludeBranches are names, globs like release/* and regexes prefixed with re:
 			ExcludeBranches []string `yaml:"exclude_branches"`
-			Protected       bool     `yaml:"protected"`
-			Days            int      `yaml:"days"`
-			BatchSize       int64    `yaml:"batch_size"`
-			WaitDays        int      `yaml:"wait_days"`
+			// ExcludeAuthors are globs of usernames, names and emails of authors of MRs and last commits
+			ExcludeAuthors []string `yaml:"exclude_authors"`
+			// MinAgeDays keeps branches whose first commit which isn't on the default branch is younger
+			MinAgeDays int `yaml:"min_age_days"`
+			// ExcludeUnmerged keeps branches with commits which aren't on the default branch
+			ExcludeUnmerged bool  `yaml:"exclude_unmerged"`
+			Protected       bool  `yaml:"protected"`
+			Days            int   `yaml:"days"`
+			BatchSize       int64 `yaml:"batch_size"`
+			// WaitDays is the grace period after a stale MR is labelled or the author of a stale branch is notified
+			WaitDays int `yaml:"wait_days"`
+			// DryRun reports what would be deleted in a project issue instead of deleting
+			DryRun bool `yaml:"dry_run"`
+			// Archive keeps deleted branches, "tag" or empty
+			Archive string `yaml:"archive"`
+			// MRAction is done with stale MRs after wait_days: delete_branch, close, close_and_delete_branch or draft
+			MRAction string `yaml:"mr_action"`
 		}{
 			Enabled:         false,
 			ExcludeBranches: []string{},
+			ExcludeAuthors:  []string{},
 			Protected:       false,
 			Days:            90,
 			BatchSize:       5,
 			WaitDays:        1,
+			MRAction:        StaleMRDeleteBranch,
 		},
+		ReviewReminders: ReviewReminders{
+			Enabled:         false,
+			SLAHours:        8,
+			EscalationHours: 8,
+			Fallback:        []string{},
+			Respin:          false,
+		},
+		ConfigValidation: ConfigValidation{
+			Enabled:  false,
+			Blocking: false,
+		},
+		PipelineFailureSummary: PipelineFailureSummary{
+			Enabled:       false,
+			ErrorPatterns: slices.Clone(defaultErrorPatterns),
+			TraceLines:    200,
+			MaxJobs:       5,
+		},
+		ConflictWarnings: ConflictWarnings{
+			Enabled:          false,
+			MaxMergeRequests: 50,
+		},
+	}
+}
+
+func (r *Request) ParseConfig(content string) (*Config, error) {
+	return r.parseConfig(content, true)
+}
+
+// parseLocalConfig doesn't fetch extended configs, it is used for untrusted content, e.g. the config proposed by MR
+func (r *Request) parseLocalConfig(content string) (*Config, error) {
+	return r.parseConfig(content, false)
+}
+
+func (r *Request) parseConfig(content string, withExtends bool) (*Config, error) {
+	mrConfig := defaultConfig()
+
+	if strings.TrimSpace(content) == "" {
+		content = loadDefaultConfig()
 	}
 
-	if err := yaml.Unmarshal([]byte(content), mrConfig); err != nil {
+	expanded := content
+	if withExtends {
+		var err error
+		if expanded, err = r.expandConfig(content); err != nil {
+			return nil, err
+		}
+	}
+
+	issues := []ConfigIssue{}
+
+	if err := yaml.Unmarshal([]byte(expanded), mrConfig); err != nil {
+		typeErr := &yaml.TypeError{}
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+
+		expandedRoot := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(expanded), expandedRoot); err != nil {
+			return nil, err
+		}
+
+		for _, e := range typeErr.Errors {
+			issue := parseYamlError(e)
+			issue.Path = linePath(expandedRoot, issue.Line, "")
+			issue.invalid = true
+			issues = append(issues, issue)
+		}
+	}
+
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(content), root); err != nil {
 		return nil, err
 	}
+
+	issues = append(issues, unknownKeys(root)...)
+	issues = append(issues, validateConfig(mrConfig, root)...)
+
+	if len(issues) > 0 {
+		return mrConfig, &ConfigError{Issues: issues}
+	}
+
 	return mrConfig, nil
 }
 
+func (r *Request) getChangedFiles() ([]string, error) {
+	if r.changedFiles != nil {
+		return r.changedFiles, nil
+	}
+
+	changedFiles, err := r.provider.GetChangedFiles(r.info.ProjectID, r.info.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetChangedFiles returns error: %w", err)
+	}
+
+	r.changedFiles = changedFiles
+	return changedFiles, nil
+}
+
 func (r *Request) LeaveComment(message string) error {
 	return r.provider.LeaveComment(r.info.ProjectID, r.info.ID, message)
 }
@@ -186,6 +417,11 @@ func (r *Request) DeleteStaleBranches() error {
 
 	defer cache.ReleaseBranchDeletionLease(r.info.ProjectID)
 
+	if r.config.StaleBranchesDeletion.DryRun {
+		metrics.BackgroundRunInc("report_stale_branches")
+		return r.reportStale()
+	}
+
 	metrics.BackgroundRunInc("clean_stale_merge_requests")
 
 	if err := r.cleanStaleMergeRequests(); err != nil {
@@ -226,29 +462,6 @@ func (r Request) UpdateFromMaster() error {
 	return nil
 }
 
-func (r Request) UpdateBranches() error {
-	listMr, err := r.provider.FindMergeRequests(r.info.ProjectID, r.info.TargetBranch, autoUpdateLabel)
-	if err != nil {
-		return err
-	}
-
-	if !cache.AcquireUpdateLease(r.info.ProjectID) {
-		return nil
-	}
-
-	defer cache.ReleaseUpdateLease(r.info.ProjectID)
-
-	for _, mr := range listMr {
-		metrics.BackgroundRunInc("update_branch")
-
-		if err := r.provider.UpdateFromMaster(r.info.ProjectID, mr.ID); err != nil {
-			logger.Info("UpdateFromDestination", "err", err)
-		}
-	}
-
-	return nil
-}
-
 func (r Request) CreateLabels() error {
 	if err := r.provider.CreateLabel(r.info.ProjectID, staleLabel, staleLabelColor); err != nil {
 		return err
@@ -281,40 +494,115 @@ func (r Request) AwardEmoji(noteID int64, emoji string) error {
 	return r.provider.AwardEmoji(r.info.ProjectID, r.info.ID, noteID, emoji)
 }
 
+func (r Request) isEligible(c Candidate) bool {
+	settings := r.config.AssignReviewers.Candidates
+	if !settings.isAvailable(c) || settings.isBot(c) || c.Username == r.info.Author {
+		return false
+	}
+
+	return !slices.Contains(r.config.AssignReviewers.ExcludeUsernames, c.Username)
+}
+
+// loadOpenReviews fills the number of open MRs awaiting review of every candidate, it returns false if loads are unknown
+func (r Request) loadOpenReviews(gamblers []Candidate) bool {
+	usernames := make([]string, 0, len(gamblers))
+	for _, g := range gamblers {
+		usernames = append(usernames, g.Username)
+	}
+
+	loads, err := r.provider.CountOpenReviews(usernames)
+	if err != nil {
+		logger.Info("CountOpenReviews returns error, roulette ignores workload", "err", err)
+		return false
+	}
+
+	for i := range gamblers {
+		gamblers[i].OpenReviews = loads[gamblers[i].Username]
+	}
+
+	return true
+}
+
+// spinRoulette picks num winners, cross_team_reviewers are picked in addition to them
 func (r Request) spinRoulette(num int) (*RouletteResult, error) {
-	gamblers, err := r.provider.GetContributors(r.info.ProjectID, r.info.ID)
+	return r.spin(num, r.config.AssignReviewers.CrossTeamReviewers)
+}
+
+// spinExactly picks num winners to add or replace some reviewers, cross_team_reviewers are picked by the first spin only
+func (r Request) spinExactly(num int) (*RouletteResult, error) {
+	return r.spin(num, 0)
+}
+
+func (r Request) spin(num, crossTeam int) (*RouletteResult, error) {
+	gamblers, err := r.provider.GetContributors(r.info.ProjectID, r.info.ID, r.config.AssignReviewers.Candidates)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(r.config.AssignReviewers.Teams) > 0 {
+		gamblers = r.withTeamMembers(gamblers)
+	}
+
 	result := RouletteResult{
 		TotalPlayers: len(gamblers),
 	}
 
-	counts, err := cache.GetCounts(r.info.ProjectID)
+	now := time.Now()
+
+	counts, err := r.assignmentCounts(now)
 	if err != nil {
-		return nil, err
+		logger.Info("assignments are unknown, roulette ignores previous assignments", "err", err)
 	}
 
-	if len(counts) > 0 {
-		for i := range gamblers {
-			if v, ok := counts[gamblers[i].Username]; ok {
-				gamblers[i].Count = v
-			}
-		}
+	for i := range gamblers {
+		gamblers[i].Count = counts[gamblers[i].Username]
 	}
 
 	for i := range gamblers {
-		if !gamblers[i].IsAvailable() {
+		if !r.config.AssignReviewers.Candidates.isAvailable(gamblers[i]) {
 			result.UnavailablePlayers++
 		}
 	}
 
+	contributors := slices.Clone(gamblers)
+	gamblers = slices.DeleteFunc(gamblers, func(c Candidate) bool {
+		return !r.isEligible(c)
+	})
+
+	// calendars are fetched for eligible candidates only
+	r.setOutOfOffice(gamblers, now)
+	gamblers = slices.DeleteFunc(gamblers, func(c Candidate) bool {
+		if c.OutOfOffice != "" {
+			result.UnavailablePlayers++
+			return true
+		}
+		return false
+	})
+
+	workingHours := r.config.AssignReviewers.WorkingHours.Enabled
+	if workingHours {
+		r.setWorkingTiers(gamblers, contributors, now)
+	}
+
+	expertise := r.config.AssignReviewers.Expertise.Enabled && r.loadExpertise(gamblers, now)
+
+	hasLoads := r.loadOpenReviews(gamblers)
+
+	if maxOpen := r.config.AssignReviewers.MaxOpenReviews; hasLoads && maxOpen > 0 {
+		gamblers = slices.DeleteFunc(gamblers, func(c Candidate) bool {
+			if c.OpenReviews >= maxOpen {
+				result.OverloadedPlayers++
+				return true
+			}
+			return false
+		})
+	}
+
 	rand.Shuffle(len(gamblers)/2, func(i, j int) {
 		gamblers[i], gamblers[j] = gamblers[j], gamblers[i]
 	})
 
-	sort.Slice(gamblers, func(i, j int) bool {
+	sort.SliceStable(gamblers, func(i, j int) bool {
 		if r.config.AssignReviewers.UseCodeowners {
 			if gamblers[i].IsCodeOwner && !gamblers[j].IsCodeOwner {
 				return true
@@ -325,31 +613,52 @@ func (r Request) spinRoulette(num int) (*RouletteResult, error) {
 			}
 		}
 
-		return gamblers[i].Count < gamblers[j].Count
-	})
+		if gamblers[i].WorkingTier != gamblers[j].WorkingTier {
+			return gamblers[i].WorkingTier < gamblers[j].WorkingTier
+		}
 
-	usernames := make([]string, 0, r.config.AssignReviewers.ReviewerNumber)
+		if expertise {
+			weight := r.config.AssignReviewers.Expertise.Weight
+			if bi, bj := gamblers[i].balance(weight), gamblers[j].balance(weight); bi != bj {
+				return bi < bj
+			}
+		}
 
-	for _, g := range gamblers {
-		if !g.IsAvailable() {
-			continue
+		if gamblers[i].OpenReviews != gamblers[j].OpenReviews {
+			return gamblers[i].OpenReviews < gamblers[j].OpenReviews
 		}
 
-		if g.IsBot() {
-			continue
+		return gamblers[i].Count < gamblers[j].Count
+	})
+
+	winners := gamblers[:min(num, len(gamblers))]
+	if len(r.config.AssignReviewers.Teams) > 0 {
+		winners, result.Pools, err = r.pickByTeams(gamblers, num, crossTeam)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if g.Username == r.info.Author {
-			continue
+	usernames := make([]string, 0, len(winners))
+	if hasLoads {
+		result.OpenReviews = make(map[string]int, len(winners))
+	}
+
+	for _, g := range winners {
+		usernames = append(usernames, g.Username)
+		if hasLoads {
+			result.OpenReviews[g.Username] = g.OpenReviews
 		}
 
-		if slices.Contains(r.config.AssignReviewers.ExcludeUsernames, g.Username) {
-			continue
+		if workingHours && g.WorkingTier != tierWorking {
+			result.OffHours = append(result.OffHours, g.Username)
 		}
 
-		usernames = append(usernames, g.Username)
-		if len(usernames) == num {
-			break
+		if expertise && len(g.Reasons) > 0 {
+			if result.Reasons == nil {
+				result.Reasons = map[string][]string{}
+			}
+			result.Reasons[g.Username] = g.Reasons
 		}
 	}
 
@@ -369,17 +678,18 @@ func (r Request) reviewRoulette(num int) (*RouletteResult, error) {
 	}
 
 	logger.Debug("usernames for review", "usernames", result.Winners)
-	for _, u := range result.Winners {
-		if _, err := cache.IncrCount(r.info.ProjectID, u); err != nil {
-			logger.Error("can't increment count", "err", err)
-		}
-	}
 
 	return result, nil
 }
 
-func (r Request) AssignReviewers(usernames []string) error {
-	return r.provider.AssignReviewers(r.info.ProjectID, r.info.ID, usernames)
+// AssignReviewers assigns roulette winners to MR, the assignments are logged once they are made
+func (r Request) AssignReviewers(winners []string) error {
+	if err := r.provider.AssignReviewers(r.info.ProjectID, r.info.ID, winners); err != nil {
+		return err
+	}
+
+	r.recordAssignments(winners, AssignedByRoulette, time.Now())
+	return nil
 }
 
 func (r Request) AutoAssignReviewers() error {
@@ -407,40 +717,65 @@ func (r Request) AutoAssignReviewers() error {
 	}
 }
 
-func (r Request) ReviewRoulette(num int) (*RouletteResult, error) {
+// excluding returns the request whose roulette skips usernames in addition to exclude_usernames
+func (r Request) excluding(usernames ...string) Request {
+	config := *r.config
+	config.AssignReviewers.ExcludeUsernames = append(slices.Clone(config.AssignReviewers.ExcludeUsernames), usernames...)
+	r.config = &config
+	return r
+}
+
+func (r Request) ReviewRoulette(num int, exclude ...string) (*RouletteResult, error) {
 	if num == 0 {
 		num = r.config.AssignReviewers.ReviewerNumber
 	}
 
-	return r.reviewRoulette(num)
+	return r.excluding(exclude...).reviewRoulette(num)
 }
 
-func (r Request) UpdateReviewRouletteCounts() error {
-	metrics.BackgroundRunInc("update_review_roulette_counts")
-
-	gamblers, err := r.provider.GetContributors(r.info.ProjectID, r.info.ID)
+// AddReviewers assigns num more roulette winners in addition to current reviewers
+func (r Request) AddReviewers(num int, exclude ...string) (*RouletteResult, error) {
+	result, err := r.excluding(append(slices.Clone(r.info.Reviewers), exclude...)...).spinExactly(max(num, 1))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	counts, err := cache.GetCounts(r.info.ProjectID)
-	if err != nil {
-		return err
+	if len(result.Winners) == 0 {
+		return result, nil
 	}
 
-	if len(counts) > 0 {
-		return nil
+	if err := r.provider.AssignReviewers(r.info.ProjectID, r.info.ID, append(slices.Clone(r.info.Reviewers), result.Winners...)); err != nil {
+		return nil, err
 	}
 
-	counts = make(map[string]int, len(gamblers))
+	r.recordAssignments(result.Winners, AssignedByRoulette, time.Now())
 
-	for _, c := range gamblers {
-		counts[c.Username] = 0
+	return result, nil
+}
+
+// RerollReviewer replaces the reviewer with a new roulette winner, the assignment of the reviewer doesn't count anymore
+func (r Request) RerollReviewer(username string, exclude ...string) (*RouletteResult, error) {
+	if !slices.Contains(r.info.Reviewers, username) {
+		return nil, NotReviewerError
 	}
 
-	if err := cache.SetCounts(r.info.ProjectID, counts); err != nil {
-		return err
+	result, err := r.excluding(append(slices.Clone(r.info.Reviewers), exclude...)...).spinExactly(1)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if len(result.Winners) == 0 {
+		return result, nil
+	}
+
+	reviewers := slices.DeleteFunc(slices.Clone(r.info.Reviewers), func(u string) bool { return u == username })
+	if err := r.provider.AssignReviewers(r.info.ProjectID, r.info.ID, append(reviewers, result.Winners...)); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r.recordAssignments([]string{username}, AssignmentCancelled, now)
+	r.recordAssignments(result.Winners, AssignedByRoulette, now)
+
+	return result, nil
 }
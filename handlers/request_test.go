@@ -1,8 +1,20 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/calendar"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,6 +35,48 @@ type testProvider struct {
 	commentCalled   bool
 	lastComment     string
 	leaveCommentErr error
+	pipelineID      int64
+	pipelineStatus  string
+	jobs            []Job
+	traces          map[int64]string
+	lastMarker      string
+	files           map[string]string
+	targetBranch    string
+	sourceBranch    string
+	changedFiles    []string
+	candidates      []Candidate
+	members         []Candidate
+	openReviews     map[string]int
+	groups          map[string][]string
+	history         map[string][]Contribution
+	reviews         []Review
+	activity        map[int64]*ReviewActivity
+	assigned        []string
+	assignErr       error
+	branches        []StaleBranch
+	mergeRequests   []MR
+	deleted         []string
+	labeled         []int64
+	issues          map[string]string
+	tags            map[string]string
+	created         []string
+	committers      map[string]Committer
+	commitComments  map[string]string
+	unlabeled       []int64
+	closed          []int64
+	drafted         []int64
+	notes           []Comment
+	diffs           map[string]BranchDiff
+	// mu guards fields written by concurrent auto-updates
+	mu           sync.Mutex
+	updated      []int64
+	updateErrors map[int64]error
+	changes      map[int64][]string
+	conflicts    map[int64]bool
+	// conflictingFiles are results of trial merges
+	conflictingFiles map[int64][]string
+	// comments are the last comments left by LeaveOrUpdateComment
+	comments map[int64]string
 }
 
 func newTestProvider() RequestProvider {
@@ -38,18 +92,90 @@ func (p *testProvider) LeaveComment(projectID, id int64, message string) error {
 	return p.err
 }
 
+func (p *testProvider) LeaveOrUpdateComment(projectID, id int64, marker, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastMarker = marker
+	if p.comments == nil {
+		p.comments = map[int64]string{}
+	}
+	p.comments[id] = message
+	return p.LeaveComment(projectID, id, message)
+}
+
+func (p *testProvider) LastComment(projectID, id int64, marker string) (*Comment, error) {
+	for _, note := range slices.Backward(p.notes) {
+		if strings.Contains(note.Body, marker) {
+			return &note, p.err
+		}
+	}
+	return nil, p.err
+}
+
 func (p *testProvider) Merge(projectID, id int64, message string) error {
 	return p.err
 }
 
 func (p *testProvider) ListBranches(projectID, size int64, protected bool) iter.Seq[StaleBranch] {
-	return nil
+	return slices.Values(p.branches)
 }
 
 func (p *testProvider) DeleteBranch(projectID int64, name string) error {
+	p.deleted = append(p.deleted, name)
 	return nil
 }
 
+func (p *testProvider) CreateBranch(projectID int64, name, ref string) error {
+	if slices.Contains(p.created, name) {
+		return AlreadyExistsError
+	}
+	p.created = append(p.created, name)
+	return p.err
+}
+
+func (p *testProvider) CreateTag(projectID int64, name, ref string) error {
+	if p.tags == nil {
+		p.tags = map[string]string{}
+	}
+	if _, ok := p.tags[name]; ok {
+		return AlreadyExistsError
+	}
+	p.tags[name] = ref
+	return p.err
+}
+
+func (p *testProvider) ListTags(projectID int64, prefix string) ([]string, error) {
+	tags := []string{}
+	for name := range p.tags {
+		if strings.HasPrefix(name, prefix) {
+			tags = append(tags, name)
+		}
+	}
+	return tags, p.err
+}
+
+func (p *testProvider) LastCommitter(projectID int64, branch string) (*Committer, error) {
+	c, ok := p.committers[branch]
+	if !ok {
+		return nil, NotFoundError
+	}
+	return &c, nil
+}
+
+func (p *testProvider) CommentCommit(projectID int64, sha, message string) error {
+	if p.commitComments == nil {
+		p.commitComments = map[string]string{}
+	}
+	p.commitComments[sha] = message
+	return p.err
+}
+
+func (p *testProvider) CompareBranch(projectID int64, branch string) (*BranchDiff, error) {
+	diff := p.diffs[branch]
+	return &diff, p.err
+}
+
 func (p *testProvider) GetVar(projectID int64, varName string) (string, error) {
 	return "test", nil
 }
@@ -59,9 +185,13 @@ func (p *testProvider) GetMRInfo(projectID, id int64, path string) (*MrInfo, err
 		ProjectID:       projectID,
 		ID:              id,
 		Title:           p.title,
+		TargetBranch:    p.targetBranch,
+		SourceBranch:    p.sourceBranch,
 		ConfigContent:   p.config,
 		Approvals:       p.approvals,
 		FailedPipelines: p.failedPipelines,
+		PipelineID:      p.pipelineID,
+		PipelineStatus:  p.pipelineStatus,
 		IsValid:         p.IsValid(),
 	}, p.err
 }
@@ -77,18 +207,64 @@ func (p *testProvider) IsValid() bool {
 }
 
 func (p *testProvider) UpdateFromMaster(projectID, mergeID int64) error {
-	return nil
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.updated = append(p.updated, mergeID)
+	return p.updateErrors[mergeID]
 }
 
 func (p *testProvider) ListMergeRequests(projectID, size int64, protected bool) iter.Seq[MR] {
-	return nil
+	return slices.Values(p.mergeRequests)
 }
 
-func (p *testProvider) FindMergeRequests(projectID int64, targetBranch, label string) ([]MR, error) {
-	return nil, p.err
+func (p *testProvider) FindMergeRequests(projectID int64, targetBranch, label string) iter.Seq[MR] {
+	return func(yield func(MR) bool) {
+		for _, mr := range p.mergeRequests {
+			if (label == "" || slices.Contains(mr.Labels, label)) && !yield(mr) {
+				return
+			}
+		}
+	}
+}
+
+func (p *testProvider) FindMergeRequestsWithoutPipeline(projectID int64, targetBranch, label string) iter.Seq[MR] {
+	return p.FindMergeRequests(projectID, targetBranch, label)
 }
 
 func (p *testProvider) AssignLabel(projectID, mergeID int64, name, color string) error {
+	p.labeled = append(p.labeled, mergeID)
+	return p.err
+}
+
+func (p *testProvider) RemoveLabel(projectID, mergeID int64, name string) error {
+	p.unlabeled = append(p.unlabeled, mergeID)
+	return p.err
+}
+
+func (p *testProvider) HasConflicts(projectID, mergeID int64) (bool, error) {
+	return p.conflicts[mergeID], p.err
+}
+
+func (p *testProvider) ConflictingFiles(projectID, mergeID int64) ([]string, error) {
+	return p.conflictingFiles[mergeID], p.err
+}
+
+func (p *testProvider) CloseMergeRequest(projectID, mergeID int64) error {
+	p.closed = append(p.closed, mergeID)
+	return p.err
+}
+
+func (p *testProvider) SetDraft(projectID, mergeID int64) error {
+	p.drafted = append(p.drafted, mergeID)
+	return p.err
+}
+
+func (p *testProvider) UpsertIssue(projectID int64, title, description string) error {
+	if p.issues == nil {
+		p.issues = map[string]string{}
+	}
+	p.issues[title] = description
 	return p.err
 }
 
@@ -121,23 +297,97 @@ func (p *testProvider) AwardEmoji(projectID, mergeID, noteID int64, emoji string
 }
 
 func (p *testProvider) GetFile(projectID int64, path string) ([]byte, error) {
+	if content, ok := p.files[ConfigRef{Project: strconv.FormatInt(projectID, 10), Path: path}.String()]; ok {
+		return []byte(content), p.err
+	}
 	return nil, p.err
 }
 
+func (p *testProvider) GetProjectFile(project, path, ref string) ([]byte, error) {
+	content, ok := p.files[ConfigRef{Project: project, Path: path, Ref: ref}.String()]
+	if !ok {
+		return nil, NotFoundError
+	}
+	return []byte(content), p.err
+}
+
 func (p *testProvider) GetChangedFiles(projectID, mergeID int64) ([]string, error) {
-	return nil, p.err
+	if files, ok := p.changes[mergeID]; ok {
+		return files, p.err
+	}
+	return p.changedFiles, p.err
 }
 
 func (p *testProvider) AssignReviewers(projectID, mergeID int64, users []string) error {
+	if p.assignErr != nil {
+		return p.assignErr
+	}
+	p.assigned = users
 	return p.err
 }
 
-func (p testProvider) IsHealthy() bool {
+func (p *testProvider) ListOpenReviews(projectID int64) iter.Seq[Review] {
+	return slices.Values(p.reviews)
+}
+
+func (p *testProvider) GetReviewActivity(projectID, mergeID int64) (*ReviewActivity, error) {
+	activity, ok := p.activity[mergeID]
+	if !ok {
+		return &ReviewActivity{}, p.err
+	}
+
+	return activity, p.err
+}
+
+func (p *testProvider) ListFailedJobs(projectID, pipelineID int64) ([]Job, error) {
+	return p.jobs, p.err
+}
+
+func (p *testProvider) GetJobTrace(projectID, jobID int64) ([]byte, error) {
+	return []byte(p.traces[jobID]), p.err
+}
+
+func (p *testProvider) IsHealthy() bool {
 	return true
 }
 
-func (p testProvider) GetContributors(projectID, mergeID int64) ([]Candidate, error) {
-	return nil, p.err
+func (p *testProvider) GetContributors(projectID, mergeID int64, settings Candidates) ([]Candidate, error) {
+	return slices.Clone(p.candidates), p.err
+}
+
+func (p *testProvider) GetMembers(projectID, mergeID int64, usernames []string, settings Candidates) ([]Candidate, error) {
+	members := []Candidate{}
+	for _, m := range p.members {
+		if slices.Contains(usernames, m.Username) {
+			members = append(members, m)
+		}
+	}
+
+	return members, p.err
+}
+
+func (p *testProvider) ListPathContributions(projectID int64, path string, since time.Time, limit int, budget *CallBudget) ([]Contribution, error) {
+	if !budget.Take() {
+		return nil, BudgetError
+	}
+	return p.history[path], p.err
+}
+
+func (p *testProvider) GetGroupMembers(group string) ([]string, error) {
+	members, ok := p.groups[group]
+	if !ok {
+		return nil, NotFoundError
+	}
+
+	return members, nil
+}
+
+func (p *testProvider) CountOpenReviews(usernames []string) (map[string]int, error) {
+	if p.openReviews == nil {
+		return nil, errors.New("open reviews are unknown")
+	}
+
+	return p.openReviews, nil
 }
 
 func Test_Merge(t *testing.T) {
@@ -255,7 +505,7 @@ func TestRequest_Greetings(t *testing.T) {
 			expectedComment:   "Hello! You need 1 approvals.",
 		},
 		{
-			name: "invalid template - should return error",
+			name: "invalid template - default config is used",
 			fields: fields{
 				provider: &testProvider{
 					title: "Test MR",
@@ -266,8 +516,8 @@ func TestRequest_Greetings(t *testing.T) {
 				},
 			},
 			args:              args{projectID: 1, id: 1},
-			wantErr:           true,
-			wantCommentCalled: false,
+			wantErr:           false,
+			wantCommentCalled: true,
 		},
 		{
 			name: "provider error on GetMRInfo - should return error",
@@ -332,3 +582,237 @@ func TestRequest_Greetings(t *testing.T) {
 		})
 	}
 }
+
+func TestRequest_spinRoulette(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	candidates := []Candidate{
+		{Username: "author"},
+		{Username: "alice"},
+		{Username: "bob"},
+		{Username: "carol"},
+		{Username: "dave", Status: "On vacation"},
+		{Username: "merge-bot"},
+	}
+
+	tests := []struct {
+		name            string
+		maxOpenReviews  int
+		openReviews     map[string]int
+		num             int
+		wantWinners     []string
+		wantOverloaded  int
+		outOfOffice     []string
+		wantUnavailable int
+		wantText        string
+	}{
+		{
+			name:            "least loaded reviewers win",
+			openReviews:     map[string]int{"alice": 5, "bob": 1, "carol": 0},
+			num:             2,
+			wantWinners:     []string{"carol", "bob"},
+			wantUnavailable: 1,
+			wantText:        "@carol (0 open reviews), @bob (1 open review)",
+		},
+		{
+			name:            "overloaded reviewers are skipped",
+			maxOpenReviews:  3,
+			openReviews:     map[string]int{"alice": 5, "bob": 3, "carol": 2},
+			num:             2,
+			wantWinners:     []string{"carol"},
+			wantOverloaded:  2,
+			wantUnavailable: 1,
+			wantText:        "2 players - overloaded",
+		},
+		{
+			name:            "unknown workload",
+			maxOpenReviews:  3,
+			num:             3,
+			wantWinners:     []string{"alice", "bob", "carol"},
+			wantUnavailable: 1,
+			wantText:        "@alice",
+		},
+		{
+			name:            "people on vacation according to calendars are unavailable",
+			openReviews:     map[string]int{"alice": 0, "bob": 1, "carol": 2},
+			num:             2,
+			outOfOffice:     []string{"alice"},
+			wantWinners:     []string{"bob", "carol"},
+			wantUnavailable: 2,
+			wantText:        "2 players - unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, u := range tt.outOfOffice {
+				vacation := fmt.Sprintf(
+					"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:%s\r\nDTEND;VALUE=DATE:%s\r\nSUMMARY:Vacation\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+					time.Now().AddDate(0, 0, -1).Format("20060102"), time.Now().AddDate(0, 0, 2).Format("20060102"),
+				)
+				if err := os.WriteFile(filepath.Join(dir, u+".ics"), []byte(vacation), 0o600); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+			}
+
+			calendar.SetSources(calendar.DirSource{Dir: dir})
+			defer calendar.SetSources()
+
+			r := Request{
+				provider: &testProvider{candidates: candidates, openReviews: tt.openReviews},
+				info:     &MrInfo{ProjectID: 1, ID: 2, Author: "author"},
+				config:   defaultConfig(),
+			}
+			r.config.AssignReviewers.MaxOpenReviews = tt.maxOpenReviews
+
+			result, err := r.spinRoulette(tt.num)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.wantWinners, result.Winners)
+			if tt.openReviews != nil {
+				assert.Equal(t, tt.wantWinners, result.Winners)
+			}
+			assert.Equal(t, tt.wantOverloaded, result.OverloadedPlayers)
+			assert.Equal(t, tt.wantUnavailable, result.UnavailablePlayers)
+			assert.Contains(t, result.String(), tt.wantText)
+		})
+	}
+}
+
+func TestRequest_RerollReviewer(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	candidates := []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}, {Username: "dave"}}
+
+	tests := []struct {
+		name         string
+		user         string
+		exclude      []string
+		wantErr      error
+		wantWinners  []string
+		wantAssigned []string
+	}{
+		{
+			name:    "user is not a reviewer",
+			user:    "carol",
+			wantErr: NotReviewerError,
+		},
+		{
+			name:         "reviewer is replaced",
+			user:         "alice",
+			exclude:      []string{"carol"},
+			wantWinners:  []string{"dave"},
+			wantAssigned: []string{"bob", "dave"},
+		},
+		{
+			name:    "no available players",
+			user:    "alice",
+			exclude: []string{"carol", "dave"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := int64(3800 + i)
+			provider := &testProvider{candidates: candidates, openReviews: map[string]int{}}
+			r := Request{
+				provider: provider,
+				info:     &MrInfo{ProjectID: projectID, ID: 1, Author: "author", Reviewers: []string{"alice", "bob"}},
+				config:   defaultConfig(),
+			}
+			r.recordAssignments([]string{"alice", "bob"}, AssignedByRoulette, time.Now())
+
+			result, err := r.RerollReviewer(tt.user, tt.exclude...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.wantWinners, result.Winners)
+			assert.Equal(t, tt.wantAssigned, provider.assigned)
+
+			counts, err := r.assignmentCounts(time.Now())
+			assert.NoError(t, err)
+			if len(tt.wantWinners) > 0 {
+				assert.Equal(t, map[string]int{"bob": 1, "dave": 1}, counts)
+			} else {
+				assert.Equal(t, map[string]int{"alice": 1, "bob": 1}, counts)
+			}
+		})
+	}
+}
+
+func TestRequest_AddReviewers(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	provider := &testProvider{
+		candidates:  []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}, {Username: "dave"}},
+		openReviews: map[string]int{"carol": 0, "dave": 3},
+	}
+	r := Request{
+		provider: provider,
+		info:     &MrInfo{ProjectID: 3810, ID: 1, Author: "author", Reviewers: []string{"alice"}},
+		config:   defaultConfig(),
+	}
+
+	result, err := r.AddReviewers(1, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, result.Winners)
+	assert.Equal(t, []string{"alice", "carol"}, provider.assigned)
+}
+
+func TestRequest_LoadProjectConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		err         error
+		wantErr     bool
+		wantEnabled bool
+		wantIssues  int
+	}{
+		{
+			name:        "config from the default branch",
+			files:       map[string]string{"7:" + configPath: "review_reminders:\n  enabled: true\n"},
+			wantEnabled: true,
+		},
+		{
+			name: "no config",
+		},
+		{
+			// the config of the previous case is the last valid one
+			name:       "config with problems",
+			files:      map[string]string{"7:" + configPath: "review_reminders:\n  enabled: true\n  review_sla_hours: 0\n"},
+			wantIssues: 1,
+		},
+		{
+			name:    "file can't be fetched",
+			err:     errors.New("boom"),
+			wantErr: true,
+		},
+		{
+			name: "config is missing",
+			err:  NotFoundError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{provider: &testProvider{files: tt.files, err: tt.err}}
+
+			err := r.LoadProjectConfig(7)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), r.info.ProjectID)
+			assert.Equal(t, tt.wantEnabled, r.config.ReviewReminders.Enabled)
+			assert.Len(t, r.configIssues, tt.wantIssues)
+		})
+	}
+}
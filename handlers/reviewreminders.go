@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+type ReviewReminders struct {
+	Enabled bool `yaml:"enabled"`
+	// SLAHours and EscalationHours count only working hours of the reviewer
+	SLAHours        int `yaml:"review_sla_hours"`
+	EscalationHours int `yaml:"escalation_hours"`
+	// Fallback are people mentioned when a reviewer doesn't respond to the reminder
+	Fallback []string `yaml:"fallback"`
+	// Respin replaces reviewers who don't respond to the reminder with new roulette winners
+	Respin bool `yaml:"respin"`
+}
+
+// Review is an open non-draft MR with reviewers
+type Review struct {
+	ID        int64
+	Author    string
+	Reviewers []string
+	// Timezones are timezones of reviewers from their profiles, reviewers without one are missing
+	Timezones map[string]string
+	CreatedAt time.Time
+}
+
+type ReviewActivity struct {
+	Approved map[string]struct{}
+	// Commented is the time of the last comment of every user
+	Commented map[string]time.Time
+}
+
+// reminderState tracks reminders of a reviewer since the assignment
+type reminderState struct {
+	NudgedAt    time.Time `json:"nudged_at"`
+	EscalatedAt time.Time `json:"escalated_at"`
+}
+
+func sameReminders(a, b map[string]reminderState) bool {
+	return maps.EqualFunc(a, b, func(x, y reminderState) bool {
+		return x.NudgedAt.Equal(y.NudgedAt) && x.EscalatedAt.Equal(y.EscalatedAt)
+	})
+}
+
+func mentions(usernames []string) string {
+	names := make([]string, 0, len(usernames))
+	for _, u := range usernames {
+		names = append(names, "@"+u)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// assignedAt returns the time of the latest assignment of the reviewer, MRs without logged assignments are counted from creation
+func assignedAt(assignments []cache.Assignment, review Review, reviewer string) time.Time {
+	at := review.CreatedAt
+	for _, a := range assignments {
+		if a.MergeID == review.ID && a.Reviewer == reviewer && a.At.After(at) {
+			at = a.At
+		}
+	}
+
+	return at
+}
+
+// RemindReviewers nudges reviewers who haven't responded within review_sla_hours and escalates after escalation_hours
+func (r *Request) RemindReviewers() error {
+	if !r.config.ReviewReminders.Enabled {
+		return nil
+	}
+
+	metrics.BackgroundRunInc("review_reminders")
+
+	assignments, err := r.assignments(time.Time{})
+	if err != nil {
+		logger.Info("assignments are unknown, reviews are counted from MR creation", "err", err)
+	}
+
+	now := time.Now()
+	for review := range r.provider.ListOpenReviews(r.info.ProjectID) {
+		if err := r.remindReview(review, assignments, now); err != nil {
+			logger.Info("review reminder failed", "projectId", r.info.ProjectID, "mergeId", review.ID, "err", err)
+		}
+	}
+
+	return nil
+}
+
+func (r Request) remindReview(review Review, assignments []cache.Assignment, now time.Time) error {
+	settings := r.config.ReviewReminders
+	hours := r.config.AssignReviewers.WorkingHours
+	sla := time.Duration(settings.SLAHours) * time.Hour
+	escalation := time.Duration(settings.EscalationHours) * time.Hour
+
+	activity, err := r.provider.GetReviewActivity(r.info.ProjectID, review.ID)
+	if err != nil {
+		return fmt.Errorf("GetReviewActivity returns error: %w", err)
+	}
+
+	states := map[string]reminderState{}
+	if _, err := cache.GetReminders(r.info.ProjectID, review.ID, &states); err != nil {
+		logger.Info("reminders cache is unavailable", "mergeId", review.ID, "err", err)
+	}
+
+	nudged, escalated, away := []string{}, []string{}, []string{}
+	next := map[string]reminderState{}
+	loaded := holidayCalendars{}
+
+	for _, u := range review.Reviewers {
+		if _, ok := activity.Approved[u]; ok {
+			continue
+		}
+
+		since := assignedAt(assignments, review, u)
+		if last, ok := activity.Commented[u]; ok && last.After(since) {
+			continue
+		}
+
+		state := states[u]
+		if state.NudgedAt.Before(since) {
+			// the reviewer has been assigned again
+			state = reminderState{}
+		}
+
+		reviewer := Candidate{Username: u, Timezone: review.Timezones[u]}
+		s := r.scheduleOf(reviewer).withHolidays(loaded)
+
+		switch {
+		case state.NudgedAt.IsZero():
+			if hours.workingDuration(since, now, s, sla) < sla {
+				break
+			}
+
+			state.NudgedAt = now
+			// people on vacation can't respond to the reminder
+			if _, ok := r.outOfOffice(reviewer, now); ok {
+				state.EscalatedAt = now
+				away = append(away, u)
+			} else {
+				nudged = append(nudged, u)
+			}
+		case state.EscalatedAt.IsZero():
+			if hours.workingDuration(state.NudgedAt, now, s, escalation) >= escalation {
+				state.EscalatedAt = now
+				escalated = append(escalated, u)
+			}
+		}
+
+		next[u] = state
+	}
+
+	if len(nudged) == 0 && len(escalated) == 0 && len(away) == 0 {
+		if sameReminders(states, next) {
+			return nil
+		}
+
+		return cache.SetReminders(r.info.ProjectID, review.ID, next)
+	}
+
+	messages := []string{}
+	if len(nudged) > 0 {
+		messages = append(messages, fmt.Sprintf(
+			"👋 %s, friendly reminder: this MR has been waiting for your review for %s. If you can't review it, please let the author know.",
+			mentions(nudged), english.Plural(settings.SLAHours, "working hour", ""),
+		))
+	}
+
+	if unresponsive := append(slices.Clone(escalated), away...); len(unresponsive) > 0 {
+		reasons := []string{}
+		if len(escalated) > 0 {
+			reasons = append(reasons, fmt.Sprintf("⏰ %s didn't respond within %s after the reminder.", mentions(escalated), english.Plural(settings.EscalationHours, "working hour", "")))
+		}
+
+		if len(away) > 0 {
+			reasons = append(reasons, fmt.Sprintf("🌴 %s %s out of office.", mentions(away), english.PluralWord(len(away), "is", "are")))
+		}
+
+		message := strings.Join(reasons, " ")
+
+		if fallback := slices.DeleteFunc(slices.Clone(settings.Fallback), func(u string) bool { return u == review.Author }); len(fallback) > 0 {
+			message += fmt.Sprintf(" %s, could you help with the review?", mentions(fallback))
+		}
+
+		if settings.Respin {
+			winners, err := r.replaceReviewers(review, unresponsive, now)
+			if err != nil {
+				logger.Info("unresponsive reviewers can't be replaced", "mergeId", review.ID, "err", err)
+			} else if len(winners) > 0 {
+				message += fmt.Sprintf("\n\n🎲 New reviewers: %s", mentions(winners))
+			}
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := r.provider.LeaveComment(r.info.ProjectID, review.ID, strings.Join(messages, "\n\n")); err != nil {
+		return fmt.Errorf("LeaveComment returns error: %w", err)
+	}
+
+	return cache.SetReminders(r.info.ProjectID, review.ID, next)
+}
+
+// replaceReviewers spins the roulette for MR of the review and replaces unresponsive reviewers with its winners,
+// assignments of the replaced reviewers don't count anymore
+func (r Request) replaceReviewers(review Review, unresponsive []string, now time.Time) ([]string, error) {
+	sub := Request{
+		provider: r.provider,
+		info:     &MrInfo{ProjectID: r.info.ProjectID, ID: review.ID, Author: review.Author, Reviewers: review.Reviewers},
+		config:   r.config,
+	}
+	sub = sub.excluding(review.Reviewers...)
+
+	result, err := sub.spinExactly(len(unresponsive))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Winners) == 0 {
+		return nil, nil
+	}
+
+	// reviewers stay if there are not enough winners to replace them
+	replaced := unresponsive[:min(len(unresponsive), len(result.Winners))]
+	reviewers := slices.DeleteFunc(slices.Clone(review.Reviewers), func(u string) bool {
+		return slices.Contains(replaced, u)
+	})
+
+	if err := r.provider.AssignReviewers(r.info.ProjectID, review.ID, append(reviewers, result.Winners...)); err != nil {
+		return nil, err
+	}
+
+	sub.recordAssignments(replaced, AssignmentCancelled, now)
+	sub.recordAssignments(result.Winners, AssignedByRoulette, now)
+
+	return result.Winners, nil
+}
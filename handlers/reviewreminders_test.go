@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/calendar"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkingHours_workingDuration(t *testing.T) {
+	hours := defaultConfig().AssignReviewers.WorkingHours
+	utc := schedule{location: time.UTC}
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want time.Duration
+	}{
+		{
+			name: "one working day",
+			from: time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC),
+			want: 9 * time.Hour,
+		},
+		{
+			name: "weekend is skipped",
+			from: time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+			want: 2 * time.Hour,
+		},
+		{
+			name: "working time of several days",
+			from: time.Date(2026, 10, 19, 17, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 10, 21, 10, 30, 0, 0, time.UTC),
+			want: 11*time.Hour + 30*time.Minute,
+		},
+		{
+			name: "counting stops at limit",
+			from: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 10, 23, 18, 0, 0, 0, time.UTC),
+			want: 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hours.workingDuration(tt.from, tt.to, utc, 24*time.Hour))
+		})
+	}
+}
+
+func TestWorkingHours_workingDuration_NightShift(t *testing.T) {
+	w := WorkingHours{Start: "22:00", End: "06:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+	s := schedule{location: time.UTC}
+
+	// Monday 20:00 - Tuesday 08:00
+	from := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, 8*time.Hour, w.workingDuration(from, from.Add(12*time.Hour), s, 24*time.Hour))
+}
+
+func Test_sameReminders(t *testing.T) {
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	assert.True(t, sameReminders(nil, map[string]reminderState{}))
+	assert.True(t, sameReminders(map[string]reminderState{"alice": {NudgedAt: at}}, map[string]reminderState{"alice": {NudgedAt: at.In(time.Local)}}))
+	assert.False(t, sameReminders(map[string]reminderState{"alice": {NudgedAt: at}}, map[string]reminderState{"alice": {NudgedAt: at, EscalatedAt: at}}))
+	assert.False(t, sameReminders(map[string]reminderState{"alice": {NudgedAt: at}}, map[string]reminderState{}))
+}
+
+func TestRequest_remindReview_Timezone(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	// Monday, 9 working hours in UTC, the night in Tokyo
+	created := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		timezones map[string]string
+		want      bool
+	}{
+		{name: "reviewer in UTC is nudged", want: true},
+		{name: "reviewer in Tokyo is not nudged", timezones: map[string]string{"alice": "UTC+09:00"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{activity: map[int64]*ReviewActivity{}}
+			r := Request{provider: provider, info: &MrInfo{ProjectID: int64(3750 + i)}, config: defaultConfig()}
+			r.config.ReviewReminders.Enabled = true
+
+			review := Review{ID: 1, Reviewers: []string{"alice"}, Timezones: tt.timezones, CreatedAt: created}
+			assert.NoError(t, r.remindReview(review, nil, now))
+			assert.Equal(t, tt.want, provider.commentCalled)
+		})
+	}
+}
+
+func TestRequest_remindReview(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	vacation := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261020\r\nDTEND;VALUE=DATE:20261024\r\nSUMMARY:Vacation\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "dave.ics"), []byte(vacation), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	calendar.SetSources(calendar.DirSource{Dir: dir})
+	defer calendar.SetSources()
+
+	// Wednesday
+	now := time.Date(2026, 10, 21, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		reviewers    []string
+		activity     *ReviewActivity
+		state        map[string]reminderState
+		respin       bool
+		wantComment  string
+		wantAssigned []string
+	}{
+		{
+			name:        "reviewer is nudged after SLA",
+			reviewers:   []string{"alice"},
+			wantComment: "👋 @alice, friendly reminder: this MR has been waiting for your review for 8 working hours.",
+		},
+		{
+			name:      "approved reviewer is not nudged",
+			reviewers: []string{"alice"},
+			activity:  &ReviewActivity{Approved: map[string]struct{}{"alice": {}}},
+		},
+		{
+			name:      "reviewer who commented is not nudged",
+			reviewers: []string{"alice"},
+			activity:  &ReviewActivity{Commented: map[string]time.Time{"alice": monday.Add(time.Hour)}},
+		},
+		{
+			name:        "reviewer is escalated to fallback after the reminder",
+			reviewers:   []string{"alice"},
+			state:       map[string]reminderState{"alice": {NudgedAt: time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)}},
+			wantComment: "⏰ @alice didn't respond within 8 working hours after the reminder. @lead, could you help with the review?",
+		},
+		{
+			name:        "reviewer on vacation is escalated instead of the reminder",
+			reviewers:   []string{"dave"},
+			wantComment: "🌴 @dave is out of office. @lead, could you help with the review?",
+		},
+		{
+			name:         "reviewer on vacation is replaced",
+			reviewers:    []string{"alice", "bob", "dave"},
+			activity:     &ReviewActivity{Approved: map[string]struct{}{"alice": {}, "bob": {}}},
+			respin:       true,
+			wantComment:  "🎲 New reviewers: @carol",
+			wantAssigned: []string{"alice", "bob", "carol"},
+		},
+		{
+			name:      "reminder is not repeated",
+			reviewers: []string{"alice"},
+			state:     map[string]reminderState{"alice": {NudgedAt: time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name:         "unresponsive reviewer is replaced",
+			reviewers:    []string{"alice", "bob"},
+			activity:     &ReviewActivity{Approved: map[string]struct{}{"bob": {}}},
+			state:        map[string]reminderState{"alice": {NudgedAt: time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)}},
+			respin:       true,
+			wantComment:  "🎲 New reviewers: @carol",
+			wantAssigned: []string{"bob", "carol"},
+		},
+		{
+			name:         "reviewers stay without enough winners",
+			reviewers:    []string{"alice", "bob", "dave"},
+			state:        map[string]reminderState{"alice": {NudgedAt: time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)}},
+			respin:       true,
+			wantComment:  "🎲 New reviewers: @carol",
+			wantAssigned: []string{"bob", "dave", "carol"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := int64(3700 + i)
+			review := Review{ID: 1, Author: "author", Reviewers: tt.reviewers, CreatedAt: monday}
+
+			if tt.state != nil {
+				assert.NoError(t, cache.SetReminders(projectID, review.ID, tt.state))
+			}
+
+			provider := &testProvider{
+				candidates: []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}},
+				activity:   map[int64]*ReviewActivity{},
+			}
+			if tt.activity != nil {
+				provider.activity[review.ID] = tt.activity
+			}
+
+			r := Request{provider: provider, info: &MrInfo{ProjectID: projectID, ID: review.ID}, config: defaultConfig()}
+			r.recordAssignments(tt.reviewers, AssignedByRoulette, monday)
+			r.config.ReviewReminders.Enabled = true
+			r.config.ReviewReminders.Fallback = []string{"lead"}
+			r.config.ReviewReminders.Respin = tt.respin
+
+			assert.NoError(t, r.remindReview(review, nil, now))
+			assert.Equal(t, tt.wantComment != "", provider.commentCalled)
+			assert.Contains(t, provider.lastComment, tt.wantComment)
+			assert.Equal(t, tt.wantAssigned, provider.assigned)
+
+			// replaced reviewers don't count anymore
+			if tt.wantAssigned != nil {
+				assignments, err := r.assignments(monday)
+				assert.NoError(t, err)
+
+				reviewers := []string{}
+				for _, a := range assignments {
+					reviewers = append(reviewers, a.Reviewer)
+				}
+				assert.ElementsMatch(t, tt.wantAssigned, reviewers)
+			}
+
+			// the next scan doesn't repeat the reminder
+			provider.commentCalled = false
+			assert.NoError(t, r.remindReview(review, nil, now))
+			assert.False(t, provider.commentCalled)
+		})
+	}
+}
+
+func TestRequest_RemindReviewers(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	provider := &testProvider{
+		reviews: []Review{{ID: 1, Reviewers: []string{"alice"}, CreatedAt: time.Now().AddDate(0, 0, -14)}},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 3799}, config: defaultConfig()}
+	assert.NoError(t, r.RemindReviewers())
+	assert.False(t, provider.commentCalled)
+
+	r.config.ReviewReminders.Enabled = true
+	assert.NoError(t, r.RemindReviewers())
+	assert.Contains(t, provider.lastComment, "@alice")
+}
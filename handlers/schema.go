@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const (
+	schemaDraft = "http://json-schema.org/draft-07/schema#"
+	schemaTitle = "merge-bot config (.mrbot.yaml)"
+)
+
+func jsonSchema(t reflect.Type, defaults reflect.Value) map[string]any {
+	// yaml.Node is used for partial overrides of rules, there are no defaults
+	if t == yamlNodeType {
+		t = reflect.TypeFor[Rules]()
+		defaults = reflect.Value{}
+	}
+
+	schema := map[string]any{}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for f := range t.Fields() {
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+
+			fieldDefaults := reflect.Value{}
+			if defaults.IsValid() {
+				fieldDefaults = defaults.FieldByIndex(f.Index)
+			}
+
+			properties[name] = jsonSchema(f.Type, fieldDefaults)
+		}
+
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		return schema
+
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = jsonSchema(t.Elem(), reflect.Value{})
+
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = jsonSchema(t.Elem(), reflect.Value{})
+
+	case reflect.String:
+		schema["type"] = "string"
+
+	case reflect.Bool:
+		schema["type"] = "boolean"
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	}
+
+	if defaults.IsValid() && !(defaults.Kind() == reflect.Map && defaults.IsNil()) && !(defaults.Kind() == reflect.Slice && defaults.IsNil()) {
+		schema["default"] = defaults.Interface()
+	}
+
+	return schema
+}
+
+// ConfigSchema returns JSON Schema of .mrbot.yaml generated from Config
+func ConfigSchema() ([]byte, error) {
+	schema := jsonSchema(reflect.TypeFor[Config](), reflect.ValueOf(defaultConfig()).Elem())
+	schema["$schema"] = schemaDraft
+	schema["title"] = schemaTitle
+
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	// maxSnooze is the longest period MR can be exempt from the stale cleanup
+	maxSnooze = 365 * 24 * time.Hour
+	// snoozeMarker starts the hidden end of the snooze in the comment of the bot,
+	// the comment keeps the snooze if the cache is lost
+	snoozeMarker = "<!-- merge-bot:snooze-until"
+)
+
+// parseSnooze accepts days and weeks like 30d and 2w in addition to Go durations
+func parseSnooze(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+
+	switch {
+	case strings.HasSuffix(s, "d"), strings.HasSuffix(s, "w"):
+		unit := 24 * time.Hour
+		if strings.HasSuffix(s, "w") {
+			unit *= 7
+		}
+
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * unit
+	default:
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d <= 0 || d > maxSnooze {
+		return 0, fmt.Errorf("%w: %q must be like 30d, 2w or 12h up to a year", PeriodError, s)
+	}
+
+	return d, nil
+}
+
+func snoozeComment(until time.Time) string {
+	return fmt.Sprintf("%s %s -->\n💤 This MR is exempt from the stale cleanup until %s", snoozeMarker, until.UTC().Format(time.RFC3339), until.Format(time.DateOnly))
+}
+
+// parseSnoozeComment returns the end of the snooze saved in the comment
+func parseSnoozeComment(body string) (time.Time, bool) {
+	_, rest, ok := strings.Cut(body, snoozeMarker+" ")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	value, _, ok := strings.Cut(rest, " -->")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return until, true
+}
+
+// snoozedUntil returns the end of the snooze of MR, the comment of the bot is read if the cache has no snooze
+func (r Request) snoozedUntil(mergeID int64, now time.Time) (time.Time, bool, error) {
+	until, ok, err := cache.SnoozedUntil(r.info.ProjectID, mergeID)
+	if err != nil || ok {
+		return until, ok, err
+	}
+
+	comment, err := r.provider.LastComment(r.info.ProjectID, mergeID, snoozeMarker)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("LastComment returns error: %w", err)
+	}
+
+	if comment == nil {
+		return time.Time{}, false, nil
+	}
+
+	until, ok = parseSnoozeComment(comment.Body)
+	if !ok || !until.After(now) {
+		return time.Time{}, false, nil
+	}
+
+	if err := cache.Snooze(r.info.ProjectID, mergeID, until); err != nil {
+		logger.Error("snooze can't be cached", "mergeID", mergeID, "err", err)
+	}
+
+	return until, true, nil
+}
+
+// Snooze exempts MR from the stale cleanup for the period, leaves the comment with its end and returns it
+func (r Request) Snooze(period string) (time.Time, error) {
+	d, err := parseSnooze(period)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	until := time.Now().Add(d)
+	if err := cache.Snooze(r.info.ProjectID, r.info.ID, until); err != nil {
+		return time.Time{}, fmt.Errorf("can't snooze MR: %w", err)
+	}
+
+	if slices.Contains(r.info.Labels, staleLabel) {
+		if err := r.provider.RemoveLabel(r.info.ProjectID, r.info.ID, staleLabel); err != nil {
+			return time.Time{}, fmt.Errorf("RemoveLabel returns error: %w", err)
+		}
+	}
+
+	if err := cache.UnmarkStale(r.info.ProjectID, r.info.ID); err != nil {
+		return time.Time{}, fmt.Errorf("UnmarkStale returns error: %w", err)
+	}
+
+	if err := r.provider.LeaveComment(r.info.ProjectID, r.info.ID, snoozeComment(until)); err != nil {
+		return time.Time{}, fmt.Errorf("LeaveComment returns error: %w", err)
+	}
+
+	return until, nil
+}
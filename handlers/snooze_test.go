@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseSnooze(t *testing.T) {
+	tests := []struct {
+		period  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{period: "30d", want: 30 * 24 * time.Hour},
+		{period: "2w", want: 14 * 24 * time.Hour},
+		{period: "12h", want: 12 * time.Hour},
+		{period: "0d", wantErr: true},
+		{period: "-1d", wantErr: true},
+		{period: "400d", wantErr: true},
+		{period: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			got, err := parseSnooze(tt.period)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, PeriodError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRequest_Snooze(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	provider := &testProvider{}
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 4600, ID: 7, Labels: []string{staleLabel}}, config: defaultConfig()}
+	assert.NoError(t, cache.MarkStale(4600, 7, time.Now()))
+
+	until, err := r.Snooze("30d")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), until, time.Minute)
+	assert.Equal(t, []int64{7}, provider.unlabeled)
+
+	commentUntil, ok := parseSnoozeComment(provider.lastComment)
+	assert.True(t, ok)
+	assert.WithinDuration(t, until, commentUntil, time.Second)
+
+	snoozedUntil, ok, err := cache.SnoozedUntil(4600, 7)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.WithinDuration(t, until, snoozedUntil, time.Second)
+
+	_, marked, err := cache.StaleMarkedAt(4600, 7)
+	assert.NoError(t, err)
+	assert.False(t, marked)
+
+	_, err = r.Snooze("forever")
+	assert.ErrorIs(t, err, PeriodError)
+}
@@ -4,55 +4,137 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/cache"
 	"github.com/gasoid/merge-bot/v3/logger"
 	"github.com/gasoid/merge-bot/v3/metrics"
 )
 
 type StaleBranch struct {
 	Name        string
+	Commit      string
+	AuthorName  string
+	AuthorEmail string
 	LastUpdated time.Time
 	Protected   bool
 }
 
-func (r Request) cleanStaleBranches() error {
-	logger.Debug("deletion of stale branches has been run")
-	var (
-		branchesDeleted int64 = 0
-		days                  = r.config.StaleBranchesDeletion.Days
-		now                   = time.Now()
-		excludeBranches       = make(map[string]struct{}, len(r.config.StaleBranchesDeletion.ExcludeBranches))
-	)
+// branchNotice is saved when the last committer of a stale branch is notified
+type branchNotice struct {
+	Commit     string    `json:"commit"`
+	NotifiedAt time.Time `json:"notified_at"`
+}
 
-	defer func() {
-		duration := time.Since(now)
-		metrics.BranchDeletionDuration(duration)
-	}()
+// inactiveDays returns the number of full days since the time
+func inactiveDays(t, now time.Time) int {
+	return int(now.Sub(t) / (24 * time.Hour))
+}
 
-	for _, s := range r.config.StaleBranchesDeletion.ExcludeBranches {
-		excludeBranches[s] = struct{}{}
-	}
+// staleBranches selects the next batch of branches to delete
+func (r Request) staleBranches(now time.Time) []staleAction {
+	var (
+		actions  = []staleAction{}
+		days     = r.config.StaleBranchesDeletion.Days
+		waitDays = r.config.StaleBranchesDeletion.WaitDays
+	)
 
 	for b := range r.provider.ListBranches(r.info.ProjectID, r.config.StaleBranchesDeletion.BatchSize, r.config.StaleBranchesDeletion.Protected) {
-		if branchesDeleted >= r.config.StaleBranchesDeletion.BatchSize {
+		if int64(len(actions)) >= r.config.StaleBranchesDeletion.BatchSize {
 			break
 		}
 
-		if _, ok := excludeBranches[b.Name]; ok {
+		span := now.Sub(b.LastUpdated)
+		if span <= time.Duration(time.Duration(days)*24*time.Hour) {
 			continue
 		}
 
-		span := now.Sub(b.LastUpdated)
-		if span > time.Duration(time.Duration(days)*24*time.Hour) {
-			// branch is stale
-			// delete branch
-			logger.Debug("branch info", "name", b.Name, "createdAt", b.LastUpdated.String())
-			if err := r.provider.DeleteBranch(r.info.ProjectID, b.Name); err != nil {
-				return fmt.Errorf("DeleteBranch returns error: %w", err)
+		if reason := r.keepBranch(b.Name, false, now, b.AuthorName, b.AuthorEmail); reason != "" {
+			logger.Debug("stale branch is kept", "name", b.Name, "reason", reason)
+			continue
+		}
+
+		// branch is stale
+		logger.Debug("branch info", "name", b.Name, "createdAt", b.LastUpdated.String())
+		inactive := fmt.Sprintf("no activity for %s (> %s)", english.Plural(inactiveDays(b.LastUpdated, now), "day", ""), english.Plural(days, "day", ""))
+
+		notice := branchNotice{}
+		ok, err := cache.GetBranchNotice(r.info.ProjectID, b.Name, &notice)
+		if err != nil {
+			logger.Error("branch notice can't be loaded", "branch", b.Name, "err", err)
+			continue
+		}
+
+		// the author is notified again if the branch has been updated since the notice
+		if !ok || notice.Commit != b.Commit {
+			actions = append(actions, staleAction{
+				kind:   staleNotifyBranch,
+				branch: b.Name,
+				commit: b.Commit,
+				reason: inactive,
+			})
+			continue
+		}
+
+		if now.Sub(notice.NotifiedAt) < time.Duration(waitDays)*24*time.Hour {
+			continue
+		}
+
+		actions = append(actions, staleAction{
+			kind:   staleDeleteBranch,
+			branch: b.Name,
+			commit: b.Commit,
+			reason: fmt.Sprintf("%s, the author was notified %s ago", inactive, english.Plural(inactiveDays(notice.NotifiedAt, now), "day", "")),
+		})
+	}
+
+	return actions
+}
+
+func (r Request) cleanStaleBranches() error {
+	logger.Debug("deletion of stale branches has been run")
+	now := time.Now()
+
+	defer func() {
+		duration := time.Since(now)
+		metrics.BranchDeletionDuration(duration)
+	}()
+
+	for _, a := range r.staleBranches(now) {
+		switch a.kind {
+		case staleDeleteBranch:
+			if err := r.deleteBranch(a.branch, a.commit, now); err != nil {
+				return err
 			}
-			branchesDeleted++
 			metrics.BranchDeletionInc()
+
+		case staleNotifyBranch:
+			if err := r.notifyBranchAuthor(a, now); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+// notifyBranchAuthor comments the last commit of the stale branch, the branch is deleted after wait_days unless it's updated
+func (r Request) notifyBranchAuthor(a staleAction, now time.Time) error {
+	committer, err := r.provider.LastCommitter(r.info.ProjectID, a.branch)
+	if err != nil {
+		return fmt.Errorf("LastCommitter returns error: %w", err)
+	}
+
+	author := committer.Name
+	if committer.Username != "" {
+		author = "@" + committer.Username
+	}
+
+	message := fmt.Sprintf("%s, branch `%s` has no open MR and has been inactive for > %s ⌛. Push to it or open an MR, otherwise it will be deleted in %s 🧹.",
+		author, a.branch, english.Plural(r.config.StaleBranchesDeletion.Days, "day", ""), english.Plural(r.config.StaleBranchesDeletion.WaitDays, "day", ""))
+
+	if err := r.provider.CommentCommit(r.info.ProjectID, a.commit, message); err != nil {
+		return fmt.Errorf("CommentCommit returns error: %w", err)
+	}
+
+	return cache.SetBranchNotice(r.info.ProjectID, a.branch, branchNotice{Commit: a.commit, NotifiedAt: now})
+}
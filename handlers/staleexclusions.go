@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+// BranchDiff is how the branch differs from the default branch
+type BranchDiff struct {
+	// Unmerged is the number of commits which aren't on the default branch
+	Unmerged int
+	// FirstCommitAt is the time of the first unmerged commit, zero if there are none
+	FirstCommitAt time.Time
+}
+
+// excludedAuthor reports whether any of the names of the author matches exclude_authors, case-insensitively
+func (r Request) excludedAuthor(names ...string) bool {
+	patterns := make([]string, 0, len(r.config.StaleBranchesDeletion.ExcludeAuthors))
+	for _, p := range r.config.StaleBranchesDeletion.ExcludeAuthors {
+		patterns = append(patterns, strings.ToLower(p))
+	}
+
+	for _, name := range names {
+		if name != "" && matchAny(patterns, strings.ToLower(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepBranch returns why the stale cleanup must not touch the branch, empty string if it can;
+// exclude_unmerged applies to branches without MRs only, branches of open MRs are always unmerged
+func (r Request) keepBranch(branch string, hasMR bool, now time.Time, authors ...string) string {
+	settings := r.config.StaleBranchesDeletion
+
+	if slices.ContainsFunc(settings.ExcludeBranches, func(p string) bool { return matchBranch(p, branch) }) {
+		return "branch is excluded"
+	}
+
+	if r.excludedAuthor(authors...) {
+		return "author is excluded"
+	}
+
+	excludeUnmerged := settings.ExcludeUnmerged && !hasMR
+	if settings.MinAgeDays <= 0 && !excludeUnmerged {
+		return ""
+	}
+
+	diff, err := r.provider.CompareBranch(r.info.ProjectID, branch)
+	if err != nil {
+		// the branch is kept until it can be checked
+		logger.Error("branch can't be compared with the default branch", "branch", branch, "err", err)
+		return "branch can't be compared"
+	}
+
+	if excludeUnmerged && diff.Unmerged > 0 {
+		return fmt.Sprintf("branch has %s", english.Plural(diff.Unmerged, "unmerged commit", ""))
+	}
+
+	if settings.MinAgeDays > 0 && !diff.FirstCommitAt.IsZero() && inactiveDays(diff.FirstCommitAt, now) < settings.MinAgeDays {
+		return fmt.Sprintf("branch is younger than %s", english.Plural(settings.MinAgeDays, "day", ""))
+	}
+
+	return ""
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_matchBranch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		branch  string
+		want    bool
+	}{
+		{pattern: "main", branch: "main", want: true},
+		{pattern: "main", branch: "main-old", want: false},
+		{pattern: "release/*", branch: "release/1.2", want: true},
+		{pattern: "release/*", branch: "release/1.2/hotfix", want: false},
+		{pattern: "env/**", branch: "env/prod/eu", want: true},
+		{pattern: "re:^env/(prod|stage)$", branch: "env/prod", want: true},
+		{pattern: "re:^env/(prod|stage)$", branch: "env/dev", want: false},
+		{pattern: "re:(", branch: "(", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.branch, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchBranch(tt.pattern, tt.branch))
+		})
+	}
+}
+
+func TestRequest_keepBranch(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		branch  string
+		hasMR   bool
+		authors []string
+		setup   func(c *Config)
+		want    string
+	}{
+		{
+			name:   "stale branch isn't kept",
+			branch: "feature/old",
+		},
+		{
+			name:   "glob exclusion",
+			branch: "release/1.2",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.ExcludeBranches = []string{"release/*"} },
+			want:   "branch is excluded",
+		},
+		{
+			name:   "regex exclusion",
+			branch: "env/prod",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.ExcludeBranches = []string{"re:^env/"} },
+			want:   "branch is excluded",
+		},
+		{
+			name:    "author exclusion",
+			branch:  "feature/old",
+			authors: []string{"Release Robot", "Robot@Example.com"},
+			setup:   func(c *Config) { c.StaleBranchesDeletion.ExcludeAuthors = []string{"*@example.com"} },
+			want:    "author is excluded",
+		},
+		{
+			name:   "unmerged commits",
+			branch: "feature/old",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.ExcludeUnmerged = true },
+			want:   "branch has 2 unmerged commits",
+		},
+		{
+			name:   "unmerged commits of MR",
+			branch: "feature/old",
+			hasMR:  true,
+			setup:  func(c *Config) { c.StaleBranchesDeletion.ExcludeUnmerged = true },
+		},
+		{
+			name:    "author exclusion in upper case",
+			branch:  "feature/old",
+			hasMR:   true,
+			authors: []string{"renovate-bot"},
+			setup:   func(c *Config) { c.StaleBranchesDeletion.ExcludeAuthors = []string{"Renovate*"} },
+			want:    "author is excluded",
+		},
+		{
+			name:   "merged branch",
+			branch: "merged",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.ExcludeUnmerged = true },
+		},
+		{
+			name:   "young branch",
+			branch: "feature/old",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.MinAgeDays = 365 },
+			want:   "branch is younger than 365 days",
+		},
+		{
+			name:   "old enough branch",
+			branch: "feature/old",
+			setup:  func(c *Config) { c.StaleBranchesDeletion.MinAgeDays = 180 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{
+				diffs: map[string]BranchDiff{
+					"feature/old": {Unmerged: 2, FirstCommitAt: now.AddDate(0, 0, -200)},
+				},
+			}
+
+			r := Request{provider: provider, info: &MrInfo{ProjectID: 1}, config: defaultConfig()}
+			if tt.setup != nil {
+				tt.setup(r.config)
+			}
+
+			assert.Equal(t, tt.want, r.keepBranch(tt.branch, tt.hasMR, now, tt.authors...))
+		})
+	}
+}
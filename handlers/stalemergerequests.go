@@ -6,70 +6,291 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize/english"
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
 	"github.com/gasoid/merge-bot/v3/metrics"
 )
 
+const (
+	StaleMRDeleteBranch         = "delete_branch"
+	StaleMRClose                = "close"
+	StaleMRCloseAndDeleteBranch = "close_and_delete_branch"
+	StaleMRDraft                = "draft"
+
+	// staleMarker is in comments of the stale label, the comment keeps the time of the mark if the cache is lost
+	staleMarker = "<!-- merge-bot:stale -->"
+)
+
+var (
+	staleMRActions = []string{StaleMRDeleteBranch, StaleMRClose, StaleMRCloseAndDeleteBranch, StaleMRDraft}
+
+	// staleMRKinds are actions of the report for every mr_action
+	staleMRKinds = map[string]string{
+		StaleMRDeleteBranch:         staleDeleteMRBranch,
+		StaleMRClose:                staleCloseMR,
+		StaleMRCloseAndDeleteBranch: staleCloseMRDeleteBranch,
+		StaleMRDraft:                staleDraftMR,
+	}
+
+	// staleMRWarnings finish the comment of the stale label
+	staleMRWarnings = map[string]string{
+		StaleMRDeleteBranch:         "its branch will be deleted",
+		StaleMRClose:                "it will be closed",
+		StaleMRCloseAndDeleteBranch: "it will be closed and its branch will be deleted",
+		StaleMRDraft:                "it will be converted to draft",
+	}
+)
+
 type MR struct {
-	ID          int64
-	Branch      string
-	Protected   bool
-	Labels      []string
-	LastUpdated time.Time
+	ID        int64
+	Branch    string
+	Protected bool
+	Draft     bool
+	Author    string
+	// SHA is the head commit of the branch
+	SHA string
+	// PipelineStatus is the status of the head pipeline, empty if MR has none
+	PipelineStatus string
+	Labels         []string
+	LastUpdated    time.Time
 }
 
-func (r Request) cleanStaleMergeRequests() error {
+// staleMarkedAt returns the time when the bot marked MR as stale, the comment of the mark is read
+// if the cache has no mark and MR still has the label
+func (r Request) staleMarkedAt(mergeID int64, labeled bool) (time.Time, bool, error) {
+	markedAt, ok, err := cache.StaleMarkedAt(r.info.ProjectID, mergeID)
+	if err != nil || ok || !labeled {
+		return markedAt, ok, err
+	}
+
+	comment, err := r.provider.LastComment(r.info.ProjectID, mergeID, staleMarker)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("LastComment returns error: %w", err)
+	}
+
+	if comment == nil {
+		return time.Time{}, false, nil
+	}
+
+	if err := cache.MarkStale(r.info.ProjectID, mergeID, comment.CreatedAt); err != nil {
+		logger.Error("stale mark can't be cached", "mergeID", mergeID, "err", err)
+	}
+
+	return comment.CreatedAt, true, nil
+}
+
+// humanCommented returns who has commented MR since the time, bots are ignored
+func (r Request) humanCommented(mergeID int64, since time.Time) (string, error) {
+	activity, err := r.provider.GetReviewActivity(r.info.ProjectID, mergeID)
+	if err != nil {
+		return "", fmt.Errorf("GetReviewActivity returns error: %w", err)
+	}
+
+	for username, at := range activity.Commented {
+		if at.After(since) && !r.config.AssignReviewers.Candidates.isBot(Candidate{Username: username}) {
+			return username, nil
+		}
+	}
+
+	return "", nil
+}
+
+// staleMergeRequests selects MRs to mark as stale, to keep and the next batch of stale MRs to act on
+func (r Request) staleMergeRequests(now time.Time) []staleAction {
 	var (
+		actions               = []staleAction{}
 		days                  = r.config.StaleBranchesDeletion.Days
 		coolDays              = r.config.StaleBranchesDeletion.WaitDays
-		now                   = time.Now()
+		mrAction              = r.config.StaleBranchesDeletion.MRAction
 		branchesDeleted int64 = 0
-		excludeBranches       = make(map[string]struct{}, len(r.config.StaleBranchesDeletion.ExcludeBranches))
 	)
 
-	defer func() {
-		duration := time.Since(now)
-		metrics.MrDeletionDuration(duration)
-	}()
-
-	for _, s := range r.config.StaleBranchesDeletion.ExcludeBranches {
-		excludeBranches[s] = struct{}{}
-	}
-
 	for mr := range r.provider.ListMergeRequests(r.info.ProjectID, r.config.StaleBranchesDeletion.BatchSize, true) {
 		if branchesDeleted >= r.config.StaleBranchesDeletion.BatchSize {
 			break
 		}
 
 		span := now.Sub(mr.LastUpdated)
-		if slices.Contains(mr.Labels, staleLabel) {
-			if span > time.Duration(time.Duration(coolDays)*24*time.Hour) {
-				if err := r.provider.DeleteBranch(r.info.ProjectID, mr.Branch); err != nil {
-					return fmt.Errorf("DeleteBranch returns error: %w", err)
+		labeled := slices.Contains(mr.Labels, staleLabel)
+
+		// comments are loaded only for MRs which can be acted on
+		if labeled || span > time.Duration(days)*24*time.Hour {
+			_, snoozed, err := r.snoozedUntil(mr.ID, now)
+			if err != nil {
+				logger.Error("snooze can't be loaded", "mergeID", mr.ID, "err", err)
+				continue
+			}
+
+			if snoozed {
+				continue
+			}
+		}
+
+		markedAt, marked, err := r.staleMarkedAt(mr.ID, labeled)
+		if err != nil {
+			logger.Error("stale mark can't be loaded", "mergeID", mr.ID, "err", err)
+			continue
+		}
+
+		if labeled {
+			if marked {
+				username, err := r.humanCommented(mr.ID, markedAt)
+				if err != nil {
+					logger.Error("stale MR activity can't be loaded", "mergeID", mr.ID, "err", err)
+					continue
 				}
-				branchesDeleted++
-				metrics.MrDeletionInc()
+
+				if username != "" {
+					actions = append(actions, staleAction{
+						kind:    staleKeepMR,
+						branch:  mr.Branch,
+						mergeID: mr.ID,
+						reason:  fmt.Sprintf("@%s commented after it was marked as stale", username),
+					})
+					continue
+				}
+			}
+
+			// drafts stay stale until the label is removed
+			if mrAction == StaleMRDraft && mr.Draft {
 				continue
 			}
+
+			if span > time.Duration(time.Duration(coolDays)*24*time.Hour) {
+				actions = append(actions, staleAction{
+					kind:    staleMRKinds[mrAction],
+					branch:  mr.Branch,
+					commit:  mr.SHA,
+					mergeID: mr.ID,
+					reason:  fmt.Sprintf("marked as stale %s ago (> %s)", english.Plural(inactiveDays(mr.LastUpdated, now), "day", ""), english.Plural(coolDays, "day", "")),
+				})
+				branchesDeleted++
+			}
+			continue
 		}
 
-		if _, ok := excludeBranches[mr.Branch]; ok {
+		if marked {
+			// somebody removed the stale label, the MR is kept
+			actions = append(actions, staleAction{
+				kind:    staleKeepMR,
+				branch:  mr.Branch,
+				mergeID: mr.ID,
+				reason:  "the stale label was removed",
+			})
 			continue
 		}
 
-		if span > time.Duration(time.Duration(days)*24*time.Hour) {
-			// mr is stale
-			if err := r.provider.AssignLabel(r.info.ProjectID, mr.ID, staleLabel, staleLabelColor); err != nil {
+		if span <= time.Duration(time.Duration(days)*24*time.Hour) {
+			continue
+		}
+
+		if reason := r.keepBranch(mr.Branch, true, now, mr.Author); reason != "" {
+			logger.Debug("stale MR is kept", "mergeID", mr.ID, "reason", reason)
+			continue
+		}
+
+		// mr is stale
+		actions = append(actions, staleAction{
+			kind:    staleMarkMR,
+			branch:  mr.Branch,
+			mergeID: mr.ID,
+			reason:  fmt.Sprintf("no activity for %s (> %s)", english.Plural(inactiveDays(mr.LastUpdated, now), "day", ""), english.Plural(days, "day", "")),
+		})
+	}
+
+	return actions
+}
+
+func (r Request) closeStaleMR(mergeID int64) error {
+	message := fmt.Sprintf("This MR is closed because it has been stale for > %s 🧹. Reopen it if it's still needed.", english.Plural(r.config.StaleBranchesDeletion.Days, "day", ""))
+	if err := r.provider.LeaveComment(r.info.ProjectID, mergeID, message); err != nil {
+		return fmt.Errorf("LeaveComment returns error: %w", err)
+	}
+
+	if err := r.provider.CloseMergeRequest(r.info.ProjectID, mergeID); err != nil {
+		return fmt.Errorf("CloseMergeRequest returns error: %w", err)
+	}
+
+	return nil
+}
+
+func (r Request) cleanStaleMergeRequests() error {
+	var (
+		days     = r.config.StaleBranchesDeletion.Days
+		coolDays = r.config.StaleBranchesDeletion.WaitDays
+		now      = time.Now()
+	)
+
+	defer func() {
+		duration := time.Since(now)
+		metrics.MrDeletionDuration(duration)
+	}()
+
+	for _, a := range r.staleMergeRequests(now) {
+		switch a.kind {
+		case staleDeleteMRBranch:
+			if err := r.deleteBranch(a.branch, a.commit, now); err != nil {
+				return err
+			}
+			metrics.MrDeletionInc()
+
+		case staleCloseMR:
+			if err := r.closeStaleMR(a.mergeID); err != nil {
+				return err
+			}
+			metrics.MrDeletionInc()
+
+		case staleCloseMRDeleteBranch:
+			if err := r.closeStaleMR(a.mergeID); err != nil {
+				return err
+			}
+
+			if err := r.deleteBranch(a.branch, a.commit, now); err != nil {
+				return err
+			}
+			metrics.MrDeletionInc()
+
+		case staleDraftMR:
+			message := staleMarker + "\n" + fmt.Sprintf("This MR is converted to draft because it has been stale for > %s 🧹. Remove the stale label when it's ready.", english.Plural(days, "day", ""))
+			if err := r.provider.LeaveComment(r.info.ProjectID, a.mergeID, message); err != nil {
+				return fmt.Errorf("LeaveComment returns error: %w", err)
+			}
+
+			if err := r.provider.SetDraft(r.info.ProjectID, a.mergeID); err != nil {
+				return fmt.Errorf("SetDraft returns error: %w", err)
+			}
+
+			// the comment of the bot doesn't keep MR
+			if err := cache.MarkStale(r.info.ProjectID, a.mergeID, time.Now()); err != nil {
+				return fmt.Errorf("MarkStale returns error: %w", err)
+			}
+
+		case staleKeepMR:
+			if err := r.provider.RemoveLabel(r.info.ProjectID, a.mergeID, staleLabel); err != nil {
+				return fmt.Errorf("RemoveLabel returns error: %w", err)
+			}
+
+			if err := cache.UnmarkStale(r.info.ProjectID, a.mergeID); err != nil {
+				return fmt.Errorf("UnmarkStale returns error: %w", err)
+			}
+
+		case staleMarkMR:
+			if err := r.provider.AssignLabel(r.info.ProjectID, a.mergeID, staleLabel, staleLabelColor); err != nil {
 				return fmt.Errorf("AssignLabel returns error: %w", err)
 			}
 
 			pluralDays := english.Plural(days, "day", "")
 			pluralCoolDays := english.Plural(coolDays, "day", "")
 
-			message := fmt.Sprintf("This MR is stale because it has been open for > %s with no activity ⌛. Remove the stale label otherwise it will be closed in %s 🧹.", pluralDays, pluralCoolDays)
-			if err := r.provider.LeaveComment(r.info.ProjectID, mr.ID, message); err != nil {
+			message := staleMarker + "\n" + fmt.Sprintf("This MR is stale because it has been open for > %s with no activity ⌛. Remove the stale label, comment or use `!snooze 30d` otherwise %s in %s 🧹.", pluralDays, staleMRWarnings[r.config.StaleBranchesDeletion.MRAction], pluralCoolDays)
+			if err := r.provider.LeaveComment(r.info.ProjectID, a.mergeID, message); err != nil {
 				return fmt.Errorf("LeaveComment returns error: %w", err)
 			}
 
+			// comments before the mark don't keep MR
+			if err := cache.MarkStale(r.info.ProjectID, a.mergeID, time.Now()); err != nil {
+				return fmt.Errorf("MarkStale returns error: %w", err)
+			}
 		}
 	}
 
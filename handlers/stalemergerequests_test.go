@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_cleanStaleMergeRequests(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		mrAction      string
+		draft         bool
+		markedAt      time.Time
+		labeled       bool
+		commented     map[string]time.Time
+		snoozed       bool
+		notes         []Comment
+		wantDeleted   []string
+		wantClosed    []int64
+		wantDrafted   []int64
+		wantUnlabeled []int64
+		wantMarked    bool
+	}{
+		{
+			name:        "branch is deleted by default",
+			mrAction:    StaleMRDeleteBranch,
+			labeled:     true,
+			wantDeleted: []string{"old-mr"},
+		},
+		{
+			name:       "MR is closed",
+			mrAction:   StaleMRClose,
+			labeled:    true,
+			wantClosed: []int64{1},
+		},
+		{
+			name:        "MR is closed and branch is deleted",
+			mrAction:    StaleMRCloseAndDeleteBranch,
+			labeled:     true,
+			wantDeleted: []string{"old-mr"},
+			wantClosed:  []int64{1},
+		},
+		{
+			name:        "MR is converted to draft",
+			mrAction:    StaleMRDraft,
+			labeled:     true,
+			markedAt:    now.AddDate(0, 0, -5),
+			wantDrafted: []int64{1},
+			wantMarked:  true,
+		},
+		{
+			name:       "draft is kept",
+			mrAction:   StaleMRDraft,
+			labeled:    true,
+			draft:      true,
+			markedAt:   now.AddDate(0, 0, -5),
+			wantMarked: true,
+		},
+		{
+			name:          "MR is kept if human commented after the mark",
+			mrAction:      StaleMRClose,
+			labeled:       true,
+			markedAt:      now.AddDate(0, 0, -5),
+			commented:     map[string]time.Time{"alice": now.AddDate(0, 0, -4)},
+			wantUnlabeled: []int64{1},
+		},
+		{
+			name:       "comments of bots and before the mark don't keep MR",
+			mrAction:   StaleMRClose,
+			labeled:    true,
+			markedAt:   now.AddDate(0, 0, -5),
+			commented:  map[string]time.Time{"alice": now.AddDate(0, 0, -6), "renovate-bot": now.AddDate(0, 0, -4)},
+			wantClosed: []int64{1},
+			wantMarked: true,
+		},
+		{
+			name:          "MR is kept if stale label is removed",
+			mrAction:      StaleMRClose,
+			markedAt:      now.AddDate(0, 0, -5),
+			wantUnlabeled: []int64{1},
+		},
+		{
+			name:     "snoozed MR is skipped",
+			mrAction: StaleMRClose,
+			labeled:  true,
+			snoozed:  true,
+		},
+		{
+			name:     "MR snoozed by comment is skipped without cache",
+			mrAction: StaleMRClose,
+			labeled:  true,
+			notes:    []Comment{{Body: snoozeComment(now.Add(time.Hour))}},
+		},
+		{
+			name:       "expired snooze doesn't keep MR",
+			mrAction:   StaleMRClose,
+			labeled:    true,
+			notes:      []Comment{{Body: snoozeComment(now.Add(-time.Hour))}},
+			wantClosed: []int64{1},
+		},
+		{
+			name:          "stale comment is the mark without cache",
+			mrAction:      StaleMRClose,
+			labeled:       true,
+			notes:         []Comment{{Body: staleMarker + "\nThis MR is stale", CreatedAt: now.AddDate(0, 0, -5)}},
+			commented:     map[string]time.Time{"alice": now.AddDate(0, 0, -4)},
+			wantUnlabeled: []int64{1},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := int64(4500 + i)
+
+			mr := MR{ID: 1, Branch: "old-mr", Draft: tt.draft, LastUpdated: now.AddDate(0, 0, -5)}
+			if tt.labeled {
+				mr.Labels = []string{staleLabel}
+			}
+
+			provider := &testProvider{
+				mergeRequests: []MR{mr},
+				activity:      map[int64]*ReviewActivity{1: {Commented: tt.commented}},
+				notes:         tt.notes,
+			}
+
+			if !tt.markedAt.IsZero() {
+				assert.NoError(t, cache.MarkStale(projectID, 1, tt.markedAt))
+			}
+
+			if tt.snoozed {
+				assert.NoError(t, cache.Snooze(projectID, 1, now.Add(time.Hour)))
+			}
+
+			r := Request{provider: provider, info: &MrInfo{ProjectID: projectID}, config: defaultConfig()}
+			r.config.StaleBranchesDeletion.MRAction = tt.mrAction
+
+			assert.NoError(t, r.cleanStaleMergeRequests())
+			assert.Equal(t, tt.wantDeleted, provider.deleted)
+			assert.Equal(t, tt.wantClosed, provider.closed)
+			assert.Equal(t, tt.wantDrafted, provider.drafted)
+			assert.Equal(t, tt.wantUnlabeled, provider.unlabeled)
+
+			_, marked, err := cache.StaleMarkedAt(projectID, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMarked, marked)
+		})
+	}
+}
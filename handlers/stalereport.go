@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize/english"
+)
+
+const (
+	staleNotifyBranch   = "notify author of branch"
+	staleDeleteBranch   = "delete branch"
+	staleMarkMR         = "mark MR as stale"
+	staleDeleteMRBranch = "delete branch of stale MR"
+
+	staleCloseMR             = "close stale MR"
+	staleCloseMRDeleteBranch = "close stale MR and delete its branch"
+	staleDraftMR             = "convert stale MR to draft"
+	staleKeepMR              = "keep MR"
+
+	// staleReportTitle is the title of the issue with dry run reports
+	staleReportTitle = "Merge Bot: stale branches cleanup preview"
+)
+
+// staleAction is what the stale cleanup does with a branch or MR
+type staleAction struct {
+	kind    string
+	branch  string
+	commit  string
+	mergeID int64
+	reason  string
+}
+
+func (a staleAction) String() string {
+	if a.mergeID > 0 {
+		return fmt.Sprintf("!%d (`%s`) - %s", a.mergeID, a.branch, a.reason)
+	}
+
+	return fmt.Sprintf("`%s` - %s", a.branch, a.reason)
+}
+
+// staleActions returns what the next cleanup run would do
+func (r Request) staleActions(now time.Time) []staleAction {
+	return append(r.staleMergeRequests(now), r.staleBranches(now)...)
+}
+
+func (r Request) staleReport(actions []staleAction) string {
+	settings := r.config.StaleBranchesDeletion
+
+	builder := &strings.Builder{}
+	builder.WriteString("🧹 **Stale branches cleanup preview**\n\n")
+
+	if !settings.Enabled {
+		builder.WriteString("ℹ️ `stale_branches_deletion` is disabled, nothing is deleted.\n\n")
+	} else if settings.DryRun {
+		builder.WriteString("ℹ️ `stale_branches_deletion.dry_run` is on, nothing is deleted.\n\n")
+	}
+
+	if len(actions) == 0 {
+		builder.WriteString("✅ Nothing to clean up\n")
+		return builder.String()
+	}
+
+	fmt.Fprintf(builder, "The next run would do the following (at most %s are deleted per run):\n", english.Plural(int(settings.BatchSize), "branch", "branches"))
+
+	if settings.Archive == ArchiveTag {
+		fmt.Fprintf(builder, "\nDeleted branches are archived as `%s<branch>/<date>` tags, use `!restore-branch <name>` to restore.\n", archiveTagPrefix)
+	}
+
+	for _, kind := range []string{staleKeepMR, staleMarkMR, staleDraftMR, staleCloseMR, staleCloseMRDeleteBranch, staleDeleteMRBranch, staleNotifyBranch, staleDeleteBranch} {
+		items := []string{}
+		for _, a := range actions {
+			if a.kind == kind {
+				items = append(items, "- "+a.String())
+			}
+		}
+
+		if len(items) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(builder, "\n**%s%s** (%d):\n%s\n", strings.ToUpper(kind[:1]), kind[1:], len(items), strings.Join(items, "\n"))
+	}
+
+	return builder.String()
+}
+
+// StalePreview lists branches and MRs which the next stale cleanup run would delete or mark
+func (r Request) StalePreview() string {
+	return r.staleReport(r.staleActions(time.Now()))
+}
+
+// reportStale keeps the project issue with the dry run report up to date instead of deleting
+func (r Request) reportStale() error {
+	report := r.staleReport(r.staleActions(time.Now()))
+
+	if err := r.provider.UpsertIssue(r.info.ProjectID, staleReportTitle, report); err != nil {
+		return fmt.Errorf("UpsertIssue returns error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_DeleteStaleBranches_DryRun(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantDeleted []string
+		wantLabeled []int64
+		wantReport  []string
+	}{
+		{
+			name:        "stale branches and MRs are cleaned",
+			wantDeleted: []string{"old-mr", "old"},
+			wantLabeled: []int64{2},
+		},
+		{
+			name:   "dry run only reports",
+			dryRun: true,
+			wantReport: []string{
+				"`stale_branches_deletion.dry_run` is on, nothing is deleted.",
+				"**Mark MR as stale** (1):\n- !2 (`quiet-mr`) - no activity for 100 days (> 90 days)",
+				"**Delete branch of stale MR** (1):\n- !1 (`old-mr`) - marked as stale 5 days ago (> 1 day)",
+				"**Delete branch** (1):\n- `old` - no activity for 120 days (> 90 days), the author was notified 2 days ago",
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{
+				branches: []StaleBranch{
+					{Name: "old", Commit: "c1", LastUpdated: now.AddDate(0, 0, -120)},
+					{Name: "fresh", LastUpdated: now.AddDate(0, 0, -3)},
+					{Name: "release", LastUpdated: now.AddDate(0, 0, -300)},
+				},
+				mergeRequests: []MR{
+					{ID: 1, Branch: "old-mr", Labels: []string{staleLabel}, LastUpdated: now.AddDate(0, 0, -5)},
+					{ID: 2, Branch: "quiet-mr", LastUpdated: now.AddDate(0, 0, -100)},
+					{ID: 3, Branch: "active-mr", LastUpdated: now.AddDate(0, 0, -1)},
+				},
+			}
+
+			r := Request{provider: provider, info: &MrInfo{ProjectID: int64(4200 + i)}, config: defaultConfig()}
+			notice := branchNotice{Commit: "c1", NotifiedAt: now.AddDate(0, 0, -2)}
+			assert.NoError(t, cache.SetBranchNotice(r.info.ProjectID, "old", notice))
+			r.config.StaleBranchesDeletion.Enabled = true
+			r.config.StaleBranchesDeletion.ExcludeBranches = []string{"release"}
+			r.config.StaleBranchesDeletion.DryRun = tt.dryRun
+
+			assert.NoError(t, r.DeleteStaleBranches())
+			assert.Equal(t, tt.wantDeleted, provider.deleted)
+			assert.Equal(t, tt.wantLabeled, provider.labeled)
+
+			if len(tt.wantReport) == 0 {
+				assert.Empty(t, provider.issues)
+				return
+			}
+
+			for _, want := range tt.wantReport {
+				assert.Contains(t, provider.issues[staleReportTitle], want)
+			}
+		})
+	}
+}
+
+func TestRequest_StalePreview(t *testing.T) {
+	provider := &testProvider{
+		branches: []StaleBranch{{Name: "old", LastUpdated: time.Now().AddDate(0, 0, -120)}},
+	}
+
+	r := Request{provider: provider, info: &MrInfo{ProjectID: 1}, config: defaultConfig()}
+
+	report := r.StalePreview()
+	assert.Contains(t, report, "`stale_branches_deletion` is disabled, nothing is deleted.")
+	assert.Contains(t, report, "at most 5 branches are deleted per run")
+	assert.Contains(t, report, "- `old` - no activity for 120 days (> 90 days)")
+	assert.Empty(t, provider.deleted)
+
+	provider.branches = nil
+	assert.Contains(t, r.StalePreview(), "✅ Nothing to clean up")
+}
+
+func TestRequest_cleanStaleBranches_Notify(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	now := time.Now()
+	const projectID = 4400
+
+	tests := []struct {
+		name         string
+		notice       *branchNotice
+		wantDeleted  []string
+		wantComment  string
+		wantNotified bool
+	}{
+		{
+			name:         "author is notified first",
+			wantComment:  "@alice, branch `old` has no open MR and has been inactive for > 90 days ⌛. Push to it or open an MR, otherwise it will be deleted in 1 day 🧹.",
+			wantNotified: true,
+		},
+		{
+			name:   "branch is kept during wait days",
+			notice: &branchNotice{Commit: "c1", NotifiedAt: now.Add(-time.Hour)},
+		},
+		{
+			name:        "branch is deleted after wait days",
+			notice:      &branchNotice{Commit: "c1", NotifiedAt: now.AddDate(0, 0, -2)},
+			wantDeleted: []string{"old"},
+		},
+		{
+			name:         "author is notified again if branch is updated",
+			notice:       &branchNotice{Commit: "c0", NotifiedAt: now.AddDate(0, 0, -200)},
+			wantComment:  "@alice, branch `old`",
+			wantNotified: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{
+				branches:   []StaleBranch{{Name: "old", Commit: "c1", LastUpdated: now.AddDate(0, 0, -120)}},
+				committers: map[string]Committer{"old": {Name: "Alice", Email: "alice@example.com", Username: "alice"}},
+			}
+
+			r := Request{provider: provider, info: &MrInfo{ProjectID: int64(projectID + i)}, config: defaultConfig()}
+			r.config.StaleBranchesDeletion.Enabled = true
+
+			if tt.notice != nil {
+				assert.NoError(t, cache.SetBranchNotice(r.info.ProjectID, "old", tt.notice))
+			}
+
+			assert.NoError(t, r.DeleteStaleBranches())
+			assert.Equal(t, tt.wantDeleted, provider.deleted)
+
+			if tt.wantComment == "" {
+				assert.Empty(t, provider.commitComments)
+				return
+			}
+
+			assert.Contains(t, provider.commitComments["c1"], tt.wantComment)
+
+			notice := branchNotice{}
+			ok, err := cache.GetBranchNotice(r.info.ProjectID, "old", &notice)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantNotified, ok)
+			assert.Equal(t, "c1", notice.Commit)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+type Team struct {
+	Name    string   `yaml:"name"`
+	Members []string `yaml:"members"`
+	// Groups are GitLab groups like org/payments, their members are the team members too
+	Groups []string `yaml:"groups"`
+	Paths  []string `yaml:"paths"`
+}
+
+type resolvedTeam struct {
+	Team
+	members map[string]struct{}
+}
+
+func (t resolvedTeam) has(username string) bool {
+	_, ok := t.members[username]
+	return ok
+}
+
+func (t resolvedTeam) owns(files []string) bool {
+	return slices.ContainsFunc(files, func(f string) bool {
+		return matchAny(t.Paths, f)
+	})
+}
+
+func teamNames(teams []resolvedTeam) string {
+	names := make([]string, 0, len(teams))
+	for _, t := range teams {
+		names = append(names, t.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func inTeams(teams []resolvedTeam, username string) bool {
+	return slices.ContainsFunc(teams, func(t resolvedTeam) bool {
+		return t.has(username)
+	})
+}
+
+func (r Request) groupMembers(group string) ([]string, error) {
+	members, ok, err := cache.GetGroupMembers(group)
+	if err != nil {
+		logger.Info("group cache is unavailable", "group", group, "err", err)
+	}
+
+	if ok {
+		return members, nil
+	}
+
+	members, err = r.provider.GetGroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.SetGroupMembers(group, members); err != nil {
+		logger.Info("group members can't be cached", "group", group, "err", err)
+	}
+
+	return members, nil
+}
+
+// resolveTeams merges members listed in config with members of groups, unavailable groups are skipped
+func (r Request) resolveTeams() []resolvedTeam {
+	teams := make([]resolvedTeam, 0, len(r.config.AssignReviewers.Teams))
+
+	for _, t := range r.config.AssignReviewers.Teams {
+		team := resolvedTeam{Team: t, members: map[string]struct{}{}}
+
+		for _, m := range t.Members {
+			team.members[m] = struct{}{}
+		}
+
+		for _, g := range t.Groups {
+			members, err := r.groupMembers(g)
+			if err != nil {
+				logger.Info("group members can't be fetched", "team", t.Name, "group", g, "err", err)
+				continue
+			}
+
+			for _, m := range members {
+				team.members[m] = struct{}{}
+			}
+		}
+
+		teams = append(teams, team)
+	}
+
+	return teams
+}
+
+// withTeamMembers adds members of teams who haven't authored MRs recently to the pool,
+// the provider checks their access level, availability and bots are checked with the rest of the pool
+func (r Request) withTeamMembers(gamblers []Candidate) []Candidate {
+	usernames := []string{}
+	for _, t := range r.resolveTeams() {
+		for m := range t.members {
+			if slices.Contains(usernames, m) || slices.ContainsFunc(gamblers, func(g Candidate) bool { return g.Username == m }) {
+				continue
+			}
+
+			usernames = append(usernames, m)
+		}
+	}
+
+	if len(usernames) == 0 {
+		return gamblers
+	}
+
+	slices.Sort(usernames)
+
+	members, err := r.provider.GetMembers(r.info.ProjectID, r.info.ID, usernames, r.config.AssignReviewers.Candidates)
+	if err != nil {
+		logger.Info("team members can't be loaded, roulette picks contributors only", "err", err)
+		return gamblers
+	}
+
+	return append(gamblers, members...)
+}
+
+// pickByTeams picks num reviewers from teams owning changed files and crossTeam ones from outside of the author's teams,
+// gamblers must be sorted by priority, it returns winners and the description of pools
+func (r Request) pickByTeams(gamblers []Candidate, num, crossTeam int) ([]Candidate, []string, error) {
+	changedFiles, err := r.getChangedFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teams := r.resolveTeams()
+
+	owners := []resolvedTeam{}
+	authorTeams := []resolvedTeam{}
+	for _, t := range teams {
+		if t.owns(changedFiles) {
+			owners = append(owners, t)
+		}
+
+		if t.has(r.info.Author) {
+			authorTeams = append(authorTeams, t)
+		}
+	}
+
+	winners := make([]Candidate, 0, num+crossTeam)
+	pick := func(n int, fits func(Candidate) bool) int {
+		picked := 0
+		for _, g := range gamblers {
+			if picked == n {
+				break
+			}
+
+			if !fits(g) || slices.ContainsFunc(winners, func(w Candidate) bool { return w.Username == g.Username }) {
+				continue
+			}
+
+			winners = append(winners, g)
+			picked++
+		}
+
+		return picked
+	}
+
+	anyone := func(Candidate) bool { return true }
+	pools := []string{}
+
+	if len(owners) == 0 {
+		picked := pick(num, anyone)
+		pools = append(pools, fmt.Sprintf("no team owns the changed files, %d from all contributors", picked))
+	} else {
+		picked := pick(num, func(c Candidate) bool { return inTeams(owners, c.Username) })
+		pools = append(pools, fmt.Sprintf("%d from %s (owners of the changed files)", picked, teamNames(owners)))
+
+		if picked < num {
+			picked = pick(num-picked, anyone)
+			pools = append(pools, fmt.Sprintf("%d from all contributors, owners don't have enough available reviewers", picked))
+		}
+	}
+
+	if crossTeam > 0 {
+		picked := pick(crossTeam, func(c Candidate) bool { return !inTeams(authorTeams, c.Username) })
+
+		outside := "outside of the author's team"
+		if len(authorTeams) > 0 {
+			outside = fmt.Sprintf("outside of %s (author's team)", teamNames(authorTeams))
+		}
+		pools = append(pools, fmt.Sprintf("%d from %s", picked, outside))
+	}
+
+	return winners, pools, nil
+}
+
+func (v *configValidator) teams(teams []Team) {
+	seen := map[string]struct{}{}
+
+	for i, t := range teams {
+		if t.Name == "" {
+			v.add(fmt.Sprintf("`review_roulette.teams[%d].name` must not be empty", i), "review_roulette", "teams", i)
+		} else if _, ok := seen[t.Name]; ok {
+			v.add(fmt.Sprintf("`review_roulette.teams[%d].name` %q is used twice", i, t.Name), "review_roulette", "teams", i, "name")
+		}
+		seen[t.Name] = struct{}{}
+
+		if len(t.Members) == 0 && len(t.Groups) == 0 {
+			v.add(fmt.Sprintf("`review_roulette.teams[%d]` must have members or groups", i), "review_roulette", "teams", i)
+		}
+
+		v.globs(t.Paths, "review_roulette", "teams", i, "paths")
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_spinRoulette_Teams(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	candidates := []Candidate{
+		{Username: "author"},
+		{Username: "pay1"},
+		{Username: "pay2"},
+		{Username: "web1"},
+		{Username: "web2"},
+		{Username: "ops1"},
+	}
+
+	// members of teams who aren't contributors
+	members := []Candidate{{Username: "pay3"}, {Username: "paybot", Bot: true}}
+
+	// the order of winners is defined by open reviews
+	openReviews := map[string]int{"pay1": 0, "pay2": 1, "web1": 2, "web2": 3, "ops1": 4, "pay3": 5, "paybot": 0}
+
+	teams := []Team{
+		{Name: "payments", Members: []string{"pay1"}, Groups: []string{"org/payments"}, Paths: []string{"services/payments/**"}},
+		{Name: "web", Members: []string{"author", "web1", "web2"}, Paths: []string{"web/**"}},
+		{Name: "ops", Groups: []string{"org/unknown"}, Paths: []string{"deploy/**"}},
+	}
+
+	tests := []struct {
+		name         string
+		changedFiles []string
+		num          int
+		cross        int
+		wantWinners  []string
+		wantPools    []string
+	}{
+		{
+			name:         "owners of changed files",
+			changedFiles: []string{"services/payments/api.go"},
+			num:          2,
+			wantWinners:  []string{"pay1", "pay2"},
+			wantPools:    []string{"2 from payments (owners of the changed files)"},
+		},
+		{
+			name:         "team members who aren't contributors",
+			changedFiles: []string{"services/payments/api.go"},
+			num:          3,
+			wantWinners:  []string{"pay1", "pay2", "pay3"},
+			wantPools:    []string{"3 from payments (owners of the changed files)"},
+		},
+		{
+			name:         "cross team reviewer",
+			changedFiles: []string{"web/index.html"},
+			num:          1,
+			cross:        1,
+			wantWinners:  []string{"web1", "pay1"},
+			wantPools:    []string{"1 from web (owners of the changed files)", "1 from outside of web (author's team)"},
+		},
+		{
+			name:         "owners have not enough reviewers",
+			changedFiles: []string{"deploy/values.yaml"},
+			num:          1,
+			wantWinners:  []string{"pay1"},
+			wantPools: []string{
+				"0 from ops (owners of the changed files)",
+				"1 from all contributors, owners don't have enough available reviewers",
+			},
+		},
+		{
+			name:         "no owners",
+			changedFiles: []string{"README.md"},
+			num:          2,
+			wantWinners:  []string{"pay1", "pay2"},
+			wantPools:    []string{"no team owns the changed files, 2 from all contributors"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{
+				provider: &testProvider{
+					candidates:   candidates,
+					members:      members,
+					openReviews:  openReviews,
+					changedFiles: tt.changedFiles,
+					groups:       map[string][]string{"org/payments": {"pay2", "pay3", "paybot"}},
+				},
+				info:   &MrInfo{ProjectID: 1, ID: 2, Author: "author"},
+				config: defaultConfig(),
+			}
+			r.config.AssignReviewers.Teams = teams
+			r.config.AssignReviewers.CrossTeamReviewers = tt.cross
+
+			result, err := r.spinRoulette(tt.num)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWinners, result.Winners)
+			assert.Equal(t, tt.wantPools, result.Pools)
+			assert.Contains(t, result.String(), "👥 Teams:\n- "+tt.wantPools[0])
+		})
+	}
+}
+
+func TestRequest_PartialSpins_Teams(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	newRequest := func(projectID int64) (Request, *testProvider) {
+		provider := &testProvider{
+			candidates:   []Candidate{{Username: "web1"}, {Username: "web2"}, {Username: "web3"}, {Username: "pay1"}},
+			openReviews:  map[string]int{"web1": 0, "web2": 1, "web3": 2, "pay1": 3},
+			changedFiles: []string{"web/index.html"},
+		}
+		r := Request{
+			provider: provider,
+			info:     &MrInfo{ProjectID: projectID, ID: 2, Author: "author", Reviewers: []string{"web1"}},
+			config:   defaultConfig(),
+		}
+		r.config.AssignReviewers.Teams = []Team{{Name: "web", Members: []string{"author", "web1", "web2", "web3"}, Paths: []string{"web/**"}}}
+		r.config.AssignReviewers.CrossTeamReviewers = 1
+
+		return r, provider
+	}
+
+	// the cross-team reviewer isn't added to the requested number
+	r, provider := newRequest(3900)
+	result, err := r.RerollReviewer("web1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web2"}, result.Winners)
+	assert.Equal(t, []string{"web2"}, provider.assigned)
+
+	r, provider = newRequest(3901)
+	result, err = r.AddReviewers(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web2"}, result.Winners)
+	assert.Equal(t, []string{"web1", "web2"}, provider.assigned)
+}
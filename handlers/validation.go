@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	yamlErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlNodeType  = reflect.TypeFor[yaml.Node]()
+)
+
+type ConfigIssue struct {
+	Line int
+	// Path is the key of the problem, e.g. rules.title_regex, empty if it is unknown
+	Path    string
+	Message string
+	// invalid means the value can't be used, unlike unknown keys which are ignored
+	invalid bool
+}
+
+// inRules reports whether the problem is in merge rules, such problems block merging
+func (c ConfigIssue) inRules() bool {
+	root := c.Path
+	if i := strings.IndexAny(root, ".["); i >= 0 {
+		root = root[:i]
+	}
+
+	return root == "rules" || root == "branch_rules"
+}
+
+func (c ConfigIssue) String() string {
+	if c.Line > 0 {
+		return fmt.Sprintf("line %d: %s", c.Line, c.Message)
+	}
+
+	return c.Message
+}
+
+type ConfigError struct {
+	Issues []ConfigIssue
+}
+
+func (e *ConfigError) Error() string {
+	issues := make([]string, 0, len(e.Issues))
+	for _, i := range e.Issues {
+		issues = append(issues, i.String())
+	}
+
+	return "config is invalid: " + strings.Join(issues, "; ")
+}
+
+// hasInvalidValues reports whether the config has values which can't be used
+func (e *ConfigError) hasInvalidValues() bool {
+	return slices.ContainsFunc(e.Issues, func(i ConfigIssue) bool { return i.invalid })
+}
+
+func parseYamlError(text string) ConfigIssue {
+	match := yamlErrorLine.FindStringSubmatch(text)
+	if match == nil {
+		return ConfigIssue{Message: text}
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	return ConfigIssue{Line: line, Message: match[2]}
+}
+
+// yamlName returns the key of the field in yaml, empty if the field isn't decoded
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" || !f.IsExported() {
+		return ""
+	}
+
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+
+	return name
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+
+	for f := range t.Fields() {
+		if name := yamlName(f); name != "" {
+			fields[name] = f.Type
+		}
+	}
+
+	return fields
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// suggestKey returns the closest known key if it looks like a typo
+func suggestKey(key string, known map[string]reflect.Type) string {
+	best, bestDistance := "", max(2, len(key)/3)+1
+
+	for k := range known {
+		d := levenshtein(key, k)
+		if d < bestDistance || (d == bestDistance && k < best) {
+			best, bestDistance = k, d
+		}
+	}
+
+	return best
+}
+
+func walkUnknownKeys(node *yaml.Node, t reflect.Type, path string) []ConfigIssue {
+	issues := []ConfigIssue{}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	// yaml.Node is used for partial overrides of rules
+	if t == yamlNodeType {
+		t = reflect.TypeFor[Rules]()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return issues
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := strings.TrimPrefix(path+"."+key.Value, ".")
+
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown key `%s`", name)
+				if suggestion := suggestKey(key.Value, fields); suggestion != "" {
+					message += fmt.Sprintf(", did you mean `%s`?", suggestion)
+				}
+
+				issues = append(issues, ConfigIssue{Line: key.Line, Path: name, Message: message})
+				continue
+			}
+
+			issues = append(issues, walkUnknownKeys(value, fieldType, name)...)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return issues
+		}
+
+		for i, item := range node.Content {
+			issues = append(issues, walkUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return issues
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			issues = append(issues, walkUnknownKeys(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value)...)
+		}
+	}
+
+	return issues
+}
+
+// unknownKeys reports keys which don't exist in Config, e.g. typos like min_aprovals
+func unknownKeys(root *yaml.Node) []ConfigIssue {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+
+	return walkUnknownKeys(root.Content[0], reflect.TypeFor[Config](), "")
+}
+
+// nodeLine returns the line of the value found by path of keys and indexes, 0 if it is not found
+func nodeLine(root *yaml.Node, path ...any) int {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return 0
+	}
+
+	node := root.Content[0]
+
+	for _, p := range path {
+		var next *yaml.Node
+
+		switch p := p.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return 0
+			}
+
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == p {
+					next = node.Content[i+1]
+					break
+				}
+			}
+
+		case int:
+			if node.Kind != yaml.SequenceNode || p >= len(node.Content) {
+				return 0
+			}
+			next = node.Content[p]
+		}
+
+		if next == nil {
+			return 0
+		}
+
+		node = next
+	}
+
+	return node.Line
+}
+
+// linePath returns the path of the innermost key or item which starts at the line, empty if there is none
+func linePath(node *yaml.Node, line int, path string) string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return linePath(node.Content[0], line, path)
+	}
+
+	found := ""
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := strings.TrimPrefix(path+"."+key.Value, ".")
+
+			if key.Line == line {
+				found = name
+			}
+
+			if nested := linePath(value, line, name); nested != "" {
+				found = nested
+			}
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			name := fmt.Sprintf("%s[%d]", path, i)
+
+			if item.Line == line {
+				found = name
+			}
+
+			if nested := linePath(item, line, name); nested != "" {
+				found = nested
+			}
+		}
+	}
+
+	return found
+}
+
+type configValidator struct {
+	root   *yaml.Node
+	issues []ConfigIssue
+}
+
+func (v *configValidator) add(message string, path ...any) {
+	v.issues = append(v.issues, ConfigIssue{Line: nodeLine(v.root, path...), Path: keyPath(path), Message: message, invalid: true})
+}
+
+func (v *configValidator) atLeast(value, minimum int64, path ...any) {
+	if value < minimum {
+		v.add(fmt.Sprintf("`%s` must be at least %d, got %d", keyPath(path), minimum, value), path...)
+	}
+}
+
+func (v *configValidator) regex(value string, path ...any) {
+	if _, err := regexp.Compile(value); err != nil {
+		v.add(fmt.Sprintf("`%s` is not a valid regex: %s", keyPath(path), err), path...)
+	}
+}
+
+func (v *configValidator) globs(values []string, path ...any) {
+	for i, value := range values {
+		if _, err := glob.Compile(value, '/'); err != nil {
+			itemPath := append(path, i)
+			v.add(fmt.Sprintf("`%s` is not a valid pattern: %s", keyPath(itemPath), err), itemPath...)
+		}
+	}
+}
+
+// branchPatterns checks globs and regexes prefixed with re:
+func (v *configValidator) branchPatterns(values []string, path ...any) {
+	for i, value := range values {
+		itemPath := append(slices.Clone(path), i)
+
+		if expr, ok := strings.CutPrefix(value, regexPrefix); ok {
+			v.regex(expr, itemPath...)
+			continue
+		}
+
+		if _, err := glob.Compile(value, '/'); err != nil {
+			v.add(fmt.Sprintf("`%s` is not a valid pattern: %s", keyPath(itemPath), err), itemPath...)
+		}
+	}
+}
+
+func (v *configValidator) rules(rules Rules, path ...any) {
+	v.atLeast(int64(rules.MinApprovals), 0, append(path, "min_approvals")...)
+	v.regex(rules.TitleRegex, append(path, "title_regex")...)
+}
+
+func keyPath(path []any) string {
+	builder := strings.Builder{}
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			if builder.Len() > 0 {
+				builder.WriteString(".")
+			}
+			builder.WriteString(p)
+		case int:
+			fmt.Fprintf(&builder, "[%d]", p)
+		}
+	}
+
+	return builder.String()
+}
+
+// validateConfig checks values which can be decoded, but can't be used
+func validateConfig(c *Config, root *yaml.Node) []ConfigIssue {
+	v := &configValidator{root: root}
+
+	v.rules(c.Rules, "rules")
+
+	if _, err := template.New("greetings").Parse(c.Greetings.Template); err != nil {
+		v.add(fmt.Sprintf("`greetings.template` can't be parsed: %s", err), "greetings", "template")
+	}
+
+	v.atLeast(int64(c.AssignReviewers.ReviewerNumber), 1, "review_roulette", "reviewer_number")
+	v.atLeast(int64(c.AssignReviewers.MaxOpenReviews), 0, "review_roulette", "max_open_reviews")
+	v.atLeast(int64(c.AssignReviewers.FairnessDays), 1, "review_roulette", "fairness_days")
+	v.candidates(c.AssignReviewers.Candidates)
+	v.workingHours(c.AssignReviewers.WorkingHours, c.Reviewers)
+	v.teams(c.AssignReviewers.Teams)
+	v.atLeast(int64(c.AssignReviewers.CrossTeamReviewers), 0, "review_roulette", "cross_team_reviewers")
+	v.atLeast(int64(c.AssignReviewers.Expertise.Weight), 0, "review_roulette", "expertise", "weight")
+	v.atLeast(int64(c.AssignReviewers.Expertise.Days), 1, "review_roulette", "expertise", "days")
+	v.atLeast(int64(c.AssignReviewers.Expertise.MaxFiles), 1, "review_roulette", "expertise", "max_files")
+
+	v.atLeast(int64(c.ReviewReminders.SLAHours), 1, "review_reminders", "review_sla_hours")
+	v.atLeast(int64(c.ReviewReminders.EscalationHours), 1, "review_reminders", "escalation_hours")
+
+	v.atLeast(int64(c.StaleBranchesDeletion.Days), 1, "stale_branches_deletion", "days")
+	v.atLeast(int64(c.StaleBranchesDeletion.MinAgeDays), 0, "stale_branches_deletion", "min_age_days")
+	v.atLeast(c.StaleBranchesDeletion.BatchSize, 1, "stale_branches_deletion", "batch_size")
+	v.atLeast(int64(c.StaleBranchesDeletion.WaitDays), 0, "stale_branches_deletion", "wait_days")
+	if a := c.StaleBranchesDeletion.Archive; a != "" && a != ArchiveTag {
+		v.add(fmt.Sprintf("`stale_branches_deletion.archive` must be %q or empty, got %q", ArchiveTag, a), "stale_branches_deletion", "archive")
+	}
+	if a := c.StaleBranchesDeletion.MRAction; !slices.Contains(staleMRActions, a) {
+		v.add(fmt.Sprintf("`stale_branches_deletion.mr_action` must be one of %s, got %q", strings.Join(staleMRActions, ", "), a), "stale_branches_deletion", "mr_action")
+	}
+	v.branchPatterns(c.StaleBranchesDeletion.ExcludeBranches, "stale_branches_deletion", "exclude_branches")
+	v.globs(c.StaleBranchesDeletion.ExcludeAuthors, "stale_branches_deletion", "exclude_authors")
+
+	v.atLeast(int64(c.ConflictWarnings.MaxMergeRequests), 1, "conflict_warnings", "max_merge_requests")
+
+	v.atLeast(int64(c.PipelineFailureSummary.TraceLines), 1, "pipeline_failure_summary", "trace_lines")
+	v.atLeast(int64(c.PipelineFailureSummary.MaxJobs), 1, "pipeline_failure_summary", "max_jobs")
+	for i, p := range c.PipelineFailureSummary.ErrorPatterns {
+		v.regex(p, "pipeline_failure_summary", "error_patterns", i)
+	}
+
+	for i, b := range c.BranchRules {
+		if len(b.TargetBranches) == 0 {
+			v.add(fmt.Sprintf("`branch_rules[%d].target_branches` must not be empty", i), "branch_rules", i)
+		}
+
+		v.globs(b.TargetBranches, "branch_rules", i, "target_branches")
+		v.globs(b.Paths, "branch_rules", i, "paths")
+
+		if b.Rules.IsZero() {
+			continue
+		}
+
+		rules := Rules{}
+		if err := b.Rules.Decode(&rules); err != nil {
+			v.add(fmt.Sprintf("`branch_rules[%d].rules` can't be decoded: %s", i, err), "branch_rules", i, "rules")
+			continue
+		}
+
+		v.rules(rules, "branch_rules", i, "rules")
+	}
+
+	return v.issues
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_ParseConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantIssues []string
+	}{
+		{
+			name:    "valid config",
+			content: "rules:\n  min_approvals: 2\n  approvers: []\n",
+		},
+		{
+			name:    "typo",
+			content: "rules:\n  min_aprovals: 2\nreview_rulette:\n  enabled: true\n",
+			wantIssues: []string{
+				"line 2: unknown key `rules.min_aprovals`, did you mean `min_approvals`?",
+				"line 3: unknown key `review_rulette`, did you mean `review_roulette`?",
+			},
+		},
+		{
+			name:       "unknown key without suggestion",
+			content:    "greetings:\n  foo: bar\n",
+			wantIssues: []string{"line 2: unknown key `greetings.foo`"},
+		},
+		{
+			name:       "wrong type",
+			content:    "rules:\n  min_approvals: two\n",
+			wantIssues: []string{"line 2: cannot unmarshal !!str `two` into int"},
+		},
+		{
+			name:    "semantic problems",
+			content: "rules:\n  title_regex: '[a-z'\ngreetings:\n  template: '{{ .MinApprovals '\nstale_branches_deletion:\n  batch_size: 0\n  archive: zip\n  mr_action: archive\n  exclude_branches: ['release/*', 're:env/(']\n",
+			wantIssues: []string{
+				"line 2: `rules.title_regex` is not a valid regex: error parsing regexp: missing closing ]: `[a-z`",
+				"line 4: `greetings.template` can't be parsed: template: greetings:1: unclosed action",
+				"line 6: `stale_branches_deletion.batch_size` must be at least 1, got 0",
+				"line 7: `stale_branches_deletion.archive` must be \"tag\" or empty, got \"zip\"",
+				"line 8: `stale_branches_deletion.mr_action` must be one of delete_branch, close, close_and_delete_branch, draft, got \"archive\"",
+				"line 9: `stale_branches_deletion.exclude_branches[1]` is not a valid regex: error parsing regexp: missing closing ): `env/(`",
+			},
+		},
+		{
+			name:    "roulette candidates",
+			content: "review_roulette:\n  candidates:\n    bot_usernames: ['[bot']\n    min_access_level: admin\n    lookback_days: 0\n",
+			wantIssues: []string{
+				"line 3: `review_roulette.candidates.bot_usernames[0]` is not a valid pattern: unexpected end of input",
+				"line 4: `review_roulette.candidates.min_access_level` must be one of guest, reporter, developer, maintainer, owner, got \"admin\"",
+				"line 5: `review_roulette.candidates.lookback_days` must be at least 1, got 0",
+			},
+		},
+		{
+			name:    "branch rules",
+			content: "branch_rules:\n  - paths: ['[']\n    rules:\n      min_aprovals: 2\n      title_regex: '('\n",
+			wantIssues: []string{
+				"line 4: unknown key `branch_rules[0].rules.min_aprovals`, did you mean `min_approvals`?",
+				"line 2: `branch_rules[0].target_branches` must not be empty",
+				"line 2: `branch_rules[0].paths[0]` is not a valid pattern: unexpected end of input",
+				"line 5: `branch_rules[0].rules.title_regex` is not a valid regex: error parsing regexp: missing closing ): `(`",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{provider: &testProvider{}}
+
+			got, err := r.ParseConfig(tt.content)
+			assert.NotNil(t, got)
+
+			if len(tt.wantIssues) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			configErr := &ConfigError{}
+			if !assert.ErrorAs(t, err, &configErr) {
+				return
+			}
+
+			issues := make([]string, 0, len(configErr.Issues))
+			for _, i := range configErr.Issues {
+				issues = append(issues, i.String())
+			}
+			assert.Equal(t, tt.wantIssues, issues)
+		})
+	}
+}
+
+func TestRequest_loadConfig_InvalidValues(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	const (
+		valid   = "rules:\n  min_approvals: 3\n  approvers: []\nreview_reminders:\n  enabled: true\n"
+		invalid = "rules:\n  min_approvals: 3\n  approvers: []\npipeline_failure_summary:\n  trace_lines: -1\n"
+	)
+
+	r := &Request{provider: &testProvider{}, info: &MrInfo{ProjectID: 2900}}
+
+	// there is no valid config yet
+	assert.NoError(t, r.loadConfig(invalid))
+	assert.Equal(t, defaultConfig(), r.config)
+	assert.Equal(t, "default settings are used instead", r.configFallback)
+	assert.Len(t, r.configIssues, 1)
+
+	r = &Request{provider: &testProvider{}, info: &MrInfo{ProjectID: 2900}}
+	assert.NoError(t, r.loadConfig(valid))
+	assert.Empty(t, r.configFallback)
+	assert.Empty(t, r.configIssues)
+
+	r = &Request{provider: &testProvider{}, info: &MrInfo{ProjectID: 2900}}
+	assert.NoError(t, r.loadConfig(invalid))
+	assert.Equal(t, "the last valid config is used instead", r.configFallback)
+	assert.True(t, r.config.ReviewReminders.Enabled)
+	assert.Equal(t, 200, r.config.PipelineFailureSummary.TraceLines)
+
+	// unknown keys are ignored, the config is used
+	r = &Request{provider: &testProvider{}, info: &MrInfo{ProjectID: 2900}}
+	assert.NoError(t, r.loadConfig("review_reminders:\n  enabeld: true\n"))
+	assert.Empty(t, r.configFallback)
+	assert.False(t, r.config.ReviewReminders.Enabled)
+}
+
+func TestRequest_IsValid_RulesProblems(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantPaths []string
+		// wantAllowed means merging isn't blocked by the problems, other checks may still fail
+		wantAllowed bool
+	}{
+		{
+			name:      "invalid title regex",
+			config:    "rules:\n  title_regex: '('\n  approvers: []\n",
+			wantPaths: []string{"rules.title_regex"},
+		},
+		{
+			name:      "negative approvals",
+			config:    "rules:\n  min_approvals: -1\n  approvers: []\n",
+			wantPaths: []string{"rules.min_approvals"},
+		},
+		{
+			name:      "approvals of a wrong type",
+			config:    "rules:\n  min_approvals: two\n  approvers: []\n",
+			wantPaths: []string{"rules.min_approvals"},
+		},
+		{
+			name:      "typo in rules",
+			config:    "rules:\n  min_aprovals: 2\n  approvers: []\n",
+			wantPaths: []string{"rules.min_aprovals"},
+		},
+		{
+			name:      "invalid branch rule",
+			config:    "rules:\n  approvers: []\nbranch_rules:\n  - paths: ['docs/**']\n",
+			wantPaths: []string{"branch_rules[0]"},
+		},
+		{
+			name:        "problems out of rules",
+			config:      "rules:\n  min_approvals: 0\n  approvers: []\npipeline_failure_summary:\n  max_jobs: 0\n",
+			wantPaths:   []string{"pipeline_failure_summary.max_jobs"},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Request{provider: &testProvider{state: "opened", config: tt.config}}
+
+			if err := r.LoadInfoAndConfig(1, 2); err != nil {
+				t.Fatalf("LoadInfoAndConfig failed: %v", err)
+			}
+
+			paths := []string{}
+			for _, i := range r.configIssues {
+				paths = append(paths, i.Path)
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+
+			ok, text, err := r.IsValid()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, !strings.Contains(text, "Merge rules of the config have problems ❌"), text)
+			if !tt.wantAllowed {
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
+func TestRequest_ParseConfig_SyntaxError(t *testing.T) {
+	r := &Request{provider: &testProvider{}}
+
+	got, err := r.ParseConfig("rules: [")
+	assert.Error(t, err)
+	assert.Nil(t, got)
+}
+
+func TestRequest_ConfigReport(t *testing.T) {
+	r := &Request{provider: &testProvider{
+		state:  "opened",
+		config: "extends: base.yaml\nrules:\n  min_aprovals: 2\n  approvers: []\n",
+		files:  map[string]string{"1:base.yaml": "rules: {title_regex: '^JIRA-'}"},
+	}}
+
+	if err := r.LoadInfoAndConfig(1, 2); err != nil {
+		t.Fatalf("LoadInfoAndConfig failed: %v", err)
+	}
+
+	text, err := r.ConfigReport()
+	assert.NoError(t, err)
+	assert.Contains(t, text, "ℹ️ Extends `base.yaml`")
+	assert.Contains(t, text, "- line 3: unknown key `rules.min_aprovals`, did you mean `min_approvals`?")
+	assert.Contains(t, text, "min_aprovals: 2")
+	assert.NotContains(t, text, "title_regex: ^JIRA-")
+
+	_, text, err = r.IsValid()
+	assert.NoError(t, err)
+	assert.Contains(t, text, "Config has 1 problem, send `!config` to see details")
+	assert.Contains(t, text, "Merge rules of the config have problems ❌")
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/calendar"
+	"github.com/gasoid/merge-bot/v3/logger"
+)
+
+const (
+	clockLayout = "15:04"
+	// overlapWindow is how far ahead working hours of a reviewer and the author are compared
+	overlapWindow = time.Hour * 24
+	overlapStep   = time.Minute * 30
+	// outOfOfficeLookups is how many personal calendars are loaded at once
+	outOfOfficeLookups = 8
+)
+
+const (
+	tierWorking = iota
+	tierOverlap
+	tierOffHours
+)
+
+var (
+	// outOfOfficeTimeout bounds loading of all personal calendars of the spin
+	outOfOfficeTimeout = 3 * time.Second
+
+	utcOffset = regexp.MustCompile(`^UTC([+-])(\d{1,2}):?(\d{2})?$`)
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+type WorkingHours struct {
+	Enabled  bool     `yaml:"enabled"`
+	Start    string   `yaml:"start"`
+	End      string   `yaml:"end"`
+	Days     []string `yaml:"days"`
+	Timezone string   `yaml:"timezone"`
+	Holidays []string `yaml:"holidays"`
+}
+
+type Reviewer struct {
+	Timezone string `yaml:"timezone"`
+	// Holidays replace working_hours.holidays for the reviewer
+	Holidays []string `yaml:"holidays,omitempty"`
+}
+
+// schedule is working time of a particular person
+type schedule struct {
+	location  *time.Location
+	calendars []string
+	// holidays are loaded calendars, see withHolidays
+	holidays []*calendar.Calendar
+}
+
+// holidayCalendars are calendars loaded during a spin, people usually share them
+type holidayCalendars map[string]*calendar.Calendar
+
+// withHolidays loads calendars of the schedule once, calendars which can't be loaded are skipped
+func (s schedule) withHolidays(loaded holidayCalendars) schedule {
+	s.holidays = make([]*calendar.Calendar, 0, len(s.calendars))
+	for _, name := range s.calendars {
+		c, ok := loaded[name]
+		if !ok {
+			var err error
+			if c, err = calendar.Load(name); err != nil {
+				logger.Info("calendar can't be loaded", "calendar", name, "err", err)
+			}
+
+			loaded[name] = c
+		}
+
+		if c != nil {
+			s.holidays = append(s.holidays, c)
+		}
+	}
+
+	return s
+}
+
+// loadTimezone accepts IANA names like Europe/Berlin and offsets like UTC+02:00
+func loadTimezone(name string) (*time.Location, error) {
+	match := utcOffset.FindStringSubmatch(name)
+	if match == nil {
+		return time.LoadLocation(name)
+	}
+
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	offset := hours*3600 + minutes*60
+	if match[1] == "-" {
+		offset = -offset
+	}
+
+	return time.FixedZone(name, offset), nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w WorkingHours) isWorkday(local time.Time) bool {
+	return slices.ContainsFunc(w.Days, func(d string) bool {
+		return weekdays[strings.ToLower(d)] == local.Weekday()
+	})
+}
+
+func isHoliday(local time.Time, holidays []*calendar.Calendar) bool {
+	return slices.ContainsFunc(holidays, func(c *calendar.Calendar) bool {
+		_, ok := c.Holiday(local)
+		return ok
+	})
+}
+
+func (w WorkingHours) isWorking(t time.Time, s schedule) bool {
+	local := t.In(s.location)
+
+	if !w.isWorkday(local) {
+		return false
+	}
+
+	if isHoliday(local, s.holidays) {
+		return false
+	}
+
+	start, startErr := parseClock(w.Start)
+	end, endErr := parseClock(w.End)
+	if startErr != nil || endErr != nil {
+		return true
+	}
+
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+
+	// night shifts like 22:00-06:00
+	if end <= start {
+		return clock >= start || clock < end
+	}
+
+	return clock >= start && clock < end
+}
+
+// shifts returns working periods of the day as offsets from its midnight
+func (w WorkingHours) shifts() [][2]time.Duration {
+	start, startErr := parseClock(w.Start)
+	end, endErr := parseClock(w.End)
+	if startErr != nil || endErr != nil {
+		return [][2]time.Duration{{0, 24 * time.Hour}}
+	}
+
+	// night shifts like 22:00-06:00 are the morning and the evening of the same day
+	if end <= start {
+		return [][2]time.Duration{{0, end}, {start, 24 * time.Hour}}
+	}
+
+	return [][2]time.Duration{{start, end}}
+}
+
+// workingDuration counts working time between from and to day by day, it stops counting at limit
+func (w WorkingHours) workingDuration(from, to time.Time, s schedule, limit time.Duration) time.Duration {
+	var worked time.Duration
+
+	shifts := w.shifts()
+
+	local := from.In(s.location)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location); day.Before(to) && worked < limit; day = day.AddDate(0, 0, 1) {
+		if !w.isWorkday(day) || isHoliday(day, s.holidays) {
+			continue
+		}
+
+		for _, shift := range shifts {
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, int(shift[0].Minutes()), 0, 0, s.location)
+			end := time.Date(day.Year(), day.Month(), day.Day(), 0, int(shift[1].Minutes()), 0, 0, s.location)
+
+			if start.Before(from) {
+				start = from
+			}
+
+			if end.After(to) {
+				end = to
+			}
+
+			if start.Before(end) {
+				worked += end.Sub(start)
+			}
+		}
+	}
+
+	return min(worked, limit)
+}
+
+func (r Request) scheduleOf(c Candidate) schedule {
+	settings := r.config.AssignReviewers.WorkingHours
+	s := schedule{location: time.UTC, calendars: settings.Holidays}
+
+	timezone := settings.Timezone
+	if c.Timezone != "" {
+		timezone = c.Timezone
+	}
+
+	if reviewer, ok := r.config.Reviewers[c.Username]; ok {
+		if reviewer.Timezone != "" {
+			timezone = reviewer.Timezone
+		}
+
+		if reviewer.Holidays != nil {
+			s.calendars = reviewer.Holidays
+		}
+	}
+
+	if timezone != "" {
+		loc, err := loadTimezone(timezone)
+		if err != nil {
+			logger.Info("timezone is unknown, UTC is used", "username", c.Username, "timezone", timezone)
+		} else {
+			s.location = loc
+		}
+	}
+
+	return s
+}
+
+// workingTier tells how convenient it is for the reviewer to review now:
+// in working hours, working hours overlapping with the author's ones soon or off hours
+func (r Request) workingTier(reviewer, author schedule, now time.Time) int {
+	settings := r.config.AssignReviewers.WorkingHours
+
+	if settings.isWorking(now, reviewer) {
+		return tierWorking
+	}
+
+	for t := now; t.Before(now.Add(overlapWindow)); t = t.Add(overlapStep) {
+		if settings.isWorking(t, reviewer) && settings.isWorking(t, author) {
+			return tierOverlap
+		}
+	}
+
+	return tierOffHours
+}
+
+// outOfOffice returns the vacation of the person from the personal calendar
+func (r Request) outOfOffice(c Candidate, now time.Time) (string, bool) {
+	e, ok := calendar.OutOfOffice(c.Username, now.In(r.scheduleOf(c).location))
+	if !ok {
+		return "", false
+	}
+
+	if e.Summary == "" {
+		return "out of office", true
+	}
+
+	return e.Summary, true
+}
+
+// setOutOfOffice looks up vacations of candidates at once, candidates whose calendars aren't loaded
+// within outOfOfficeTimeout are considered available, their calendars are still cached for next spins
+func (r Request) setOutOfOffice(gamblers []Candidate, now time.Time) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		sem     = make(chan struct{}, outOfOfficeLookups)
+		done    = make(chan struct{})
+		results = map[int]string{}
+	)
+
+	for i, c := range gamblers {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ooo, _ := r.outOfOffice(c, now)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = ooo
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(outOfOfficeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		logger.Info("calendars aren't loaded in time, the rest of candidates are considered available", "timeout", outOfOfficeTimeout)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, ooo := range results {
+		gamblers[i].OutOfOffice = ooo
+	}
+}
+
+// setWorkingTiers ranks candidates by working hours, the author is looked up among all contributors
+func (r Request) setWorkingTiers(gamblers, contributors []Candidate, now time.Time) {
+	author := Candidate{Username: r.info.Author}
+	if i := slices.IndexFunc(contributors, func(c Candidate) bool { return c.Username == r.info.Author }); i >= 0 {
+		author = contributors[i]
+	}
+
+	loaded := holidayCalendars{}
+	authorSchedule := r.scheduleOf(author).withHolidays(loaded)
+
+	for i := range gamblers {
+		gamblers[i].WorkingTier = r.workingTier(r.scheduleOf(gamblers[i]).withHolidays(loaded), authorSchedule, now)
+	}
+}
+
+func (v *configValidator) workingHours(w WorkingHours, reviewers map[string]Reviewer) {
+	for _, key := range []string{"start", "end"} {
+		value := w.Start
+		if key == "end" {
+			value = w.End
+		}
+
+		if _, err := parseClock(value); err != nil {
+			v.add(fmt.Sprintf("`review_roulette.working_hours.%s` must be a time like 09:00, got %q", key, value), "review_roulette", "working_hours", key)
+		}
+	}
+
+	for i, d := range w.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			v.add(fmt.Sprintf("`review_roulette.working_hours.days[%d]` must be one of mon, tue, wed, thu, fri, sat, sun, got %q", i, d), "review_roulette", "working_hours", "days", i)
+		}
+	}
+
+	v.timezone(w.Timezone, "review_roulette", "working_hours", "timezone")
+
+	for name, reviewer := range reviewers {
+		v.timezone(reviewer.Timezone, "reviewers", name, "timezone")
+	}
+}
+
+func (v *configValidator) timezone(value string, path ...any) {
+	if value == "" {
+		return
+	}
+
+	if _, err := loadTimezone(value); err != nil {
+		v.add(fmt.Sprintf("`%s` is not a valid timezone: %q", keyPath(path), value), path...)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/calendar"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_loadTimezone(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantOffset int
+		wantErr    bool
+	}{
+		{"UTC", 0, false},
+		{"Asia/Tokyo", 9 * 3600, false},
+		{"UTC+02:00", 2 * 3600, false},
+		{"UTC-5", -5 * 3600, false},
+		{"UTC+05:30", 5*3600 + 30*60, false},
+		{"Mars/Olympus", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := loadTimezone(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			_, offset := time.Date(2026, 1, 15, 12, 0, 0, 0, loc).Zone()
+			assert.Equal(t, tt.wantOffset, offset)
+		})
+	}
+}
+
+func TestRequest_workingTier(t *testing.T) {
+	r := Request{config: defaultConfig()}
+	r.config.AssignReviewers.WorkingHours.Enabled = true
+	r.config.Reviewers = map[string]Reviewer{
+		"tokyo": {Timezone: "Asia/Tokyo"},
+	}
+
+	berlin := r.scheduleOf(Candidate{Username: "berlin", Timezone: "Europe/Berlin"})
+	tokyo := r.scheduleOf(Candidate{Username: "tokyo", Timezone: "Europe/Berlin"})
+	newYork := r.scheduleOf(Candidate{Username: "new-york", Timezone: "America/New_York"})
+
+	assert.Equal(t, "Asia/Tokyo", tokyo.location.String(), "config has priority over profile")
+
+	// Wednesday 10:00 in Berlin, 17:00 in Tokyo, 04:00 in New York
+	now := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, tierWorking, r.workingTier(tokyo, berlin, now))
+	assert.Equal(t, tierOverlap, r.workingTier(newYork, berlin, now))
+	// Tokyo and New York working hours don't overlap
+	assert.Equal(t, tierOffHours, r.workingTier(newYork, tokyo, now.Add(time.Hour*2)))
+
+	// Saturday
+	assert.Equal(t, tierOffHours, r.workingTier(berlin, berlin, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)))
+}
+
+func TestWorkingHours_isWorking_NightShift(t *testing.T) {
+	w := WorkingHours{Start: "22:00", End: "06:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+	s := schedule{location: time.UTC}
+
+	assert.True(t, w.isWorking(time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC), s))
+	assert.True(t, w.isWorking(time.Date(2026, 10, 14, 5, 59, 0, 0, time.UTC), s))
+	assert.False(t, w.isWorking(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), s))
+}
+
+func TestSchedule_withHolidays(t *testing.T) {
+	w := defaultConfig().AssignReviewers.WorkingHours
+	holiday, err := calendar.Parse(strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261014\r\nSUMMARY:Holiday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.NoError(t, err)
+
+	// calendars are loaded once, the missing one isn't loaded again
+	loaded := holidayCalendars{"holidays.ics": holiday}
+	s := schedule{location: time.UTC, calendars: []string{"holidays.ics", "missing.ics"}}.withHolidays(loaded)
+	assert.Equal(t, []*calendar.Calendar{holiday}, s.holidays)
+	assert.Contains(t, loaded, "missing.ics")
+
+	// Wednesday
+	assert.False(t, w.isWorking(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), s))
+	assert.True(t, w.isWorking(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC), s))
+}
+
+// slowSource returns the vacation calendar of every user after the delay of the user
+type slowSource struct {
+	vacation *calendar.Calendar
+	delays   map[string]time.Duration
+}
+
+func (s slowSource) Calendar(username string) (*calendar.Calendar, error) {
+	time.Sleep(s.delays[username])
+	return s.vacation, nil
+}
+
+func TestRequest_setOutOfOffice(t *testing.T) {
+	now := time.Now()
+	vacation, err := calendar.Parse(strings.NewReader(fmt.Sprintf(
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:%s\r\nDTEND;VALUE=DATE:%s\r\nSUMMARY:Vacation\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		now.AddDate(0, 0, -1).Format("20060102"), now.AddDate(0, 0, 2).Format("20060102"),
+	)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	calendar.SetSources(slowSource{vacation: vacation, delays: map[string]time.Duration{"bob": time.Second}})
+	defer calendar.SetSources()
+
+	defer func(timeout time.Duration) { outOfOfficeTimeout = timeout }(outOfOfficeTimeout)
+	outOfOfficeTimeout = 100 * time.Millisecond
+
+	r := Request{info: &MrInfo{}, config: defaultConfig()}
+	gamblers := []Candidate{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}}
+
+	start := time.Now()
+	r.setOutOfOffice(gamblers, now)
+
+	// the calendar of bob isn't loaded in time, bob is considered available
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"Vacation", "", "Vacation"}, []string{gamblers[0].OutOfOffice, gamblers[1].OutOfOffice, gamblers[2].OutOfOffice})
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/calendar"
 	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/handlers"
 	_ "github.com/gasoid/merge-bot/v3/handlers/gitlab"
 	"github.com/gasoid/merge-bot/v3/logger"
 	_ "github.com/gasoid/merge-bot/v3/webhook/gitlab"
@@ -16,12 +22,33 @@ func main() {
 		return
 	}
 
+	if showConfigSchema {
+		if err := PrintConfigSchema(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger.New()
 
+	if err := handlers.InitUpdater(); err != nil {
+		logger.Error("git updater can't be initialized", "error", err)
+		os.Exit(1)
+	}
+
 	if err := cache.Init(); err != nil {
 		logger.Error("cache can't be initialized", "error", err)
 	}
 
+	if err := calendar.Init(); err != nil {
+		logger.Error("personal calendars can't be loaded", "error", err)
+	}
+
+	if err := startScheduler(context.Background()); err != nil {
+		logger.Error("scheduler can't be started", "error", err)
+	}
+
 	startMetricsEndpoint()
 	start()
 }
@@ -17,11 +17,15 @@ var (
 	mrDeletionCounter             *prometheus.CounterVec
 	branchesDeletionDuration      prometheus.Histogram
 	mrDeletionDuration            prometheus.Histogram
+	cacheRequestsCounter          *prometheus.CounterVec
+	gitDuration                   *prometheus.HistogramVec
 )
 
 const (
 	commandSucceeded = "succeeded"
 	commandFailed    = "failed"
+	cacheHit         = "hit"
+	cacheMiss        = "miss"
 )
 
 func Handler(event string, f func() error) error {
@@ -76,6 +80,19 @@ func MrDeletionDuration(duration time.Duration) {
 	mrDeletionDuration.Observe(duration.Seconds())
 }
 
+func CacheHitInc(name string) {
+	cacheRequestsCounter.WithLabelValues(name, cacheHit).Inc()
+}
+
+func CacheMissInc(name string) {
+	cacheRequestsCounter.WithLabelValues(name, cacheMiss).Inc()
+}
+
+// GitDuration observes clone and fetch of bare mirrors
+func GitDuration(operation string, duration time.Duration) {
+	gitDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
 func initMetrics() error {
 	commandsCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -135,6 +152,20 @@ func initMetrics() error {
 		Buckets: prometheus.LinearBuckets(5, 4, 10),
 	})
 
+	cacheRequestsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mergebot_cache_requests_total",
+			Help: "How many cache lookups hit or missed",
+		},
+		[]string{"cache", "result"},
+	)
+
+	gitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mergebot_git_duration",
+		Help:    "Time it has taken to clone or fetch the mirror of the repository",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	}, []string{"operation"})
+
 	if err := prometheus.Register(backgroundTaskCounter); err != nil {
 		return err
 	}
@@ -167,6 +198,14 @@ func initMetrics() error {
 		return err
 	}
 
+	if err := prometheus.Register(cacheRequestsCounter); err != nil {
+		return err
+	}
+
+	if err := prometheus.Register(gitDuration); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	hourly = "@hourly"
+	every  = "@every "
+
+	minInterval = time.Minute
+)
+
+// Schedule runs a task at fixed intervals like "@hourly" or "@every 30m"
+type Schedule struct {
+	interval time.Duration
+}
+
+// Parse accepts @hourly and @every <duration>, the duration is at least a minute
+func Parse(expr string) (Schedule, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	if expr == hourly {
+		return Schedule{interval: time.Hour}, nil
+	}
+
+	text, ok := strings.CutPrefix(expr, every)
+	if !ok {
+		return Schedule{}, fmt.Errorf("%w: %q must be @hourly or @every <duration>", ErrSchedule, expr)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(text))
+	if err != nil || d < minInterval {
+		return Schedule{}, fmt.Errorf("%w: %q must be a duration of at least %s", ErrSchedule, text, minInterval)
+	}
+
+	return Schedule{interval: d}, nil
+}
+
+// Next returns the first time after t which is a multiple of the interval,
+// replicas get the same times, so every run is claimed once
+func (s Schedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.interval).Add(s.interval)
+}
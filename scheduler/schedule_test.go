@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "@hourly"},
+		{expr: "@every 15m"},
+		{expr: " @Every 24h "},
+		{expr: "@every 30s", wantErr: true},
+		{expr: "@every soon", wantErr: true},
+		{expr: "@daily", wantErr: true},
+		{expr: "0 3 * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrSchedule)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@every 15m", time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)},
+		{"@every 6h", time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)},
+		{"@every 24h", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, s.Next(now))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+)
+
+// Off disables the task in -schedule
+const Off = "off"
+
+var (
+	schedules string
+	jitter    string
+
+	ErrSchedule = errors.New("schedule is invalid")
+)
+
+func init() {
+	config.StringVar(&schedules, "schedule", "", "schedules of background tasks separated by semicolon, @hourly or @every <duration>, e.g. \"stale-branches=@every 24h; review-reminders=off\" (also via SCHEDULE)")
+	config.StringVar(&jitter, "schedule-jitter", "5m", "max random delay of background tasks, it spreads API requests of replicas and tasks (also via SCHEDULE_JITTER)")
+}
+
+type task struct {
+	name     string
+	schedule Schedule
+	run      func(ctx context.Context)
+}
+
+// Scheduler runs background tasks by schedule, every run is done by a single replica
+type Scheduler struct {
+	tasks     []task
+	jitter    time.Duration
+	overrides map[string]string
+}
+
+// New returns Scheduler configured by -schedule and -schedule-jitter
+func New() (*Scheduler, error) {
+	d, err := time.ParseDuration(jitter)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("%w: jitter %q must be a duration like 5m", ErrSchedule, jitter)
+	}
+
+	overrides, err := parseOverrides(schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{jitter: d, overrides: overrides}, nil
+}
+
+func parseOverrides(s string) (map[string]string, error) {
+	overrides := map[string]string{}
+
+	for item := range strings.SplitSeq(s, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q must look like task=schedule", ErrSchedule, item)
+		}
+
+		overrides[strings.TrimSpace(name)] = strings.TrimSpace(spec)
+	}
+
+	return overrides, nil
+}
+
+// Add registers the task, spec is the default schedule which can be overridden by -schedule
+func (s *Scheduler) Add(name, spec string, run func(ctx context.Context)) error {
+	if override, ok := s.overrides[name]; ok {
+		spec = override
+	}
+
+	if strings.EqualFold(spec, Off) {
+		logger.Info("scheduled task is disabled", "task", name)
+		return nil
+	}
+
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", name, err)
+	}
+
+	s.tasks = append(s.tasks, task{name: name, schedule: schedule, run: run})
+	return nil
+}
+
+// Start runs tasks in background until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	for name := range s.overrides {
+		if !s.known(name) {
+			logger.Info("-schedule has unknown task", "task", name)
+		}
+	}
+
+	for _, t := range s.tasks {
+		go s.loop(ctx, t)
+	}
+}
+
+func (s *Scheduler) known(name string) bool {
+	for _, t := range s.tasks {
+		if t.name == name {
+			return true
+		}
+	}
+
+	return s.overrides[name] == Off
+}
+
+func (s *Scheduler) delay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+
+	return rand.N(s.jitter)
+}
+
+func (s *Scheduler) loop(ctx context.Context, t task) {
+	for {
+		at := t.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(at) + s.delay())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, t, at)
+	}
+}
+
+// runOnce runs the task if no other replica has run it at the time
+func (s *Scheduler) runOnce(ctx context.Context, t task, at time.Time) bool {
+	ok, err := cache.ClaimRun(t.name, at)
+	if err != nil {
+		logger.Error("scheduled task can't be claimed", "task", t.name, "err", err)
+		return false
+	}
+
+	if !ok {
+		logger.Debug("scheduled task is run by another replica", "task", t.name, "at", at)
+		return false
+	}
+
+	logger.Debug("scheduled task started", "task", t.name, "at", at)
+	metrics.BackgroundRunInc("scheduler_" + strings.ReplaceAll(t.name, "-", "_"))
+
+	t.run(ctx)
+	return true
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	defer func() { schedules, jitter = "", "5m" }()
+
+	schedules = "stale-branches=@every 24h; review-reminders=off;"
+	s, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, s.jitter)
+
+	runs := 0
+	run := func(ctx context.Context) { runs++ }
+
+	assert.NoError(t, s.Add("stale-branches", "@hourly", run))
+	assert.NoError(t, s.Add("review-reminders", "@hourly", run))
+	assert.NoError(t, s.Add("labels", "@every 6h", run))
+
+	names := []string{}
+	for _, t := range s.tasks {
+		names = append(names, t.name)
+	}
+	assert.Equal(t, []string{"stale-branches", "labels"}, names)
+	assert.Equal(t, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), s.tasks[0].schedule.Next(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)))
+
+	assert.ErrorIs(t, s.Add("broken", "every hour", run), ErrSchedule)
+
+	schedules = "stale-branches"
+	_, err = New()
+	assert.ErrorIs(t, err, ErrSchedule)
+
+	schedules, jitter = "", "soon"
+	_, err = New()
+	assert.ErrorIs(t, err, ErrSchedule)
+}
+
+func TestScheduler_runOnce(t *testing.T) {
+	if err := cache.Init(); err != nil {
+		t.Fatalf("cache.Init failed: %v", err)
+	}
+
+	runs := 0
+	task := task{name: "test-run-once", run: func(ctx context.Context) { runs++ }}
+
+	// two replicas get the same tick
+	replica1, replica2 := &Scheduler{}, &Scheduler{}
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	assert.True(t, replica1.runOnce(context.Background(), task, at))
+	assert.False(t, replica2.runOnce(context.Background(), task, at))
+	assert.Equal(t, 1, runs)
+
+	assert.True(t, replica2.runOnce(context.Background(), task, at.Add(time.Hour)))
+	assert.Equal(t, 2, runs)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/handlers"
+)
+
+var (
+	showConfigSchema bool
+)
+
+func init() {
+	config.BoolVar(&showConfigSchema, "config-schema", false, "Prints JSON Schema of .mrbot.yaml")
+}
+
+func PrintConfigSchema() error {
+	schema, err := handlers.ConfigSchema()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(schema))
+	return nil
+}
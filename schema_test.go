@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gasoid/merge-bot/v3/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSchemaIsUpToDate(t *testing.T) {
+	published, err := os.ReadFile("mrbot.schema.json")
+	if err != nil {
+		t.Fatalf("mrbot.schema.json can't be read: %v", err)
+	}
+
+	schema, err := handlers.ConfigSchema()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(published)), string(schema), "run: go run . -config-schema > mrbot.schema.json")
+}
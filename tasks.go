@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gasoid/merge-bot/v3/cache"
+	"github.com/gasoid/merge-bot/v3/handlers"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/scheduler"
+)
+
+const (
+	// registryDays is how long projects are maintained after their last webhook
+	registryDays = 30
+)
+
+// projectTasks are run by the scheduler for every project the bot has seen
+var projectTasks = []struct {
+	name string
+	spec string
+	run  func(*handlers.Request) error
+}{
+	{"labels", "@hourly", (*handlers.Request).CreateLabels},
+	{"stale-branches", "@hourly", (*handlers.Request).DeleteStaleBranches},
+	{"review-reminders", "@hourly", (*handlers.Request).RemindReviewers},
+}
+
+func startScheduler(ctx context.Context) error {
+	s, err := scheduler.New()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range projectTasks {
+		if err := s.Add(t.name, t.spec, func(ctx context.Context) {
+			forEachProject(ctx, t.name, t.run)
+		}); err != nil {
+			return err
+		}
+	}
+
+	s.Start(ctx)
+	return nil
+}
+
+func forEachProject(ctx context.Context, task string, run func(*handlers.Request) error) {
+	projects, err := cache.ListProjects(time.Now().AddDate(0, 0, -registryDays))
+	if err != nil {
+		logger.Error("registered projects can't be loaded", "task", task, "err", err)
+		return
+	}
+
+	for _, p := range projects {
+		if ctx.Err() != nil {
+			return
+		}
+
+		command, err := handlers.New(p.Provider)
+		if err != nil {
+			logger.Error("can't initialize provider", "provider", p.Provider, "task", task, "err", err)
+			continue
+		}
+
+		if err := command.LoadProjectConfig(p.ID); err != nil {
+			logger.Error("can't load repo config", "projectId", p.ID, "task", task, "err", err)
+			continue
+		}
+
+		if err := run(command); err != nil {
+			logger.Error("scheduled task returns err", "projectId", p.ID, "task", task, "err", err)
+		}
+	}
+}
@@ -11,10 +11,14 @@ import (
 )
 
 const (
-	mergeAction  = "merge"
-	openAction   = "open"
-	updateAction = "update"
-	pushAction   = "push"
+	mergeAction    = "merge"
+	openAction     = "open"
+	updateAction   = "update"
+	pushAction     = "push"
+	pipelineAction = "pipeline"
+	branchAction   = "branch"
+	failedStatus   = "failed"
+	branchRef      = "refs/heads/"
 )
 
 func init() {
@@ -30,6 +34,8 @@ type GitlabProvider struct {
 	projectId int64
 	id        int64
 	secret    string
+	branch    string
+	after     string
 }
 
 func New() webhook.Provider {
@@ -42,10 +48,12 @@ func (g GitlabProvider) GetSecret() string {
 
 func (g *GitlabProvider) ParseRequest(request *http.Request) error {
 	var (
-		err     error
-		ok      bool
-		comment *gitlab.MergeCommentEvent
-		mr      *gitlab.MergeEvent
+		err      error
+		ok       bool
+		comment  *gitlab.MergeCommentEvent
+		mr       *gitlab.MergeEvent
+		pipeline *gitlab.PipelineEvent
+		push     *gitlab.PushEvent
 	)
 
 	eventHeader := request.Header.Get("X-Gitlab-Event")
@@ -88,6 +96,35 @@ func (g *GitlabProvider) ParseRequest(request *http.Request) error {
 		g.updatedAt = mr.ObjectAttributes.UpdatedAt
 	}
 
+	if pipeline, ok = event.(*gitlab.PipelineEvent); ok {
+		// branch pipelines don't belong to any merge request
+		if pipeline.MergeRequest.IID == 0 {
+			return nil
+		}
+
+		g.projectId = pipeline.Project.ID
+		g.id = pipeline.MergeRequest.IID
+
+		if pipeline.ObjectAttributes.Status == failedStatus {
+			g.action = pipelineAction
+		}
+	}
+
+	if push, ok = event.(*gitlab.PushEvent); ok {
+		// tags are pushed by TagPushEvent, but refs are checked to be sure
+		if !strings.HasPrefix(push.Ref, branchRef) {
+			return nil
+		}
+
+		g.projectId = push.ProjectID
+		g.branch = strings.TrimPrefix(push.Ref, branchRef)
+		// deleted branches have zero sha
+		if strings.Trim(push.After, "0") != "" {
+			g.after = push.After
+		}
+		g.action = branchAction
+	}
+
 	return nil
 }
 
@@ -103,6 +140,10 @@ func (g *GitlabProvider) GetCmd() string {
 		return webhook.OnUpdate
 	case pushAction:
 		return webhook.OnCommit
+	case pipelineAction:
+		return webhook.OnPipeline
+	case branchAction:
+		return strings.Join([]string{webhook.OnBranchPush, g.branch, g.after}, " ")
 	}
 
 	logger.Debug("getCmd", "note", g.note)
@@ -7,11 +7,14 @@ import (
 )
 
 const (
-	OnNewMR     = "\anewMREvent"
-	OnMerge     = "\amergeEvent"
-	OnUpdate    = "\aupdateEvent"
-	OnCommit    = "\acommitEvent"
-	spaceSymbol = " "
+	OnNewMR    = "\anewMREvent"
+	OnMerge    = "\amergeEvent"
+	OnUpdate   = "\aupdateEvent"
+	OnCommit   = "\acommitEvent"
+	OnPipeline = "\apipelineEvent"
+	// OnBranchPush has no MR, args are "<branch> <sha>"
+	OnBranchPush = "\abranchPushEvent"
+	spaceSymbol  = " "
 )
 
 var (
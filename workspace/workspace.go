@@ -0,0 +1,322 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gasoid/merge-bot/v3/config"
+	"github.com/gasoid/merge-bot/v3/logger"
+	"github.com/gasoid/merge-bot/v3/metrics"
+
+	"github.com/ldez/go-git-cmd-wrapper/v2/fetch"
+	"github.com/ldez/go-git-cmd-wrapper/v2/git"
+	"github.com/ldez/go-git-cmd-wrapper/v2/global"
+	ginit "github.com/ldez/go-git-cmd-wrapper/v2/init"
+	"github.com/ldez/go-git-cmd-wrapper/v2/worktree"
+)
+
+const (
+	mirrorSuffix = ".git"
+	// HeadsRefSpec mirrors branches only, tags and MR refs are not needed for updates
+	HeadsRefSpec = "+refs/heads/*:refs/heads/*"
+)
+
+var (
+	cacheDir   string
+	cacheQuota string
+
+	defaultManager *Manager
+	defaultErr     error
+	defaultOnce    sync.Once
+)
+
+func init() {
+	config.StringVar(&cacheDir, "git-cache-dir", filepath.Join(os.TempDir(), "merge-bot-mirrors"), "directory for bare mirrors of repositories, mount a volume to keep them across restarts (also via GIT_CACHE_DIR)")
+	config.StringVar(&cacheQuota, "git-cache-quota", "5Gb", "max disk size of bare mirrors in Gb/Mb/Kb, least recently used mirrors are evicted (also via GIT_CACHE_QUOTA)")
+}
+
+// Manager keeps bare mirrors of repositories and checks them out to worktrees
+type Manager struct {
+	dir   string
+	quota uint64
+
+	mu    sync.Mutex
+	repos map[string]*sync.Mutex
+
+	// usage of mirrors is scanned once and updated after every use of a mirror
+	usageMu sync.Mutex
+	usage   map[string]mirrorUsage
+}
+
+// New returns Manager which keeps mirrors in dir, quota is the max size of all mirrors in bytes
+func New(dir string, quota uint64) *Manager {
+	return &Manager{dir: dir, quota: quota, repos: map[string]*sync.Mutex{}}
+}
+
+// Default returns Manager configured by -git-cache-dir and -git-cache-quota
+func Default() (*Manager, error) {
+	defaultOnce.Do(func() {
+		quota, err := humanize.ParseBytes(cacheQuota)
+		if err != nil {
+			defaultErr = fmt.Errorf("git-cache-quota %q is invalid: %w", cacheQuota, err)
+			return
+		}
+
+		defaultManager = New(cacheDir, quota)
+	})
+
+	return defaultManager, defaultErr
+}
+
+// mirrorName is stable for the repository and doesn't depend on credentials in the URL
+func mirrorName(repoURL string) (string, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", err
+	}
+
+	parsed.User = nil
+	sum := sha256.Sum256([]byte(parsed.String()))
+	name := strings.TrimSuffix(path.Base(parsed.Path), mirrorSuffix)
+
+	return fmt.Sprintf("%s-%s%s", hex.EncodeToString(sum[:8]), name, mirrorSuffix), nil
+}
+
+func (m *Manager) repoLock(name string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.repos[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.repos[name] = lock
+	}
+
+	return lock
+}
+
+// Use calls fn with the dir of the mirror of the repository, the mirror may not exist yet,
+// calls for the same repository run one by one and least recently used mirrors are evicted afterwards
+func (m *Manager) Use(repoURL string, fn func(mirror string) error) error {
+	name, err := mirrorName(repoURL)
+	if err != nil {
+		return fmt.Errorf("repository url is invalid: %w", err)
+	}
+
+	lock := m.repoLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o750); err != nil {
+		return fmt.Errorf("git cache dir error: %w", err)
+	}
+
+	mirror := filepath.Join(m.dir, name)
+	err = fn(mirror)
+
+	// mtime of the mirror tells least recently used ones
+	now := time.Now()
+	if err := os.Chtimes(mirror, now, now); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Debug("git mirror mtime can't be updated", "dir", mirror, "err", err)
+	}
+
+	m.track(name, dirSize(mirror), now)
+	m.evict()
+
+	return err
+}
+
+// Run fetches the repository into its mirror and calls fn with a detached worktree of the branch,
+// the worktree is removed when fn returns
+func (m *Manager) Run(repoURL, branch string, fn func(dir string) error) error {
+	return m.Use(repoURL, func(mirror string) error {
+		if err := m.sync(mirror, repoURL); err != nil {
+			return err
+		}
+
+		return m.withWorktree(mirror, branch, fn)
+	})
+}
+
+// sync clones the repository into a bare mirror on the first use and fetches it afterwards,
+// the URL isn't stored in the mirror because it may contain credentials
+func (m *Manager) sync(mirror, repoURL string) error {
+	operation := "fetch"
+	start := time.Now()
+
+	if _, err := os.Stat(mirror); errors.Is(err, fs.ErrNotExist) {
+		operation = "clone"
+
+		if output, err := git.Init(ginit.Bare, ginit.Quiet, ginit.Directory(mirror)); err != nil {
+			logger.Debug("git init error", "dir", mirror, "output", output)
+			return fmt.Errorf("git init error: %w, output: %s", err, output)
+		}
+	}
+
+	// worktrees of interrupted operations hold their commits
+	if output, err := git.Worktree(global.UpperC(mirror), worktree.Prune); err != nil {
+		logger.Debug("git worktree prune error", "dir", mirror, "output", output)
+	}
+
+	if output, err := git.Fetch(global.UpperC(mirror), fetch.Prune, fetch.NoTags, fetch.Quiet, fetch.Remote(repoURL), fetch.RefSpec(HeadsRefSpec)); err != nil {
+		logger.Debug("git fetch error", "dir", mirror, "output", output)
+
+		if operation == "clone" {
+			// the next operation starts from scratch
+			os.RemoveAll(mirror) //nolint:errcheck
+		}
+
+		return fmt.Errorf("git %s error: %w, output: %s", operation, err, output)
+	}
+
+	metrics.GitDuration(operation, time.Since(start))
+	return nil
+}
+
+func (m *Manager) withWorktree(mirror, branch string, fn func(dir string) error) error {
+	tmp, err := os.MkdirTemp("", "merge-bot")
+	if err != nil {
+		logger.Debug("temp dir error", "error", err)
+		return fmt.Errorf("temp dir error: %w", err)
+	}
+
+	defer func() {
+		os.RemoveAll(tmp) //nolint:errcheck
+
+		if output, err := git.Worktree(global.UpperC(mirror), worktree.Prune); err != nil {
+			logger.Debug("git worktree prune error", "dir", mirror, "output", output)
+		}
+	}()
+
+	dir := filepath.Join(tmp, "worktree")
+
+	// detached worktree doesn't move branches of the mirror, they are updated by fetch only
+	if output, err := git.Worktree(global.UpperC(mirror), worktree.Add(dir, "refs/heads/"+branch), worktree.Detach); err != nil {
+		logger.Debug("git worktree add error", "dir", dir, "output", output)
+		return fmt.Errorf("git worktree add error: %w, output: %s", err, output)
+	}
+
+	return fn(dir)
+}
+
+type mirrorUsage struct {
+	name   string
+	size   uint64
+	usedAt time.Time
+}
+
+func dirSize(dir string) uint64 {
+	var size uint64
+
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			size += uint64(info.Size())
+		}
+
+		return nil
+	})
+
+	return size
+}
+
+// scan measures mirrors left by previous runs, it is done once, usageMu must be held
+func (m *Manager) scan() {
+	if m.usage != nil {
+		return
+	}
+
+	m.usage = map[string]mirrorUsage{}
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		logger.Debug("git cache dir can't be read", "dir", m.dir, "err", err)
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasSuffix(e.Name(), mirrorSuffix) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		m.usage[e.Name()] = mirrorUsage{name: e.Name(), size: dirSize(filepath.Join(m.dir, e.Name())), usedAt: info.ModTime()}
+	}
+}
+
+// track records the size of the mirror after it has been cloned or fetched, a mirror without files is gone
+func (m *Manager) track(name string, size uint64, usedAt time.Time) {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+
+	m.scan()
+
+	if size == 0 {
+		delete(m.usage, name)
+		return
+	}
+
+	m.usage[name] = mirrorUsage{name: name, size: size, usedAt: usedAt}
+}
+
+// evict removes least recently used mirrors until all of them fit the quota, mirrors in use are kept
+func (m *Manager) evict() {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+
+	m.scan()
+
+	var total uint64
+	for _, mirror := range m.usage {
+		total += mirror.size
+	}
+
+	if total <= m.quota {
+		return
+	}
+
+	mirrors := slices.SortedFunc(maps.Values(m.usage), func(a, b mirrorUsage) int {
+		return a.usedAt.Compare(b.usedAt)
+	})
+
+	for _, mirror := range mirrors {
+		if total <= m.quota {
+			return
+		}
+
+		lock := m.repoLock(mirror.name)
+		if !lock.TryLock() {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(m.dir, mirror.name)); err != nil {
+			logger.Error("git mirror can't be evicted", "mirror", mirror.name, "err", err)
+		} else {
+			logger.Debug("git mirror is evicted", "mirror", mirror.name, "size", humanize.Bytes(mirror.size))
+			total -= mirror.size
+			delete(m.usage, mirror.name)
+		}
+
+		lock.Unlock()
+	}
+}